@@ -9,97 +9,248 @@ Currently, it only support algorithms which can solve binary classification prob
 package hector
 
 import (
-	"strconv"
+	"context"
+	"errors"
 	"os"
+	"strconv"
 )
 
 func AlgorithmRun(classifier Classifier, train_path string, test_path string, pred_path string, params map[string]string) (float64, []*LabelPrediction, error) {
+	return AlgorithmRunContext(context.Background(), classifier, train_path, test_path, pred_path, params, nil)
+}
+
+/*
+AlgorithmRunContext is AlgorithmRun which stops training when ctx is done and sends progress of training to sink
+*/
+func AlgorithmRunContext(ctx context.Context, classifier Classifier, train_path string, test_path string, pred_path string, params map[string]string, sink ProgressSink) (float64, []*LabelPrediction, error) {
 	global, _ := strconv.ParseInt(params["global"], 10, 64)
+	online, ok := classifier.(OnlineClassifier)
+	if ok && params["stream"] == "1" {
+		err := AlgorithmTrainOnlineContext(ctx, online, train_path, params, sink)
+		if err != nil {
+			return 0.5, nil, err
+		}
+		test_dataset := NewDataSet()
+		err = test_dataset.Load(test_path, global)
+		if err != nil {
+			return 0.5, nil, err
+		}
+		return AlgorithmRunOnDataSetContext(ctx, classifier, nil, test_dataset, pred_path, params, sink)
+	}
+
 	train_dataset := NewDataSet()
 
 	err := train_dataset.Load(train_path, global)
-	
-	if err != nil{
+
+	if err != nil {
 		return 0.5, nil, err
 	}
-	
+
 	test_dataset := NewDataSet()
 	err = test_dataset.Load(test_path, global)
-	if err != nil{
+	if err != nil {
+		return 0.5, nil, err
+	}
+	err = classifier.Init(params)
+	if err != nil {
+		return 0.5, nil, err
+	}
+	err = LoadValidation(classifier, params)
+	if err != nil {
 		return 0.5, nil, err
 	}
-	classifier.Init(params)
-	auc, predictions := AlgorithmRunOnDataSet(classifier, train_dataset, test_dataset, pred_path, params)
+	return AlgorithmRunOnDataSetContext(ctx, classifier, train_dataset, test_dataset, pred_path, params, sink)
+}
 
-	return auc, predictions, nil
+func AlgorithmTrain(classifier Classifier, train_path string, params map[string]string) error {
+	return AlgorithmTrainContext(context.Background(), classifier, train_path, params, nil)
 }
 
-func AlgorithmTrain(classifier Classifier, train_path string, params map[string]string) (error) {
+/*
+AlgorithmTrainContext is AlgorithmTrain which stops training when ctx is done and sends progress of training
+to sink. The partial model of a ContextClassifier is still saved to the model file, and ctx.Err() is returned
+*/
+func AlgorithmTrainContext(ctx context.Context, classifier Classifier, train_path string, params map[string]string, sink ProgressSink) error {
 	global, _ := strconv.ParseInt(params["global"], 10, 64)
+	online, ok := classifier.(OnlineClassifier)
+	if ok && params["stream"] == "1" {
+		return AlgorithmTrainOnlineContext(ctx, online, train_path, params, sink)
+	}
+
 	train_dataset := NewDataSet()
 
 	err := train_dataset.Load(train_path, global)
-	
-	if err != nil{
+
+	if err != nil {
 		return err
 	}
 
-	classifier.Init(params)
-	classifier.Train(train_dataset)
+	err = classifier.Init(params)
+	if err != nil {
+		return err
+	}
+	err = LoadValidation(classifier, params)
+	if err != nil {
+		return err
+	}
+	err = TrainWithContext(ctx, classifier, train_dataset, sink)
+	if _, ok := classifier.(ContextClassifier); err != nil && !ok {
+		return err
+	}
 
 	model_path, _ := params["model"]
 
 	if model_path != "" {
-		classifier.SaveModel(model_path)
+		save_err := classifier.SaveModel(model_path)
+		if save_err != nil {
+			return save_err
+		}
 	}
 
+	return err
+}
+
+/*
+LoadValidation loads the validation file of params for classifiers which monitor validation
+datasets, it does nothing if there is no validation file or the classifier does not support it
+*/
+func LoadValidation(classifier interface{}, params map[string]string) error {
+	validated, ok := classifier.(ValidatedClassifier)
+	validation_path, _ := params["validation"]
+	if !ok || validation_path == "" {
+		return nil
+	}
+	global, _ := strconv.ParseInt(params["global"], 10, 64)
+	dataset := NewDataSet()
+	err := dataset.Load(validation_path, global)
+	if err != nil {
+		return err
+	}
+	validated.SetValidation(dataset)
 	return nil
 }
 
+/*
+AlgorithmTrainOnline trains an online classifier without loading the training file into memory.
+The file is re-opened for each of the "steps" passes, so stdin ("-") only supports one pass
+*/
+func AlgorithmTrainOnline(classifier OnlineClassifier, train_path string, params map[string]string) error {
+	return AlgorithmTrainOnlineContext(context.Background(), classifier, train_path, params, nil)
+}
+
+/*
+AlgorithmTrainOnlineContext is AlgorithmTrainOnline which stops reading the training file when ctx is done.
+The model of the samples trained is saved, and ctx.Err() is returned
+*/
+func AlgorithmTrainOnlineContext(ctx context.Context, classifier OnlineClassifier, train_path string, params map[string]string, sink ProgressSink) error {
+	global, _ := strconv.ParseInt(params["global"], 10, 64)
+	steps, _ := strconv.Atoi(params["steps"])
+	if steps < 1 {
+		steps = 1
+	}
+	if train_path == "-" && steps > 1 {
+		return errors.New("can not make more than one pass over stdin")
+	}
+
+	err := classifier.Init(params)
+	if err != nil {
+		return err
+	}
+	for step := 0; step < steps; step++ {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		dataset := OpenStreamingDataSetContext(ctx, train_path, global)
+		classifier.TrainOnline(dataset)
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		if dataset.Err() != nil {
+			return dataset.Err()
+		}
+		sink.Send(TrainEvent{Kind: TrainEventEnum.EPOCH, Step: step + 1, Total: steps})
+	}
+
+	model_path, _ := params["model"]
+
+	if model_path != "" {
+		save_err := classifier.SaveModel(model_path)
+		if save_err != nil {
+			return save_err
+		}
+	}
+	return err
+}
+
+/*
+AlgorithmTest loads the model and predicts the test file. If the model file has a header,
+the test file is loaded with the global bias feature id recorded in it
+*/
 func AlgorithmTest(classifier Classifier, test_path string, pred_path string, params map[string]string) (float64, []*LabelPrediction, error) {
 	global, _ := strconv.ParseInt(params["global"], 10, 64)
-	
+
 	model_path, _ := params["model"]
-	classifier.Init(params)
+	err := classifier.Init(params)
+	if err != nil {
+		return 0.0, nil, err
+	}
 	if model_path != "" {
-		classifier.LoadModel(model_path)
+		err = classifier.LoadModel(model_path)
+		if err != nil {
+			return 0.0, nil, err
+		}
+		header, err := ReadModelHeader(model_path)
+		if err == nil {
+			global = header.Global
+		}
 	} else {
 		return 0.0, nil, nil
 	}
 
 	test_dataset := NewDataSet()
-	err := test_dataset.Load(test_path, global)
-	if err != nil{
+	err = test_dataset.Load(test_path, global)
+	if err != nil {
 		return 0.0, nil, err
 	}
-	
+
 	auc, predictions := AlgorithmRunOnDataSet(classifier, nil, test_dataset, pred_path, params)
 
 	return auc, predictions, nil
 }
 
 func AlgorithmRunOnDataSet(classifier Classifier, train_dataset, test_dataset *DataSet, pred_path string, params map[string]string) (float64, []*LabelPrediction) {
-	
+	auc, predictions, _ := AlgorithmRunOnDataSetContext(context.Background(), classifier, train_dataset, test_dataset, pred_path, params, nil)
+	return auc, predictions
+}
+
+/*
+AlgorithmRunOnDataSetContext is AlgorithmRunOnDataSet which stops training when ctx is done and sends progress
+of training to sink. The test dataset is not predicted by models whose training is stopped
+*/
+func AlgorithmRunOnDataSetContext(ctx context.Context, classifier Classifier, train_dataset, test_dataset *DataSet, pred_path string, params map[string]string, sink ProgressSink) (float64, []*LabelPrediction, error) {
 	if train_dataset != nil {
-		classifier.Train(train_dataset)
+		err := TrainWithContext(ctx, classifier, train_dataset, sink)
+		if err != nil {
+			return 0.5, nil, err
+		}
 	}
 
 	predictions := []*LabelPrediction{}
 	var pred_file *os.File
-	if pred_path != ""{
+	if pred_path != "" {
 		pred_file, _ = os.Create(pred_path)
 	}
-	for _,sample := range test_dataset.Samples {
+	for _, sample := range test_dataset.Samples {
 		prediction := classifier.Predict(sample)
-		if pred_file != nil{
+		if pred_file != nil {
 			pred_file.WriteString(strconv.FormatFloat(prediction, 'g', 5, 64) + "\n")
 		}
-		predictions = append(predictions, &(LabelPrediction{Label: sample.Label, Prediction: prediction}))
+		predictions = append(predictions, &(LabelPrediction{Label: sample.Label, Prediction: prediction, Weight: sample.Weight, HasWeight: true}))
 	}
-	if pred_path != ""{
+	if pred_path != "" {
 		defer pred_file.Close()
 	}
-		
+
 	auc := AUC(predictions)
-	return auc, predictions
+	return auc, predictions, nil
 }
@@ -0,0 +1,152 @@
+package hector
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+type AlgorithmType int
+
+var AlgorithmTypeEnum = struct {
+	BINARY AlgorithmType
+	MULTI_CLASS AlgorithmType
+	REGRESSION AlgorithmType
+}{1, 2, 4}
+
+/*
+Algorithm describes a registered method. New must return a Classifier if Types contains
+BINARY or REGRESSION, and a MultiClassClassifier if Types contains MULTI_CLASS
+*/
+type Algorithm struct {
+	Name string
+	Description string
+	Types AlgorithmType
+	Params ParamSchema
+	New func() interface{}
+}
+
+func (a *Algorithm) Supports(t AlgorithmType) bool {
+	return a.Types & t != 0
+}
+
+func (a *Algorithm) TypeNames() []string {
+	ret := []string{}
+	if a.Supports(AlgorithmTypeEnum.BINARY) {
+		ret = append(ret, "binary")
+	}
+	if a.Supports(AlgorithmTypeEnum.MULTI_CLASS) {
+		ret = append(ret, "multi-class")
+	}
+	if a.Supports(AlgorithmTypeEnum.REGRESSION) {
+		ret = append(ret, "regression")
+	}
+	return ret
+}
+
+var algorithms = make(map[string]*Algorithm)
+
+/*
+RegisterAlgorithm makes an algorithm available to GetClassifier, GetMutliClassClassifier and
+command line tools. Register in an init function, so that PrepareParams can define flags for
+its params. It panics if the name is registered twice or New does not match Types
+*/
+func RegisterAlgorithm(algo Algorithm) {
+	if algo.Name == "" || algo.New == nil {
+		panic("hector: RegisterAlgorithm needs name and constructor")
+	}
+	if _, dup := algorithms[algo.Name]; dup {
+		panic("hector: RegisterAlgorithm called twice for " + algo.Name)
+	}
+	instance := algo.New()
+	if algo.Supports(AlgorithmTypeEnum.BINARY | AlgorithmTypeEnum.REGRESSION) {
+		if _, ok := instance.(Classifier); !ok {
+			panic("hector: " + algo.Name + " does not implement Classifier")
+		}
+	}
+	if algo.Supports(AlgorithmTypeEnum.MULTI_CLASS) {
+		if _, ok := instance.(MultiClassClassifier); !ok {
+			panic("hector: " + algo.Name + " does not implement MultiClassClassifier")
+		}
+	}
+	if algo.Params == nil {
+		algo.Params = ParamSchema{}
+	}
+	algorithms[algo.Name] = &algo
+}
+
+/*
+unregisterAlgorithm removes an algorithm registered by tests, so that it does not stay in Methods and merged params
+*/
+func unregisterAlgorithm(name string) {
+	delete(algorithms, name)
+}
+
+func GetAlgorithm(method string) (*Algorithm, error) {
+	algo, ok := algorithms[method]
+	if !ok {
+		return nil, fmt.Errorf("unknown method %s, registered methods are %s", method, strings.Join(Methods(), ", "))
+	}
+	return algo, nil
+}
+
+/*
+Methods returns names of all registered algorithms in alphabetical order
+*/
+func Methods() []string {
+	ret := []string{}
+	for method, _ := range algorithms {
+		ret = append(ret, method)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+/*
+AlgorithmOf finds the registered algorithm whose constructor returns the same type as model,
+it returns nil if the type is not registered
+*/
+func AlgorithmOf(model interface{}) *Algorithm {
+	model_type := reflect.TypeOf(model)
+	for _, method := range Methods() {
+		algo := algorithms[method]
+		if reflect.TypeOf(algo.New()) == model_type {
+			return algo
+		}
+	}
+	return nil
+}
+
+func MethodsOfType(t AlgorithmType) []string {
+	ret := []string{}
+	for _, method := range Methods() {
+		if algorithms[method].Supports(t) {
+			ret = append(ret, method)
+		}
+	}
+	return ret
+}
+
+func GetClassifier(method string) (Classifier, error) {
+	algo, err := GetAlgorithm(method)
+	if err != nil {
+		return nil, err
+	}
+	if !algo.Supports(AlgorithmTypeEnum.BINARY | AlgorithmTypeEnum.REGRESSION) {
+		return nil, errors.New(method + " is not a binary classification or regression method")
+	}
+	return algo.New().(Classifier), nil
+}
+
+func GetMutliClassClassifier(method string) (MultiClassClassifier, error) {
+	algo, err := GetAlgorithm(method)
+	if err != nil {
+		return nil, err
+	}
+	if !algo.Supports(AlgorithmTypeEnum.MULTI_CLASS) {
+		return nil, errors.New(method + " is not a multi-class classification method")
+	}
+	return algo.New().(MultiClassClassifier), nil
+}
@@ -0,0 +1,72 @@
+package hector
+
+import (
+	"math"
+	"testing"
+)
+
+type constantClassifier struct {
+	value float64
+}
+
+func (c *constantClassifier) Init(params map[string]string) error {
+	values, err := ParamSchema{FloatParam("constant-value", 0.5, 0.0, 1.0, "prediction of constant classifier")}.Parse(params)
+	if err != nil {
+		return err
+	}
+	c.value = values.Float("constant-value")
+	return nil
+}
+
+func (c *constantClassifier) Train(dataset *DataSet) {}
+
+func (c *constantClassifier) Predict(sample *Sample) float64 {
+	return c.value
+}
+
+func (c *constantClassifier) SaveModel(path string) error {
+	return nil
+}
+
+func (c *constantClassifier) LoadModel(path string) error {
+	return nil
+}
+
+func TestAlgorithmRegistry(t *testing.T) {
+	RegisterAlgorithm(Algorithm{
+		Name: "test-constant",
+		Types: AlgorithmTypeEnum.BINARY,
+		Params: ParamSchema{FloatParam("constant-value", 0.5, 0.0, 1.0, "prediction of constant classifier")},
+		New: func() interface{} { return &(constantClassifier{}) },
+	})
+	defer unregisterAlgorithm("test-constant")
+
+	classifier, err := GetClassifier("test-constant")
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := DefaultParams()
+	params["constant-value"] = "0.3"
+	err = classifier.Init(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(classifier.Predict(NewSample()) - 0.3) > 1e-9 {
+		t.Error("registered classifier is not initialized by params")
+	}
+
+	_, err = GetClassifier("no-such-method")
+	if err == nil {
+		t.Error("unknown method should fail")
+	}
+	_, err = GetMutliClassClassifier("lr")
+	if err == nil {
+		t.Error("lr is not a multi-class method")
+	}
+	for _, method := range MethodsOfType(AlgorithmTypeEnum.MULTI_CLASS) {
+		_, err = GetMutliClassClassifier(method)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
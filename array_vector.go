@@ -36,6 +36,20 @@ func (v *ArrayVector) FromString(buf string) {
 	}
 }
 
+func (v *ArrayVector) WriteBinary(w *BinaryWriter) {
+	w.Int(len(v.data))
+	for _, value := range v.data {
+		w.Float(value)
+	}
+}
+
+func (v *ArrayVector) ReadBinary(r *BinaryReader) {
+	n := r.Len()
+	for i := 0; i < n; i++ {
+		v.data = append(v.data, r.Float())
+	}
+}
+
 func (v *ArrayVector) Expand(size int){
 	for len(v.data) < size {
 		v.data = append(v.data, 0.0)
@@ -130,13 +144,15 @@ func (v *ArrayVector) Scale(s float64) {
 }
 
 func (v *ArrayVector) SoftMaxNorm() *ArrayVector {
+	// subtract max value, so that exp does not overflow
+	_, max_val := v.KeyWithMaxValue()
 	sum := 0.0
 	for _, val := range v.data {
-		sum += math.Exp(val)
+		sum += math.Exp(val - max_val)
 	}
 	ret := NewArrayVector()
 	for key, val := range v.data {
-		ret.SetValue(key, math.Exp(val) / sum)
+		ret.SetValue(key, math.Exp(val - max_val) / sum)
 	}
 	return ret
 }
\ No newline at end of file
@@ -1,6 +1,7 @@
 package main
 
 import(
+	"context"
 	"hector"
 	"strconv"
 	"fmt"
@@ -45,16 +46,34 @@ func main(){
 		defer pprof.StopCPUProfile()
 	}
 	
+	// Ctrl-C stops training of the current fold, AUC is averaged over folds done, a second Ctrl-C quits at once
+	ctx, stop := hector.InterruptContext()
+	defer stop()
+	progress := hector.WriterProgress(os.Stderr, 10)
+
 	average_auc := 0.0
 	for part := 0; part < total; part++ {
 		train, test := SplitFile(dataset, total, part)
-		classifier := hector.GetClassifier(method)
-		classifier.Init(params)
-		auc, _ := hector.AlgorithmRunOnDataSet(classifier, train, test, "", params)
+		classifier, err := hector.GetClassifier(method)
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = classifier.Init(params)
+		if err != nil {
+			log.Fatal(err)
+		}
+		auc, _, err := hector.AlgorithmRunOnDataSetContext(ctx, classifier, train, test, "", params, progress)
+		if err == context.Canceled {
+			fmt.Printf("training is interrupted after %d folds\n", part)
+			total = part
+			break
+		}
 		fmt.Println("AUC:")
 		fmt.Println(auc)
 		average_auc += auc
 		classifier = nil
 	}
-	fmt.Println(average_auc / float64(total))
+	if total > 0 {
+		fmt.Println(average_auc / float64(total))
+	}
 }
\ No newline at end of file
@@ -4,13 +4,17 @@ import(
 	"hector"
 	"strings"
 	"os"
+	"log"
 )
 
 func main(){
 	train, _, _, _, params := hector.PrepareParams()
 	
 	feature_combination := hector.CategoryFeatureCombination{}
-	feature_combination.Init(params)
+	err := feature_combination.Init(params)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	dataset := hector.NewRawDataSet()
 	dataset.Load(train)
@@ -1,6 +1,7 @@
 package main
 
 import(
+	"context"
 	"hector"
 	"strconv"
 	"fmt"
@@ -44,12 +45,28 @@ func main(){
 		defer pprof.StopCPUProfile()
 	}
 	
+	// Ctrl-C stops training of the current fold, accuracy is averaged over folds done, a second Ctrl-C quits at once
+	ctx, stop := hector.InterruptContext()
+	defer stop()
+	progress := hector.WriterProgress(os.Stderr, 10)
+
 	average_accuracy := 0.0
 	for part := 0; part < total; part++ {
 		train, test := SplitFile(dataset, total, part)
-		classifier := hector.GetMutliClassClassifier(method)
-		classifier.Init(params)
-		accuracy := hector.MultiClassRunOnDataSet(classifier, train, test, "", params)
+		classifier, err := hector.GetMutliClassClassifier(method)
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = classifier.Init(params)
+		if err != nil {
+			log.Fatal(err)
+		}
+		accuracy, err := hector.MultiClassRunOnDataSetContext(ctx, classifier, train, test, "", params, progress)
+		if err == context.Canceled {
+			fmt.Printf("training is interrupted after %d folds\n", part)
+			total = part
+			break
+		}
 		fmt.Println("accuracy : ", accuracy)
 		average_accuracy += accuracy
 		classifier = nil
@@ -57,5 +74,7 @@ func main(){
 		test = nil
 		runtime.GC()
 	}
-	fmt.Println(average_accuracy / float64(total))
+	if total > 0 {
+		fmt.Println(average_accuracy / float64(total))
+	}
 }
\ No newline at end of file
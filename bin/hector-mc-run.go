@@ -1,6 +1,7 @@
 package main
 
 import(
+	"context"
 	"hector"
 	"fmt"
 	"log"
@@ -13,7 +14,10 @@ func main(){
 	
 	action, _ := params["action"]
 
-	classifier := hector.GetMutliClassClassifier(method)
+	classifier, err := hector.GetMutliClassClassifier(method)
+	if err != nil {
+		log.Fatal(err)
+	}
 	
 	profile, _ := params["profile"]
 	if profile != "" {
@@ -26,11 +30,23 @@ func main(){
 		defer pprof.StopCPUProfile()
 	}
 
+	// Ctrl-C stops training, models of the steps done are still saved by the train action, a second Ctrl-C quits at once
+	ctx, stop := hector.InterruptContext()
+	defer stop()
+	progress := hector.WriterProgress(os.Stderr, 10)
+
 	if action == "" {
-		accuracy, _ := hector.MultiClassRun(classifier, train, test, pred, params)
+		accuracy, err := hector.MultiClassRunContext(ctx, classifier, train, test, pred, params, progress)
+		if err == context.Canceled {
+			fmt.Println("training is interrupted")
+			return
+		}
 		fmt.Println("accuracy : ", accuracy)
 	} else if action == "train" {
-		hector.MultiClassTrain(classifier, train, params)
+		err = hector.MultiClassTrainContext(ctx, classifier, train, params, progress)
+		if err == context.Canceled {
+			fmt.Println("training is interrupted")
+		}
 
 	} else if action == "test" {
 		accuracy, _ := hector.MultiClassTest(classifier, test, pred, params)
@@ -1,8 +1,11 @@
 package main
 
 import(
+	"context"
 	"hector"
 	"fmt"
+	"log"
+	"os"
 )
 
 func main(){
@@ -10,18 +13,47 @@ func main(){
 	
 	action, _ := params["action"]
 
-	classifier := hector.GetClassifier(method)
+	classifier, err := hector.GetClassifier(method)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Ctrl-C stops training, models of the steps done are still saved by the train action, a second Ctrl-C quits at once
+	ctx, stop := hector.InterruptContext()
+	defer stop()
+	progress := hector.WriterProgress(os.Stderr, 10)
 	
 	if action == "" {
-		auc, _, _ := hector.AlgorithmRun(classifier, train, test, pred, params)
+		auc, _, err := hector.AlgorithmRunContext(ctx, classifier, train, test, pred, params, progress)
+		if err == context.Canceled {
+			fmt.Println("training is interrupted")
+			return
+		}
 		fmt.Println("AUC:")
 		fmt.Println(auc)
 	} else if action == "train" {
-		hector.AlgorithmTrain(classifier, train, params)
+		err = hector.AlgorithmTrainContext(ctx, classifier, train, params, progress)
+		if err == context.Canceled {
+			fmt.Println("training is interrupted")
+		}
 
 	} else if action == "test" {
 		auc, _, _ := hector.AlgorithmTest(classifier, test, pred, params)
 		fmt.Println("AUC:")
 		fmt.Println(auc)
+	} else if action == "importance" {
+		importance, err := hector.AlgorithmImportance(classifier, test, params)
+		if err != nil {
+			log.Fatal(err)
+		}
+		out := os.Stdout
+		if params["output"] != "" {
+			out, err = os.Create(params["output"])
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer out.Close()
+		}
+		hector.WriteFeatureImportance(out, importance)
 	}
 }
\ No newline at end of file
@@ -7,6 +7,7 @@ import(
 	"fmt"
 	"bufio"
 	"sync"
+	"log"
 )
 
 func SplitFile(input string, total int, part int) (string, string, error) {
@@ -44,7 +45,7 @@ func SplitFile(input string, total int, part int) (string, string, error) {
 }
 
 func main(){
-	train_path, test_path, pred_path, _, params := hector.PrepareParams()
+	train_path, test_path, _, _, params := hector.PrepareParams()
 	total := 5
 	methods := []string{"ftrl", "fm"}
 	all_methods_predictions := [][]*hector.LabelPrediction{}
@@ -55,7 +56,10 @@ func main(){
 		all_predictions := []*hector.LabelPrediction{}
 		for part := 0; part < total; part++ {
 			train, test, _ := SplitFile(train_path, total, part)
-			classifier := hector.GetClassifier(method)
+			classifier, err := hector.GetClassifier(method)
+			if err != nil {
+				log.Fatal(err)
+			}
 			
 			auc, predictions, _ := hector.AlgorithmRun(classifier, train, test, "", params)
 			fmt.Println("AUC:")
@@ -71,7 +75,10 @@ func main(){
 		all_methods_predictions = append(all_methods_predictions, all_predictions)
 		fmt.Println(average_auc / float64(total))
 		
-		classifier := hector.GetClassifier(method)
+		classifier, err := hector.GetClassifier(method)
+		if err != nil {
+			log.Fatal(err)
+		}
 		fmt.Println(test_path)
 		_, test_predictions, _ := hector.AlgorithmRun(classifier, train_path, test_path, "", params)
 		all_methods_test_predictions = append(all_methods_test_predictions, test_predictions)
@@ -79,7 +86,7 @@ func main(){
 	
 	var wait sync.WaitGroup
 	wait.Add(2)
-	dataset := hector.NewDataSet()
+	dataset := hector.NewStreamingDataSet(1000)
 	go func(){
 		for i, _ := range all_methods_predictions[0] {
 			sample := hector.NewSample()
@@ -96,8 +103,11 @@ func main(){
 	
 	ensembler := hector.LinearRegression{}
 	go func(){
-		ensembler.Init(params)
-		ensembler.Train(dataset)
+		err := ensembler.Init(params)
+		if err != nil {
+			log.Fatal(err)
+		}
+		ensembler.TrainOnline(dataset)
 		wait.Done()
 	}()
 	wait.Wait()
@@ -105,11 +115,11 @@ func main(){
 	fmt.Println(ensembler.Model)
 	
 	wait.Add(2)
-	test_dataset := hector.NewDataSet()
+	test_dataset := hector.NewStreamingDataSet(1000)
 	go func(){
 		for i, _ := range all_methods_test_predictions[0] {
 			sample := hector.NewSample()
-			sample.Label = all_methods_test_predictions[0][i].Prediction
+			sample.Label = all_methods_test_predictions[0][i].Label
 			for j, _ := range all_methods_test_predictions{
 				feature := hector.Feature{Id: int64(j), Value: all_methods_test_predictions[j][i].Prediction}
 				sample.AddFeature(feature)
@@ -123,7 +133,7 @@ func main(){
 	go func(){
 		pred_file, _ := os.Create(test_path + ".out")
 		for sample := range test_dataset.Samples {
-			prediction := sample.Label //ensembler.Predict(sample)
+			prediction := ensembler.Predict(sample)
 			pred_file.WriteString(strconv.FormatFloat(prediction, 'g', 5, 64) + "\n")
 		}
 		defer pred_file.Close()
@@ -1,13 +1,8 @@
 package hector
 
 import (
-	"strconv"
 	"sort"
 	"container/list"
-	"fmt"
-	"io/ioutil"
-	"os"
-	"bufio"
 	"math/rand"
 	"math"
 )
@@ -21,14 +16,41 @@ type CART struct {
 	params CARTParams
 	continuous_features bool
 	salt int64
+	// random generator of training, each tree of forests has its own
+	rng *rand.Rand
+	bins *FeatureBins
+	label_count int
+	init_params map[string]string
 }
 
-func DTGoLeft(sample *MapBasedSample, feature_split Feature) bool {
-	value, ok := sample.Features[feature_split.Id]
-	if ok && value >= feature_split.Value {
-		return true
-	} else {
-		return false
+/*
+DTGoLeft sends samples whose value of the split feature is not less than the split value, or is one of
+the categories of categorical splits, to the left child. Samples without the feature go to the direction
+learned for missing values in training
+*/
+func DTGoLeft(sample *MapBasedSample, node *TreeNode) bool {
+	value, ok := sample.Features[node.feature_split.Id]
+	if !ok {
+		return node.missing_left
+	}
+	if node.categories != nil {
+		return node.hasCategory(value)
+	}
+	return value >= node.feature_split.Value
+}
+
+/*
+normalizeLabels scales the label distribution of samples of rows, weighted by samples, to sum 1. If the
+samples all weigh 0, the distribution counts their labels instead of being NaN
+*/
+func normalizeLabels(distribution *ArrayVector, samples []*MapBasedSample, rows []int) {
+	if distribution.Sum() == 0 {
+		for _, k := range rows {
+			distribution.AddValue(samples[k].Label, 1.0)
+		}
+	}
+	if distribution.Sum() > 0 {
+		distribution.Scale(1.0 / distribution.Sum())
 	}
 }
 
@@ -52,13 +74,19 @@ func (dt *CART) RandByFeatureId(fid int64) float64{
 }
 
 func (dt *CART) FindBestSplitOfContinusousFeature(samples []*MapBasedSample, node *TreeNode, feature_select_prob float64){
+	if dt.bins != nil {
+		dt.findBestSplitByHistogram(samples, node, feature_select_prob)
+		return
+	}
 	feature_weight_labels := make(map[int64]*FeatureLabelDistribution)
 	total_dis := NewArrayVector()
+	total_count := 0
 	for i, k := range node.samples{
-		if i > 10 && rand.Float64() > dt.params.SamplingRatio {
+		if i > 10 && dt.rng.Float64() > dt.params.SamplingRatio {
 			continue
 		}
-		total_dis.AddValue(samples[k].Label, 1.0)
+		total_dis.AddValue(samples[k].Label, samples[k].GetWeight())
+		total_count++
 		for fid, fvalue := range samples[k].Features{
 			if dt.RandByFeatureId(fid) > feature_select_prob {
 				continue
@@ -67,24 +95,173 @@ func (dt *CART) FindBestSplitOfContinusousFeature(samples []*MapBasedSample, nod
 			if !ok {
 				feature_weight_labels[fid] = NewFeatureLabelDistribution()
 			}	
-			feature_weight_labels[fid].AddWeightLabel(fvalue, samples[k].Label)
+			feature_weight_labels[fid].AddWeightLabel(fvalue, samples[k].Label, samples[k].GetWeight())
 		}
 	}
 	
 	min_gini := 1.0
-	node.feature_split = Feature{Id:-1, Value: 0}
+	node.setSplit(-1, 0.0, false, nil)
 	for fid, distribution := range feature_weight_labels{
+		if dt.params.CategoricalFeatures[fid] {
+			stats := newLabelCategoryStats(total_dis)
+			for _, wl := range distribution.weight_label {
+				stats.AddLabel(wl.weight, wl.label, wl.sample_weight)
+			}
+			categories, gini, missing_left, ok := stats.BestSplitByGini(total_dis)
+			if ok && (min_gini > gini || (min_gini == gini && fid < node.feature_split.Id)) {
+				min_gini = gini
+				node.setSplit(fid, 0.0, missing_left, categories)
+			}
+			continue
+		}
 		sort.Sort(distribution)
 		split, gini := distribution.BestSplitByGini(total_dis)
-		if min_gini > gini {
+		missing_left := false
+		// samples without the feature go with small values, or with large values if it is better
+		if distribution.Len() < total_count {
+			missing_split, missing_gini := distribution.BestSplitByGiniMissingHigh(total_dis)
+			if missing_gini < gini {
+				split, gini, missing_left = missing_split, missing_gini, true
+			}
+		}
+		// ties are broken by feature id, so trees do not depend on order of maps
+		if min_gini > gini || (min_gini == gini && fid < node.feature_split.Id) {
 			min_gini = gini
-			node.feature_split.Id = fid
-			node.feature_split.Value = split
+			node.setSplit(fid, split, missing_left, nil)
 		}
 	}
 	if min_gini > dt.params.GiniThreshold {
-		node.feature_split.Id = -1
-		node.feature_split.Value = 0.0
+		node.setSplit(-1, 0.0, false, nil)
+	}
+}
+
+/*
+prepareBins sets bins of dataset if max-bins is positive, they are used by FindBestSplitOfContinusousFeature
+*/
+func (dt *CART) prepareBins(dataset *DataSet) {
+	dt.bins = nil
+	if dt.params.MaxBins <= 0 {
+		return
+	}
+	dt.bins = dataset.FeatureBins(dt.params.MaxBins)
+	dt.label_count = 0
+	for _, sample := range dataset.Samples {
+		if dt.label_count <= sample.Label {
+			dt.label_count = sample.Label + 1
+		}
+	}
+}
+
+/*
+histogram keeps weights of samples of each label in node
+*/
+func (dt *CART) histogram(samples []*MapBasedSample, node *TreeNode) *Histogram {
+	ret := NewHistogram(dt.label_count)
+	stats := make([]float64, dt.label_count)
+	for _, k := range node.samples {
+		stats[samples[k].Label] = samples[k].GetWeight()
+		ret.Add(dt.bins, k, stats...)
+		stats[samples[k].Label] = 0.0
+	}
+	return ret
+}
+
+/*
+findBestSplitByHistogram scans bins of features instead of sorted values. With dt-sample-ratio
+less than 1, each node samples its own histogram, so histograms of children are not subtracted
+*/
+func (dt *CART) findBestSplitByHistogram(samples []*MapBasedSample, node *TreeNode, feature_select_prob float64) {
+	total_dis := NewArrayVector()
+	hist := node.histogram
+	// bins of categorical features mix categories, their splits are found by samples of node
+	category_samples := []int{}
+	if dt.params.SamplingRatio < 1.0 {
+		hist = NewHistogram(dt.label_count)
+		stats := make([]float64, dt.label_count)
+		for i, k := range node.samples {
+			if i > 10 && dt.rng.Float64() > dt.params.SamplingRatio {
+				continue
+			}
+			total_dis.AddValue(samples[k].Label, samples[k].GetWeight())
+			stats[samples[k].Label] = samples[k].GetWeight()
+			hist.Add(dt.bins, k, stats...)
+			stats[samples[k].Label] = 0.0
+			category_samples = append(category_samples, k)
+		}
+	} else {
+		for _, k := range node.samples {
+			total_dis.AddValue(samples[k].Label, samples[k].GetWeight())
+		}
+		if hist == nil {
+			hist = dt.histogram(samples, node)
+			node.histogram = hist
+		}
+		category_samples = node.samples
+	}
+
+	min_gini := 1.0
+	node.setSplit(-1, 0.0, false, nil)
+	for fid, _ := range dt.params.CategoricalFeatures {
+		if dt.RandByFeatureId(fid) > feature_select_prob {
+			continue
+		}
+		stats := newLabelCategoryStats(total_dis)
+		for _, k := range category_samples {
+			if value, ok := samples[k].Features[fid]; ok {
+				stats.AddLabel(value, samples[k].Label, samples[k].GetWeight())
+			}
+		}
+		categories, gini, missing_left, ok := stats.BestSplitByGini(total_dis)
+		if ok && (min_gini > gini || (min_gini == gini && fid < node.feature_split.Id)) {
+			min_gini = gini
+			node.setSplit(fid, 0.0, missing_left, categories)
+		}
+	}
+	for fid, _ := range hist.features {
+		if dt.RandByFeatureId(fid) > feature_select_prob || dt.params.CategoricalFeatures[fid] {
+			continue
+		}
+		present_dis := NewArrayVector()
+		for b := 0; b < hist.Bins(fid); b++ {
+			for label, count := range hist.Stats(fid, b) {
+				present_dis.AddValue(label, count)
+			}
+		}
+		// samples without the feature go with small values, or with large values (left) if it is better
+		for _, missing_left := range []bool{false, true} {
+			left_dis := total_dis.Copy()
+			left_dis.AddVector(present_dis, -1.0)
+			right_dis := present_dis.Copy()
+			if missing_left {
+				if left_dis.Sum() == 0 {
+					break
+				}
+				right_dis.AddVector(left_dis, 1.0)
+				left_dis = NewArrayVector()
+			}
+			for b := 0; b < hist.Bins(fid); b++ {
+				stats := hist.Stats(fid, b)
+				count := 0.0
+				for _, c := range stats {
+					count += c
+				}
+				if count == 0 {
+					continue
+				}
+				gini := Gini(left_dis, right_dis)
+				if min_gini > gini || (min_gini == gini && fid < node.feature_split.Id) {
+					min_gini = gini
+					node.setSplit(fid, dt.bins.Thresholds[fid][b], missing_left, nil)
+				}
+				for label, c := range stats {
+					left_dis.AddValue(label, c)
+					right_dis.AddValue(label, -c)
+				}
+			}
+		}
+	}
+	if min_gini > dt.params.GiniThreshold {
+		node.setSplit(-1, 0.0, false, nil)
 	}
 }
 
@@ -92,10 +269,10 @@ func (dt *CART) FindBestSplitOfBinaryFeature(samples []*MapBasedSample, node *Tr
 	feature_right_dis := make(map[int64]*ArrayVector)
 	total_dis := NewArrayVector()
 	for i, k := range node.samples{
-		if i > 10 && rand.Float64() > dt.params.SamplingRatio {
+		if i > 10 && dt.rng.Float64() > dt.params.SamplingRatio {
 			continue
 		}
-		total_dis.AddValue(samples[k].Label, 1.0)
+		total_dis.AddValue(samples[k].Label, samples[k].GetWeight())
 		for fid, _ := range samples[k].Features{
 			if dt.RandByFeatureId(fid) > feature_select_prob {
 				continue
@@ -104,7 +281,7 @@ func (dt *CART) FindBestSplitOfBinaryFeature(samples []*MapBasedSample, node *Tr
 			if !ok {
 				feature_right_dis[fid] = NewArrayVector()
 			}
-			feature_right_dis[fid].AddValue(samples[k].Label, 1.0)
+			feature_right_dis[fid].AddValue(samples[k].Label, samples[k].GetWeight())
 		}
 	}
 	
@@ -114,7 +291,7 @@ func (dt *CART) FindBestSplitOfBinaryFeature(samples []*MapBasedSample, node *Tr
 		left_dis := total_dis.Copy()
 		left_dis.AddVector(right_dis, -1.0)
 		gini := Gini(left_dis, right_dis)
-		if min_gini > gini {
+		if min_gini > gini || (min_gini == gini && fid < node.feature_split.Id) {
 			min_gini = gini
 			node.feature_split.Id = fid
 			node.feature_split.Value = 1.0
@@ -128,6 +305,9 @@ func (dt *CART) FindBestSplitOfBinaryFeature(samples []*MapBasedSample, node *Tr
 
 
 func (dt *CART) AppendNodeToTree(samples []*MapBasedSample, node *TreeNode, queue *list.List, tree *Tree, feature_select_prob float64) {
+	defer func() {
+		node.histogram = nil
+	}()
 	if node.depth >= dt.params.MaxDepth {
 		return
 	}
@@ -146,19 +326,23 @@ func (dt *CART) AppendNodeToTree(samples []*MapBasedSample, node *TreeNode, queu
 	left_node.prediction = NewArrayVector()
 	right_node.prediction = NewArrayVector()
 	for _, k := range node.samples {
-		if DTGoLeft(samples[k], node.feature_split) {
+		if DTGoLeft(samples[k], node) {
 			left_node.samples = append(left_node.samples, k)
-			left_node.prediction.AddValue(samples[k].Label, 1.0)
+			left_node.prediction.AddValue(samples[k].Label, samples[k].GetWeight())
 		} else {
 			right_node.samples = append(right_node.samples, k)
-			right_node.prediction.AddValue(samples[k].Label, 1.0)
+			right_node.prediction.AddValue(samples[k].Label, samples[k].GetWeight())
 		}
 	}
+	node.gain = GiniGain(left_node.prediction, right_node.prediction)
+	setChildHistograms(node, &left_node, &right_node, func(child *TreeNode) *Histogram {
+		return dt.histogram(samples, child)
+	})
 	node.samples = nil
 	
 	if len(left_node.samples) > dt.params.MinLeafSize {
 		left_node.sample_count = len(left_node.samples)
-		left_node.prediction.Scale(1.0 / left_node.prediction.Sum())
+		normalizeLabels(left_node.prediction, samples, left_node.samples)
 		queue.PushBack(&left_node)
 		node.left = len(tree.nodes)
 		tree.AddTreeNode(&left_node)
@@ -166,7 +350,7 @@ func (dt *CART) AppendNodeToTree(samples []*MapBasedSample, node *TreeNode, queu
 
 	if len(right_node.samples) > dt.params.MinLeafSize {
 		right_node.sample_count = len(right_node.samples)
-		right_node.prediction.Scale(1.0 / right_node.prediction.Sum())
+		normalizeLabels(right_node.prediction, samples, right_node.samples)
 		queue.PushBack(&right_node)
 		node.right = len(tree.nodes)
 		tree.AddTreeNode(&right_node)
@@ -174,24 +358,45 @@ func (dt *CART) AppendNodeToTree(samples []*MapBasedSample, node *TreeNode, queu
 }
 
 func (dt *CART) SingleTreeBuild(samples []*MapBasedSample, feature_select_prob float64, bootstrap bool) Tree {
+	tree, _ := dt.BaggedTreeBuild(samples, feature_select_prob, bootstrap)
+	return tree
+}
+
+/*
+withRand returns a copy of dt which draws random numbers from rng, so trees can be built concurrently
+*/
+func (dt *CART) withRand(rng *rand.Rand) *CART {
+	ret := *dt
+	ret.rng = rng
+	return &ret
+}
+
+/*
+BaggedTreeBuild builds a tree as SingleTreeBuild, and returns whether each sample is in the bootstrap
+sample of the tree. Samples not in bag are out-of-bag samples of the tree, the mask is nil without bootstrap
+*/
+func (dt *CART) BaggedTreeBuild(samples []*MapBasedSample, feature_select_prob float64, bootstrap bool) (Tree, []bool) {
 	tree := Tree{}
 	queue := list.New()
 	root := TreeNode{depth: 0, left: -1, right: -1, prediction: NewArrayVector(), samples: []int{}}
 	
+	var in_bag []bool
 	if !bootstrap {
 		for i, sample := range samples {
 			root.AddSample(i)
-			root.prediction.AddValue(sample.Label, 1.0)
+			root.prediction.AddValue(sample.Label, sample.GetWeight())
 		}
 	} else {
+		in_bag = make([]bool, len(samples))
 		for i := 0; i < len(samples); i++ {
-			k := rand.Intn(len(samples))
+			k := dt.rng.Intn(len(samples))
 			root.AddSample(k)
-			root.prediction.AddValue(samples[k].Label, 1.0)
+			root.prediction.AddValue(samples[k].Label, samples[k].GetWeight())
+			in_bag[k] = true
 		}
 	}
 	root.sample_count = len(root.samples)
-	root.prediction.Scale(1.0 / root.prediction.Sum())
+	normalizeLabels(root.prediction, samples, root.samples)
 
 	queue.PushBack(&root)
 	tree.AddTreeNode(&root)
@@ -205,7 +410,7 @@ func (dt *CART) SingleTreeBuild(samples []*MapBasedSample, feature_select_prob f
 			dt.AppendNodeToTree(samples, node, queue, &tree, feature_select_prob)
 		}
 	}
-	return tree
+	return tree, in_bag
 }
 
 func PredictBySingleTree(tree *Tree, sample *MapBasedSample) (*TreeNode, string) {
@@ -213,7 +418,7 @@ func PredictBySingleTree(tree *Tree, sample *MapBasedSample) (*TreeNode, string)
 	node := tree.GetNode(0)
 	path += node.ToString()
 	for {
-		if DTGoLeft(sample, node.feature_split) {
+		if DTGoLeft(sample, node) {
 			if node.left >= 0 && node.left < tree.Size() {
 				node = tree.GetNode(node.left)
 				path += "-" + node.ToString()
@@ -250,12 +455,10 @@ func (dt *CART) Train(dataset * DataSet) {
 		msample := sample.ToMapBasedSample()
 		samples = append(samples, msample)
 	}
-	if dt.continuous_features {
-		fmt.Println("Continuous DataSet")
-	} else {
-		fmt.Println("Binary DataSet")
-	}
+	logDebug("dataset of cart", "continuous", dt.continuous_features)
+	dt.prepareBins(dataset)
 	dt.tree = dt.SingleTreeBuild(samples, 1.0, false)
+	dt.bins = nil
 }
 
 func (dt *CART) Predict(sample * Sample) float64 {
@@ -264,25 +467,38 @@ func (dt *CART) Predict(sample * Sample) float64 {
 	return node.prediction.GetValue(1)
 }
 
+func (dt *CART) Trees() []*Tree {
+	return []*Tree{&(dt.tree)}
+}
+
 func (dt *CART) PredictMultiClass(sample * Sample)  *ArrayVector {
 	msample := sample.ToMapBasedSample()
 	node,_ := PredictBySingleTree(&dt.tree, msample)
 	return node.prediction
 }
 
-func (self *CART) SaveModel(path string){
-	ioutil.WriteFile(path, self.tree.ToString(), 0600)
+func (self *CART) SaveModel(path string) error {
+	header := NewModelHeader(self, self.init_params, TreesFeatureCount([]*Tree{&(self.tree)}))
+	if header.Binary() {
+		w := NewBinaryWriter(header.Format)
+		self.tree.WriteBinary(w)
+		return WriteModelFile(path, header, w.Bytes())
+	}
+	return WriteModelFile(path, header, self.tree.ToString())
 }
 
-func (self *CART) LoadModel(path string){
-	file, _ := os.Open(path)
-	defer file.Close()
-	text := ""
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		text += scanner.Text() + "\n"
+func (self *CART) LoadModel(path string) error {
+	header, body, err := ReadModelBody(path, self)
+	if err != nil {
+		return err
+	}
+	if header.Binary() {
+		r := NewBinaryReader(body, header.Format).WithVersion(header.Version)
+		self.tree.ReadBinary(r)
+		return r.Err()
 	}
-	self.tree.FromString(string(text))
+	self.tree.FromString(string(body))
+	return nil
 }
 
 
@@ -291,18 +507,52 @@ type CARTParams struct {
 	MinLeafSize int
 	GiniThreshold float64
 	SamplingRatio float64
+	MaxBins int
+	CategoricalFeatures map[int64]bool
+}
+
+var CARTParamSchema = ParamSchema{
+	IntParam("min-leaf-size", 10, 0, math.Inf(1), "min leaf size in dt"),
+	IntParam("max-depth", 10, 1, math.Inf(1), "max depth of dt"),
+	FloatParam("gini", 1.0, 0.0, 1.0, "gini threshold, node will not be split if its best gini is larger"),
+	PositiveFloatParam("dt-sample-ratio", 1.0, 1.0, "sampling ratio when split feature in decision tree"),
+	MaxBinsParam,
+	CategoricalFeaturesParam,
+	SeedParam,
+}
+
+var MaxBinsParam = IntParam("max-bins", 0, 0, math.Inf(1), "bins of each feature in histogram split finding of trees, 0 finds splits on sorted values")
+
+func init() {
+	RegisterAlgorithm(Algorithm{
+		Name: "cart",
+		Description: "classification tree",
+		Types: AlgorithmTypeEnum.BINARY | AlgorithmTypeEnum.MULTI_CLASS,
+		Params: CARTParamSchema,
+		New: func() interface{} { return &(CART{}) },
+	})
 }
 
-func (dt *CART) Init(params map[string]string) {
+func (dt *CART) Init(params map[string]string) error {
 	dt.tree = Tree{}
 	dt.continuous_features = false
-	min_leaf_size, _ := strconv.ParseInt(params["min-leaf-size"], 10, 32)
-	max_depth, _ := strconv.ParseInt(params["max-depth"], 10, 32)
+	values, err := CARTParamSchema.Parse(params)
+	if err != nil {
+		return err
+	}
 	
-	dt.params.MinLeafSize = int(min_leaf_size)
-	dt.params.MaxDepth = int(max_depth)
-	dt.params.GiniThreshold, _ = strconv.ParseFloat(params["gini"], 64)
-	dt.salt = rand.Int63n(10000000000)
-	dt.params.SamplingRatio, _ = strconv.ParseFloat(params["dt-sample-ratio"], 64)
+	dt.params.MinLeafSize = values.Int("min-leaf-size")
+	dt.params.MaxDepth = values.Int("max-depth")
+	dt.params.GiniThreshold = values.Float("gini")
+	dt.rng = NewRand(int64(values.Int("seed")))
+	dt.salt = dt.rng.Int63n(10000000000)
+	dt.params.SamplingRatio = values.Float("dt-sample-ratio")
+	dt.params.MaxBins = values.Int("max-bins")
+	dt.params.CategoricalFeatures, err = ParseFeatureIds(values.String("categorical-features"))
+	if err != nil {
+		return err
+	}
+	dt.init_params = params
+	return nil
 }
 
@@ -0,0 +1,198 @@
+package hector
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"testing"
+)
+
+// labels of samples are 1 if feature 1 is not less than 0.5, and a third of positive samples miss feature 1
+func missingValueDataSet(n int) *DataSet {
+	ret := NewDataSet()
+	for i := 0; i < n; i++ {
+		x := rand.Float64()
+		sample := NewSample()
+		if x >= 0.5 {
+			sample.Label = 1
+		}
+		if sample.Label == 0 || rand.Float64() > 0.33 {
+			sample.AddFeature(Feature{Id: 1, Value: x})
+		}
+		sample.AddFeature(Feature{Id: 2, Value: rand.Float64()})
+		ret.AddSample(sample)
+	}
+	return ret
+}
+
+func TestMissingValueDirection(t *testing.T) {
+	train_dataset := missingValueDataSet(1000)
+	test_dataset := missingValueDataSet(500)
+	for _, max_bins := range []string{"0", "16"} {
+		params := DefaultParams()
+		params["max-depth"] = "1"
+		params["min-leaf-size"] = "5"
+		params["max-bins"] = max_bins
+
+		cart := CART{}
+		cart.Init(params)
+		cart.Train(train_dataset)
+		root := cart.tree.GetNode(0)
+		if root.feature_split.Id != 1 || !root.missing_left {
+			t.Errorf("root of cart with %s bins splits by %v, missing values go left : %v", max_bins, root.feature_split, root.missing_left)
+		}
+
+		for _, second_order := range []string{"0", "1"} {
+			params["second-order"] = second_order
+			params["learning-rate"] = "0.5"
+			gbdt := GBDT{}
+			gbdt.Init(params)
+			gbdt.Train(train_dataset)
+			root = gbdt.dts[0].tree.GetNode(0)
+			if root.feature_split.Id != 1 || !root.missing_left {
+				t.Errorf("root of gbdt with %s bins and second-order %s splits by %v, missing values go left : %v", max_bins, second_order, root.feature_split, root.missing_left)
+			}
+			for _, sample := range test_dataset.Samples {
+				if len(sample.Features) == 1 && gbdt.Predict(sample) < 0.5 {
+					t.Fatalf("gbdt with %s bins and second-order %s predicts %f for positive sample without feature 1", max_bins, second_order, gbdt.Predict(sample))
+				}
+			}
+		}
+	}
+}
+
+func TestMissingValueDirectionInModelFile(t *testing.T) {
+	params := DefaultParams()
+	params["max-depth"] = "3"
+	params["min-leaf-size"] = "5"
+	cart := CART{}
+	cart.Init(params)
+	cart.Train(missingValueDataSet(1000))
+	if !cart.tree.GetNode(0).missing_left {
+		t.Fatal("missing values of root of cart go right")
+	}
+
+	file, _ := ioutil.TempFile("", "hector-cart")
+	file.Close()
+	defer os.Remove(file.Name())
+	for _, format := range []string{MODEL_FORMAT_TEXT, MODEL_FORMAT_BINARY} {
+		cart.init_params["model-format"] = format
+		err := cart.SaveModel(file.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		loaded := CART{}
+		err = loaded.LoadModel(file.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, node := range cart.tree.nodes {
+			if node.missing_left != loaded.tree.nodes[i].missing_left {
+				t.Fatalf("direction of missing values of node %d changes after load in %s format", i, format)
+			}
+		}
+	}
+
+	// trees written before directions of missing values send them right
+	tree := Tree{}
+	tree.FromString("1\n0\t-1\t-1\t0\t0.5|0.5|\t10\t1\t0.5\n")
+	if tree.GetNode(0).missing_left {
+		t.Error("missing values go left in tree without directions")
+	}
+}
+
+// feature 1 is one of 10 categories, labels of samples of categories 1, 4 and 7 are 1
+func categoricalDataSet(n int) *DataSet {
+	ret := NewDataSet()
+	for i := 0; i < n; i++ {
+		category := rand.Intn(10)
+		sample := NewSample()
+		if category % 3 == 1 && category < 8 {
+			sample.Label = 1
+		}
+		sample.AddFeature(Feature{Id: 1, Value: float64(category)})
+		sample.AddFeature(Feature{Id: 2, Value: rand.Float64()})
+		ret.AddSample(sample)
+	}
+	return ret
+}
+
+func TestCategoricalSplit(t *testing.T) {
+	train_dataset := categoricalDataSet(1000)
+	separates := func(node *TreeNode) bool {
+		if node.feature_split.Id != 1 || node.categories == nil {
+			return false
+		}
+		left := node.hasCategory(1.0)
+		for category := 0; category < 10; category++ {
+			positive := category % 3 == 1 && category < 8
+			if node.hasCategory(float64(category)) != (positive == left) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, max_bins := range []string{"0", "16"} {
+		params := DefaultParams()
+		params["max-depth"] = "1"
+		params["min-leaf-size"] = "5"
+		params["max-bins"] = max_bins
+		params["categorical-features"] = "1"
+
+		cart := CART{}
+		err := cart.Init(params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cart.Train(train_dataset)
+		if !separates(cart.tree.GetNode(0)) {
+			t.Errorf("root of cart with %s bins splits by %v and categories %v", max_bins, cart.tree.GetNode(0).feature_split, cart.tree.GetNode(0).categories)
+		}
+		for _, second_order := range []string{"0", "1"} {
+			params["second-order"] = second_order
+			gbdt := GBDT{}
+			gbdt.Init(params)
+			gbdt.Train(train_dataset)
+			root := gbdt.dts[0].tree.GetNode(0)
+			if !separates(root) {
+				t.Errorf("root of gbdt with %s bins and second-order %s splits by %v and categories %v", max_bins, second_order, root.feature_split, root.categories)
+			}
+		}
+	}
+
+	params := DefaultParams()
+	params["max-depth"] = "2"
+	params["categorical-features"] = "x"
+	cart := CART{}
+	if cart.Init(params) == nil {
+		t.Error("bad categorical features are accepted")
+	}
+	params["categorical-features"] = "1"
+	cart.Init(params)
+	cart.Train(train_dataset)
+	file, _ := ioutil.TempFile("", "hector-cart")
+	file.Close()
+	defer os.Remove(file.Name())
+	for _, format := range []string{MODEL_FORMAT_TEXT, MODEL_FORMAT_BINARY} {
+		cart.init_params["model-format"] = format
+		err := cart.SaveModel(file.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		loaded := CART{}
+		err = loaded.LoadModel(file.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, node := range cart.tree.nodes {
+			if len(node.categories) != len(loaded.tree.nodes[i].categories) {
+				t.Fatalf("categories of node %d change from %v to %v after load in %s format", i, node.categories, loaded.tree.nodes[i].categories, format)
+			}
+			for k, category := range node.categories {
+				if category != loaded.tree.nodes[i].categories[k] {
+					t.Fatalf("categories of node %d change from %v to %v after load in %s format", i, node.categories, loaded.tree.nodes[i].categories, format)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package hector
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var CategoricalFeaturesParam = StringParam("categorical-features", "", "comma separated ids of features whose values are categories, trees split them by sets of categories")
+
+/*
+ParseFeatureIds parses comma separated feature ids, like "3,7,12"
+*/
+func ParseFeatureIds(value string) (map[int64]bool, error) {
+	ret := make(map[int64]bool)
+	for _, tk := range strings.Split(value, ",") {
+		tk = strings.TrimSpace(tk)
+		if len(tk) == 0 {
+			continue
+		}
+		fid, err := strconv.ParseInt(tk, 10, 64)
+		if err != nil {
+			return nil, errors.New("bad feature id " + tk)
+		}
+		ret[fid] = true
+	}
+	return ret, nil
+}
+
+/*
+CategoryStats keeps width statistics of samples of each category of a categorical feature, e.g.
+sum and count of goals as Histogram does for bins
+*/
+type CategoryStats struct {
+	width int
+	categories map[float64][]float64
+}
+
+func NewCategoryStats(width int) *CategoryStats {
+	return &(CategoryStats{width: width, categories: make(map[float64][]float64)})
+}
+
+func (c *CategoryStats) Add(category float64, stats ...float64) {
+	values := c.get(category)
+	for i, stat := range stats {
+		values[i] += stat
+	}
+}
+
+/*
+AddLabel adds a sample of label and weight in category, statistics of classification trees are weights of labels
+*/
+func (c *CategoryStats) AddLabel(category float64, label int, weight float64) {
+	c.get(category)[label] += weight
+}
+
+func (c *CategoryStats) get(category float64) []float64 {
+	values, ok := c.categories[category]
+	if !ok {
+		values = make([]float64, c.width)
+		c.categories[category] = values
+	}
+	return values
+}
+
+/*
+BestSplit finds the set of categories going left by Fisher's method : categories are sorted by key of
+their statistics, e.g. mean goal, and only sets of the categories of smallest keys are tried, one of
+them is the best set for variance of goals and gini of binary labels. total is statistics of all samples
+in the node, samples without the feature go right, or left if gain is larger. gain returns false for
+splits which can not be made. It returns sorted categories of the set, gain, whether missing values go
+left and whether a split is found
+*/
+func (c *CategoryStats) BestSplit(total []float64, key func(stats []float64) float64, gain func(left, right []float64) (float64, bool)) ([]float64, float64, bool, bool) {
+	categories := make([]float64, 0, len(c.categories))
+	keys := make(map[float64]float64)
+	missing := make([]float64, c.width)
+	copy(missing, total)
+	for category, stats := range c.categories {
+		categories = append(categories, category)
+		keys[category] = key(stats)
+		for i, stat := range stats {
+			missing[i] -= stat
+		}
+	}
+	// ties are broken by category, so splits do not depend on order of maps
+	sort.Slice(categories, func(i, j int) bool {
+		ki, kj := keys[categories[i]], keys[categories[j]]
+		return ki < kj || (ki == kj && categories[i] < categories[j])
+	})
+
+	best_gain := 0.0
+	best_size := 0
+	best_missing_left := false
+	found := false
+	left := make([]float64, c.width)
+	right := make([]float64, c.width)
+	for _, missing_left := range []bool{false, true} {
+		if missing_left && !nonZero(missing) {
+			break
+		}
+		for i, _ := range left {
+			left[i] = 0.0
+			right[i] = total[i]
+			if missing_left {
+				left[i] = missing[i]
+				right[i] = total[i] - missing[i]
+			}
+		}
+		// all categories may go left if missing values go right
+		for size := 1; size <= len(categories); size++ {
+			for i, stat := range c.categories[categories[size - 1]] {
+				left[i] += stat
+				right[i] -= stat
+			}
+			g, ok := gain(left, right)
+			if ok && (!found || g > best_gain) {
+				best_gain = g
+				best_size = size
+				best_missing_left = missing_left
+				found = true
+			}
+		}
+	}
+	if !found {
+		return nil, 0.0, false, false
+	}
+	ret := make([]float64, best_size)
+	copy(ret, categories[:best_size])
+	sort.Float64s(ret)
+	return ret, best_gain, best_missing_left, true
+}
+
+func newLabelCategoryStats(total_dis *ArrayVector) *CategoryStats {
+	return NewCategoryStats(len(total_dis.data))
+}
+
+/*
+BestSplitByGini finds the set of categories of least gini, statistics are weights of labels. Categories are
+ordered by share of the most frequent label of total_dis, which is the Fisher ordering of binary labels
+*/
+func (c *CategoryStats) BestSplitByGini(total_dis *ArrayVector) ([]float64, float64, bool, bool) {
+	total := make([]float64, c.width)
+	copy(total, total_dis.data)
+	major := 0
+	for label, count := range total {
+		if count > total[major] {
+			major = label
+		}
+	}
+	key := func(stats []float64) float64 {
+		sum := 0.0
+		for _, count := range stats {
+			sum += count
+		}
+		if sum == 0 {
+			return 0.0
+		}
+		return stats[major] / sum
+	}
+	gain := func(left, right []float64) (float64, bool) {
+		left_dis := ArrayVector{data: left}
+		right_dis := ArrayVector{data: right}
+		if left_dis.Sum() <= 0 || right_dis.Sum() <= 0 {
+			return 0.0, false
+		}
+		return -Gini(&left_dis, &right_dis), true
+	}
+	categories, g, missing_left, ok := c.BestSplit(total, key, gain)
+	return categories, -g, missing_left, ok
+}
+
+func nonZero(stats []float64) bool {
+	for _, stat := range stats {
+		if stat > 1e-9 || stat < -1e-9 {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+varianceGain is the gain of splits of statistics sum, sum of squares and count of goals, which is
+the negative sum of variances of children
+*/
+func varianceGain(left, right []float64) (float64, bool) {
+	if left[2] <= 0 || right[2] <= 0 {
+		return 0.0, false
+	}
+	vari := left[1] - left[0] * left[0] / left[2] + right[1] - right[0] * right[0] / right[2]
+	return -vari, true
+}
+
+func meanGoal(stats []float64) float64 {
+	return stats[0] / stats[2]
+}
+
+/*
+hasCategory tells whether value is one of the categories going left of a categorical split
+*/
+func (t *TreeNode) hasCategory(value float64) bool {
+	k := sort.SearchFloat64s(t.categories, value)
+	return k < len(t.categories) && t.categories[k] == value
+}
+
+/*
+setSplit sets the split of node, categories is nil for splits of numeric values
+*/
+func (t *TreeNode) setSplit(fid int64, value float64, missing_left bool, categories []float64) {
+	t.feature_split.Id = fid
+	t.feature_split.Value = value
+	t.missing_left = missing_left
+	t.categories = categories
+}
@@ -1,7 +1,6 @@
 package hector
 
 import(
-	"fmt"
 	"math/rand"
 )
 
@@ -9,12 +8,22 @@ type CategoryFeatureCombination struct {
 	algo *EPLogisticRegression
 	feature_combinations []CombinedFeature
 	output string
+	rng *rand.Rand
 }
 
-func (c *CategoryFeatureCombination) Init(params map[string]string) {
+func (c *CategoryFeatureCombination) Init(params map[string]string) error {
 	c.algo = &(EPLogisticRegression{})
-	c.algo.Init(params)	
+	err := c.algo.Init(params)
+	if err != nil {
+		return err
+	}
 	c.output = params["output"]
+	values, err := ParamSchema{SeedParam}.Parse(params)
+	if err != nil {
+		return err
+	}
+	c.rng = NewRand(int64(values.Int("seed")))
+	return nil
 }
 
 func (c *CategoryFeatureCombination) OneCVAUC(dataset0 *RawDataSet, combines []CombinedFeature, total_cv, cv int) float64{
@@ -57,7 +66,7 @@ func (c *CategoryFeatureCombination) FindCombination(dataset *RawDataSet) []Comb
 			}
 		}
 	}
-	fmt.Printf("candidates %d\n", len(candidate_column_combines))
+	logInfo("candidates of feature combinations", "count", len(candidate_column_combines))
 	used_combines := make(map[int]bool)
 	
 	total_cv := 3
@@ -86,7 +95,7 @@ func (c *CategoryFeatureCombination) FindCombination(dataset *RawDataSet) []Comb
 				best_auc = ave_auc
 				best_combines = i
 				ok = true
-				if rand.Intn(10) == 1{
+				if c.rng.Intn(10) == 1{
 					break
 				}
 			}
@@ -96,8 +105,7 @@ func (c *CategoryFeatureCombination) FindCombination(dataset *RawDataSet) []Comb
 		}
 		used_combines[best_combines] = true
 		c.feature_combinations = append(c.feature_combinations, candidate_column_combines[best_combines])
-		fmt.Println(best_auc)
-		fmt.Println(c.feature_combinations)
+		logInfo("feature combinations", "auc", best_auc, "combinations", c.feature_combinations)
 	}
 
 	return c.feature_combinations
@@ -2,8 +2,8 @@ package hector
 
 type Classifier interface {
 
-	//Set training parameters from parameter map
-	Init(params map[string]string)
+	//Set training parameters from parameter map, fail on unknown, missing or invalid params
+	Init(params map[string]string) error
 
 	//Train model on a given dataset
 	Train(dataset * DataSet)
@@ -11,13 +11,14 @@ type Classifier interface {
 	//Predict the probability of a sample to be positive sample
 	Predict(sample * Sample) float64
 
-	SaveModel(path string)
-	LoadModel(path string)
+	//Save model with a header of algorithm name and params, see ModelHeader
+	SaveModel(path string) error
+	LoadModel(path string) error
 }
 
 type MultiClassClassifier interface {
-	//Set training parameters from parameter map
-	Init(params map[string]string)
+	//Set training parameters from parameter map, fail on unknown, missing or invalid params
+	Init(params map[string]string) error
 
 	//Train model on a given dataset
 	Train(dataset * DataSet)
@@ -25,7 +26,22 @@ type MultiClassClassifier interface {
 	//Predict the probability of a sample to be positive sample
 	PredictMultiClass(sample * Sample) *ArrayVector
 
-	SaveModel(path string)
-	LoadModel(path string)
+	//Save model with a header of algorithm name and params, see ModelHeader
+	SaveModel(path string) error
+	LoadModel(path string) error
 
-}
\ No newline at end of file
+}
+
+/*
+ValidatedClassifier monitors a validation dataset in training, e.g. for early stopping
+*/
+type ValidatedClassifier interface {
+	SetValidation(dataset * DataSet)
+}
+
+type OnlineClassifier interface {
+	Classifier
+
+	//Make one training pass over a stream of samples, model is kept between passes
+	TrainOnline(dataset * StreamingDataSet)
+}
@@ -1,15 +1,18 @@
 package hector
 
 import (
+	"io/ioutil"
+	"math"
+	"os"
 	"testing"
 )
 func TestClassifiers(t *testing.T){
 	train_dataset := LinearDataSet(1000)
 	test_dataset := LinearDataSet(500)
 
-	algos := []string{"ep", "fm", "ftrl", "lr", "linear_svm"}
+	algos := []string{"ep", "fm", "ftrl", "lr", "lr-lbfgs", "linear_svm"}
 
-	params := make(map[string]string)
+	params := DefaultParams()
 	params["beta"] = "1.0"
 	params["steps"] = "10"
 	params["lambda1"] = "0.1"
@@ -26,8 +29,14 @@ func TestClassifiers(t *testing.T){
 	params["factors"] = "10"
 
 	for _, algo := range algos {
-		classifier := GetClassifier(algo)
-		classifier.Init(params)
+		classifier, err := GetClassifier(algo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = classifier.Init(params)
+		if err != nil {
+			t.Fatal(err)
+		}
 		auc, _ := AlgorithmRunOnDataSet(classifier, train_dataset, test_dataset, "", params)
 
 		t.Logf("auc of %s in linear dataset is %f", algo, auc)
@@ -37,10 +46,33 @@ func TestClassifiers(t *testing.T){
 	}
 }
 
+func TestLBFGSLogisticRegressionL1(t *testing.T) {
+	train_dataset := LinearDataSet(1000)
+	test_dataset := LinearDataSet(500)
+
+	params := DefaultParams()
+	params["regularization"] = "0.0"
+	dense := LBFGSLogisticRegression{}
+	dense.Init(params)
+	dense.Train(train_dataset)
+
+	params["l1-regularization"] = "0.01"
+	sparse := LBFGSLogisticRegression{}
+	sparse.Init(params)
+	auc, _ := AlgorithmRunOnDataSet(&sparse, train_dataset, test_dataset, "", params)
+	t.Logf("auc of lr-lbfgs with L1 in linear dataset is %f, %d of %d weights are kept", auc, len(sparse.Model.data), len(dense.Model.data))
+	if auc < 0.9 {
+		t.Error("auc less than 0.9 in linear dataset")
+	}
+	if len(sparse.Model.data) * 2 > len(dense.Model.data) {
+		t.Error("L1 regularization does not remove most irrelevant features")
+	}
+}
+
 func TestClassifiersOnXOR(t *testing.T) {
 	algos := []string{"ann", "rf", "rdt", "knn"}
 
-	params := make(map[string]string)
+	params := DefaultParams()
 	params["steps"] = "30"
 	params["max-depth"] = "10"
 	params["min-leaf-size"] = "10"
@@ -57,8 +89,14 @@ func TestClassifiersOnXOR(t *testing.T) {
 	for _, algo := range algos {
 		train_dataset := XORDataSet(1000)
 		test_dataset := XORDataSet(500)
-		classifier := GetClassifier(algo)
-		classifier.Init(params)
+		classifier, err := GetClassifier(algo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = classifier.Init(params)
+		if err != nil {
+			t.Fatal(err)
+		}
 		auc, _ := AlgorithmRunOnDataSet(classifier, train_dataset, test_dataset, "", params)
 
 		t.Logf("auc of %s in xor dataset is %f", algo, auc)
@@ -66,4 +104,103 @@ func TestClassifiersOnXOR(t *testing.T) {
 			t.Error("auc less than 0.9 in xor dataset")
 		}
 	}
-}
\ No newline at end of file
+}
+func TestClassifiersSaveLoad(t *testing.T) {
+	test_dataset := XORDataSet(100)
+
+	params := DefaultParams()
+	params["steps"] = "5"
+	params["hidden"] = "5"
+	params["tree-count"] = "5"
+	params["min-leaf-size"] = "5"
+	params["k"] = "10"
+
+	file, _ := ioutil.TempFile("", "hector-model")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	tolerances := map[string]float64{MODEL_FORMAT_TEXT: 1e-9, MODEL_FORMAT_BINARY: 1e-9, MODEL_FORMAT_BINARY32: 1e-4}
+	for format, tolerance := range tolerances {
+		params["model-format"] = format
+		for _, algo := range Methods() {
+			classifier, err := GetClassifier(algo)
+			if err != nil {
+				continue
+			}
+			err = classifier.Init(params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			classifier.Train(XORDataSet(300))
+			err = classifier.SaveModel(file.Name())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			loaded, err := LoadAnyModel(file.Name())
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, sample := range test_dataset.Samples {
+				p1 := classifier.Predict(sample)
+				p2 := loaded.Predict(sample)
+				if math.Abs(p1 - p2) > tolerance {
+					t.Errorf("prediction of %s in %s format on sample %d changes from %f to %f after load", algo, format, i, p1, p2)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestClassifiersSeed(t *testing.T) {
+	params := DefaultParams()
+	params["steps"] = "5"
+	params["hidden"] = "5"
+	params["tree-count"] = "5"
+	params["min-leaf-size"] = "5"
+	params["k"] = "10"
+	params["subsample"] = "0.8"
+	params["seed"] = "7"
+
+	// sums of real values in linear dataset depend on order of maps
+	datasets := map[string]func(int) *DataSet{"xor": XORDataSet, "linear": LinearDataSet}
+	for name, new_dataset := range datasets {
+		train_dataset := new_dataset(300)
+		test_dataset := new_dataset(100)
+		for _, algo := range Methods() {
+			predictions := [][]float64{}
+			for run := 0; run < 2; run++ {
+				classifier, err := GetClassifier(algo)
+				if err != nil {
+					break
+				}
+				err = classifier.Init(params)
+				if err != nil {
+					t.Fatal(err)
+				}
+				// rdt releases samples of its train dataset
+				dataset := NewDataSet()
+				for _, sample := range train_dataset.Samples {
+					dataset.AddSample(sample)
+				}
+				classifier.Train(dataset)
+				run_predictions := []float64{}
+				for _, sample := range test_dataset.Samples {
+					run_predictions = append(run_predictions, classifier.Predict(sample))
+				}
+				predictions = append(predictions, run_predictions)
+			}
+			if len(predictions) < 2 {
+				continue
+			}
+			for i, p := range predictions[0] {
+				// svm predicts NaN in xor dataset
+				if p != predictions[1][i] && !(math.IsNaN(p) && math.IsNaN(predictions[1][i])) {
+					t.Errorf("prediction of %s with the same seed in %s dataset on sample %d changes from %v to %v", algo, name, i, p, predictions[1][i])
+					break
+				}
+			}
+		}
+	}
+}
@@ -1,6 +1,6 @@
 package hector
 
 type Clustering interface {
-	Init(params map[string]string)
+	Init(params map[string]string) error
 	Cluster(dataset DataSet)
 }
\ No newline at end of file
@@ -2,6 +2,12 @@ package hector
 
 import (
 	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
 	"os"
 	"strconv"
 	"strings"
@@ -111,6 +117,7 @@ func (d *RawDataSet) Load(path string) error {
 type DataSet struct {
 	Samples []*Sample
 	max_label int
+	bins *FeatureBins
 }
 
 func NewDataSet() *DataSet {
@@ -126,8 +133,30 @@ func (d *DataSet) AddSample(sample *Sample) {
 	}
 }
 
+/*
+TotalWeight is the sum of weights of samples, which normalizes losses of batch learners
+*/
+func (d *DataSet) TotalWeight() float64 {
+	ret := 0.0
+	for _, sample := range d.Samples {
+		ret += sample.GetWeight()
+	}
+	return ret
+}
+
+/*
+lossWeight is TotalWeight, or 1 if the samples all weigh 0, so that losses are 0 instead of NaN
+*/
+func (d *DataSet) lossWeight() float64 {
+	ret := d.TotalWeight()
+	if ret <= 0 {
+		return 1.0
+	}
+	return ret
+}
+
 func (d *DataSet) Load(path string, global_bias_feature_id int64) error {
-	file, err := os.Open(path)
+	file, err := OpenDataFile(path)
 	if err != nil {
 		return err
 	}
@@ -135,43 +164,111 @@ func (d *DataSet) Load(path string, global_bias_feature_id int64) error {
 
 	scanner := bufio.NewScanner(file)
 
+	line := 0
 	for scanner.Scan() {
-		line := strings.Replace(scanner.Text(), " ", "\t", -1)
-		tks := strings.Split(line, "\t")
-		sample := Sample{Features: []Feature{}, Label: 0}
-		for i, tk := range tks {
-			if i == 0 {
-				label, _ := strconv.Atoi(tk)
-				sample.Label = label
-				if d.max_label < label {
-					d.max_label = label
+		line++
+		sample, err := ParseSampleStrict(scanner.Text(), global_bias_feature_id)
+		if err != nil {
+			return fmt.Errorf("line %d of %s: %v", line, path, err)
+		}
+		d.AddSample(sample)
+	}
+	if scanner.Err() != nil {
+		return scanner.Err()
+	}
+	return nil
+}
+
+/*
+OpenDataFile opens a dataset for reading. Path "-" means stdin, and files ending with
+".gz" are decompressed on the fly
+*/
+func OpenDataFile(path string) (io.ReadCloser, error) {
+	if path == "-" {
+		return ioutil.NopCloser(os.Stdin), nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(path, ".gz") {
+		return file, nil
+	}
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &gzipDataFile{reader, file}, nil
+}
+
+type gzipDataFile struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (f *gzipDataFile) Close() error {
+	f.Reader.Close()
+	return f.file.Close()
+}
+
+/*
+ParseSample parses one line of the libsvm-like format: label, or label:weight, followed by fid:value pairs.
+Samples without weight count once. A weight which is not a non-negative number is logged, and the sample
+counts once
+*/
+func ParseSample(line string, global_bias_feature_id int64) *Sample {
+	sample, err := ParseSampleStrict(line, global_bias_feature_id)
+	if err != nil {
+		logWarn("weight of sample is taken as 1", "error", err)
+	}
+	return sample
+}
+
+/*
+ParseSampleStrict is ParseSample which returns an error if the weight is not a non-negative number, e.g.
+"1:abc" or "1:-2". The sample is still returned with weight 1
+*/
+func ParseSampleStrict(line string, global_bias_feature_id int64) (*Sample, error) {
+	line = strings.Replace(line, " ", "\t", -1)
+	tks := strings.Split(line, "\t")
+	sample := Sample{Features: []Feature{}, Label: 0, Weight: 1.0}
+	var ret error
+	for i, tk := range tks {
+		if i == 0 {
+			// the label may be followed by the weight of the sample, like 1:2.5
+			kv := strings.SplitN(tk, ":", 2)
+			label, _ := strconv.Atoi(kv[0])
+			sample.Label = label
+			if len(kv) > 1 {
+				weight, err := strconv.ParseFloat(kv[1], 64)
+				if err != nil || weight < 0 || math.IsNaN(weight) || math.IsInf(weight, 0) {
+					ret = errors.New("bad weight " + kv[1] + " of sample")
+				} else {
+					sample.Weight = weight
 				}
-			} else {
-				kv := strings.Split(tk, ":")
-				feature_id, err := strconv.ParseInt(kv[0], 10, 64)
+			}
+		} else {
+			kv := strings.Split(tk, ":")
+			feature_id, err := strconv.ParseInt(kv[0], 10, 64)
+			if err != nil {
+				break
+			}
+			feature_value := 1.0
+			if len(kv) > 1 {
+				feature_value, err = strconv.ParseFloat(kv[1], 64)
 				if err != nil {
 					break
 				}
-				feature_value := 1.0
-				if len(kv) > 1 {
-					feature_value, err = strconv.ParseFloat(kv[1], 64)
-					if err != nil {
-						break
-					}
-				}
-				feature := Feature{feature_id, feature_value}
-				sample.Features = append(sample.Features, feature)
 			}
+			feature := Feature{feature_id, feature_value}
+			sample.Features = append(sample.Features, feature)
 		}
-		if global_bias_feature_id >= 0 {
-			sample.Features = append(sample.Features, Feature{global_bias_feature_id, 1.0})
-		}
-		d.AddSample(&sample)
 	}
-	if scanner.Err() != nil {
-		return scanner.Err()
+	if global_bias_feature_id >= 0 {
+		sample.Features = append(sample.Features, Feature{global_bias_feature_id, 1.0})
 	}
-	return nil
+	return &sample, ret
 }
 
 func RemoveLowFreqFeatures(dataset *DataSet, threshold float64) {
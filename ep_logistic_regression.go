@@ -3,8 +3,6 @@ package hector
 import (
 	"math"
 	"strconv"
-	"os"
-	"bufio"
 	"strings"
 )
 
@@ -15,9 +13,19 @@ type EPLogisticRegressionParams struct {
 type EPLogisticRegression struct {
 	Model map[int64]*Gaussian
 	params EPLogisticRegressionParams
+	init_params map[string]string
 }
 
-func (algo *EPLogisticRegression) SaveModel(path string) {
+func (algo *EPLogisticRegression) SaveModel(path string) error {
+	header := NewModelHeader(algo, algo.init_params, len(algo.Model))
+	if header.Binary() {
+		w := NewBinaryWriter(header.Format)
+		w.Int(len(algo.Model))
+		for f, g := range algo.Model {
+			w.Varint(f).Float(g.mean).Float(g.vari)
+		}
+		return WriteModelFile(path, header, w.Bytes())
+	}
 	sb := StringBuilder{}
 	for f, g := range algo.Model {
 		sb.Int64(f)
@@ -27,14 +35,26 @@ func (algo *EPLogisticRegression) SaveModel(path string) {
 		sb.Float(g.vari)
 		sb.Write("\n")
 	}
-	sb.WriteToFile(path)
+	return WriteModelFile(path, header, sb.Bytes())
 }
 
-func (algo *EPLogisticRegression) LoadModel(path string) {
-	file, _ := os.Open(path)
-	defer file.Close()
+func (algo *EPLogisticRegression) LoadModel(path string) error {
+	header, body, err := ReadModelBody(path, algo)
+	if err != nil {
+		return err
+	}
+	if header.Binary() {
+		r := NewBinaryReader(body, header.Format)
+		n := r.Len()
+		for i := 0; i < n; i++ {
+			fid := r.Varint()
+			g := Gaussian{mean: r.Float(), vari: r.Float()}
+			algo.Model[fid] = &g
+		}
+		return r.Err()
+	}
 
-	scaner := bufio.NewScanner(file)
+	scaner := NewModelScanner(body)
 	for scaner.Scan() {
 		line := scaner.Text()
 		tks := strings.Split(line, "\t")
@@ -44,6 +64,7 @@ func (algo *EPLogisticRegression) LoadModel(path string) {
 		g := Gaussian{mean: mean, vari: vari}
 		algo.Model[fid] = &g
 	}
+	return nil
 }
 
 func (algo *EPLogisticRegression) Predict(sample * Sample) float64 {
@@ -65,10 +86,30 @@ func (algo *EPLogisticRegression) Predict(sample * Sample) float64 {
 	return t.Integral(t.mean / math.Sqrt(t.vari))
 }
 
-func (algo *EPLogisticRegression) Init(params map[string]string) {
+var EPLogisticRegressionParamSchema = ParamSchema{
+	BetaParam,
+}
+
+func init() {
+	RegisterAlgorithm(Algorithm{
+		Name: "ep",
+		Description: "bayesian logistic regression with expectation propagation",
+		Types: AlgorithmTypeEnum.BINARY,
+		Params: EPLogisticRegressionParamSchema,
+		New: func() interface{} { return &(EPLogisticRegression{}) },
+	})
+}
+
+func (algo *EPLogisticRegression) Init(params map[string]string) error {
 	algo.Model = make(map[int64]*Gaussian)
-	algo.params.beta, _ = strconv.ParseFloat(params["beta"], 64)
+	values, err := EPLogisticRegressionParamSchema.Parse(params)
+	if err != nil {
+		return err
+	}
+	algo.params.beta = values.Float("beta")
 	algo.params.init_var = 1.0
+	algo.init_params = params
+	return nil
 }
 
 func (algo *EPLogisticRegression) Clear(){
@@ -77,55 +118,64 @@ func (algo *EPLogisticRegression) Clear(){
 }
 
 func (algo *EPLogisticRegression) Train(dataset *DataSet) {
-
 	for _, sample := range dataset.Samples {
-		s := Gaussian{mean: 0.0, vari: 0.0}
-		for _, feature := range sample.Features {
-			if feature.Value == 0.0{
-				continue
-			}
-			wi, ok := algo.Model[feature.Id]
-			if !ok {
-				wi = &(Gaussian{mean: 0.0, vari: algo.params.init_var})
-				algo.Model[feature.Id] = wi
-			}
-			s.mean += feature.Value * wi.mean
-			s.vari += feature.Value * feature.Value * wi.vari
-		}
+		algo.TrainSample(sample)
+	}
+}
 
-		t := s
-		t.vari += algo.params.beta
+func (algo *EPLogisticRegression) TrainOnline(dataset *StreamingDataSet) {
+	for sample := range dataset.Samples {
+		algo.TrainSample(sample)
+	}
+}
 
-		t2 := Gaussian{mean:0.0, vari: 0.0}
-		if sample.Label > 0.0 {
-			t2.UpperTruncateGaussian(t.mean, t.vari, 0.0)
-		} else {
-			t2.LowerTruncateGaussian(t.mean, t.vari, 0.0)
+func (algo *EPLogisticRegression) TrainSample(sample *Sample) {
+	s := Gaussian{mean: 0.0, vari: 0.0}
+	for _, feature := range sample.Features {
+		if feature.Value == 0.0{
+			continue
 		}
-		t.MultGaussian(&t2)
-		s2 := t
-		s2.vari += algo.params.beta
-		s0 := s
-		s.MultGaussian(&s2)
-
-		for _, feature := range sample.Features {
-			if feature.Value == 0.0{
-				continue
-			}
-			wi0 := Gaussian{mean:0.0, vari:algo.params.init_var}
-			w2 := Gaussian{mean:0.0, vari:0.0}
-			wi, _ := algo.Model[feature.Id]
-			w2.mean = (s.mean - (s0.mean - wi.mean * feature.Value)) / feature.Value
-			w2.vari = (s.vari + (s0.vari - wi.vari * feature.Value * feature.Value)) / (feature.Value * feature.Value)
-			wi.MultGaussian(&w2)
-			wi_vari := wi.vari
-			wi_new_vari := wi_vari * wi0.vari / (0.99 * wi0.vari + 0.01 * wi.vari)
-			wi.vari = wi_new_vari
-			wi.mean = wi.vari * (0.99 * wi.mean / wi_vari + 0.01 * wi0.mean / wi.vari)
-			if wi.vari < algo.params.init_var * 0.01 {
-				wi.vari = algo.params.init_var * 0.01
-			}
+		wi, ok := algo.Model[feature.Id]
+		if !ok {
+			wi = &(Gaussian{mean: 0.0, vari: algo.params.init_var})
 			algo.Model[feature.Id] = wi
 		}
+		s.mean += feature.Value * wi.mean
+		s.vari += feature.Value * feature.Value * wi.vari
+	}
+
+	t := s
+	t.vari += algo.params.beta
+
+	t2 := Gaussian{mean:0.0, vari: 0.0}
+	if sample.Label > 0.0 {
+		t2.UpperTruncateGaussian(t.mean, t.vari, 0.0)
+	} else {
+		t2.LowerTruncateGaussian(t.mean, t.vari, 0.0)
+	}
+	t.MultGaussian(&t2)
+	s2 := t
+	s2.vari += algo.params.beta
+	s0 := s
+	s.MultGaussian(&s2)
+
+	for _, feature := range sample.Features {
+		if feature.Value == 0.0{
+			continue
+		}
+		wi0 := Gaussian{mean:0.0, vari:algo.params.init_var}
+		w2 := Gaussian{mean:0.0, vari:0.0}
+		wi, _ := algo.Model[feature.Id]
+		w2.mean = (s.mean - (s0.mean - wi.mean * feature.Value)) / feature.Value
+		w2.vari = (s.vari + (s0.vari - wi.vari * feature.Value * feature.Value)) / (feature.Value * feature.Value)
+		wi.MultGaussian(&w2)
+		wi_vari := wi.vari
+		wi_new_vari := wi_vari * wi0.vari / (0.99 * wi0.vari + 0.01 * wi.vari)
+		wi.vari = wi_new_vari
+		wi.mean = wi.vari * (0.99 * wi.mean / wi_vari + 0.01 * wi0.mean / wi.vari)
+		if wi.vari < algo.params.init_var * 0.01 {
+			wi.vari = algo.params.init_var * 0.01
+		}
+		algo.Model[feature.Id] = wi
 	}
 }
\ No newline at end of file
@@ -5,9 +5,22 @@ import (
 	"math"
 )
 
+/*
+LabelPrediction is a prediction of a sample. Weight is the weight of the sample if HasWeight is set,
+predictions without weight count once
+*/
 type LabelPrediction struct {
 	Label int
 	Prediction float64
+	Weight float64
+	HasWeight bool
+}
+
+func (lp *LabelPrediction) GetWeight() float64 {
+	if lp.HasWeight {
+		return lp.Weight
+	}
+	return 1.0
 }
 
 type By func(p1, p2 *LabelPrediction) bool
@@ -48,22 +61,23 @@ func AUC(predictions0 []*LabelPrediction) float64 {
 	
 	By(prediction).Sort(predictions)
 	
+	// each pair of a positive and a negative sample counts by the product of their weights
 	pn := 0.0
-	nn := float64(len(predictions))
+	nn := 0.0
 	ret := 0.0
-	count := nn
-	for i, lp := range predictions{
+	for _, lp := range predictions{
+		weight := lp.GetWeight()
 		if lp.Label > 0 {
-			pn += 1.0
-			nn -= 1.0
-			ret += float64(count) - float64(i)
+			pn += weight
+		} else {
+			nn += weight
+			ret += weight * pn
 		}
 	}
-	ret2 := pn * (pn + 1) / 2.0;
 	if pn * nn == 0.0{
 		return 0.5
 	}
-	return (ret - ret2) / (pn * nn)
+	return ret / (pn * nn)
 }
 
 func RMSE(predictions []*LabelPrediction) float64 {
@@ -71,22 +85,43 @@ func RMSE(predictions []*LabelPrediction) float64 {
 	n := 0.0
 
 	for _, pred := range predictions {
-		ret += (float64(pred.Label) - pred.Prediction) * (float64(pred.Label) - pred.Prediction)
-		n += 1.0
+		weight := pred.GetWeight()
+		ret += weight * (float64(pred.Label) - pred.Prediction) * (float64(pred.Label) - pred.Prediction)
+		n += weight
 	}
 
 	return math.Sqrt(ret / n)
 }
 
+/*
+LogLoss is the weighted average negative log likelihood of binary labels, predictions are clipped to [1e-15, 1 - 1e-15]
+*/
+func LogLoss(predictions []*LabelPrediction) float64 {
+	ret := 0.0
+	n := 0.0
+	for _, pred := range predictions {
+		weight := pred.GetWeight()
+		p := math.Min(math.Max(pred.Prediction, 1e-15), 1 - 1e-15)
+		if pred.Label > 0 {
+			ret -= weight * math.Log(p)
+		} else {
+			ret -= weight * math.Log(1 - p)
+		}
+		n += weight
+	}
+	return ret / n
+}
+
 func ErrorRate(predictions []*LabelPrediction) float64 {
 	ret := 0.0
 	n := 0.0
 
 	for _, pred := range predictions {
+		weight := pred.GetWeight()
 		if (float64(pred.Label) - 0.5) * (pred.Prediction - 0.5) < 0 {
-			ret += 1.0
+			ret += weight
 		}
-		n += 1.0
+		n += weight
 	}
 	return ret / n
 }
@@ -54,4 +54,35 @@ func TestErrorRate(t *testing.T) {
 	if math.Abs(error_rate) > 1e-9{
 		t.Error("Error Rate Error")
 	}
-}
\ No newline at end of file
+}
+func TestLogLoss(t *testing.T) {
+	predictions := []*LabelPrediction{&(LabelPrediction{Label: 1, Prediction: 0.8}), &(LabelPrediction{Label: 0, Prediction: 0.4})}
+	expected := -(math.Log(0.8) + math.Log(0.6)) / 2
+	if math.Abs(LogLoss(predictions) - expected) > 1e-12 {
+		t.Errorf("logloss is %f, not %f", LogLoss(predictions), expected)
+	}
+	predictions = []*LabelPrediction{&(LabelPrediction{Label: 1, Prediction: 0.0})}
+	if math.IsInf(LogLoss(predictions), 1) {
+		t.Error("logloss of wrong prediction with probability 1 is infinite")
+	}
+}
+
+func TestWeightedEvaluation(t *testing.T) {
+	// a sample of weight 2 counts as two samples, and a sample of weight 0 does not count
+	weighted := []*LabelPrediction{}
+	duplicated := []*LabelPrediction{}
+	for i := 0; i < 200; i++ {
+		label := rand.Intn(2)
+		prediction := rand.Float64() + 0.3 * float64(label)
+		weight := float64(rand.Intn(3))
+		weighted = append(weighted, &(LabelPrediction{Label: label, Prediction: prediction, Weight: weight, HasWeight: true}))
+		for k := 0; k < int(weight); k++ {
+			duplicated = append(duplicated, &(LabelPrediction{Label: label, Prediction: prediction}))
+		}
+	}
+	for name, metric := range map[string]func([]*LabelPrediction) float64{"auc": AUC, "rmse": RMSE, "error rate": ErrorRate, "logloss": LogLoss} {
+		if math.Abs(metric(weighted) - metric(duplicated)) > 1e-9 {
+			t.Errorf("%s is %f by weights, %f by duplicated predictions", name, metric(weighted), metric(duplicated))
+		}
+	}
+}
@@ -1,13 +1,19 @@
 package hector
 
 import (
+	"errors"
+	"math"
+	"math/rand"
 	"strconv"
+	"strings"
 )
 
 type FactorizeMachine struct {
 	w *Vector
 	v []*Vector
 	params FactorizeMachineParams
+	rng *rand.Rand
+	init_params map[string]string
 }
 
 type FactorizeMachineParams struct {
@@ -16,21 +22,116 @@ type FactorizeMachineParams struct {
 	FactorNumber int
 }
 
-func (self *FactorizeMachine) SaveModel(path string){
-
+/*
+Model file starts with a line of factor number, learning rate and regularization,
+followed by one line per feature : fid, w and the factor values v[0..factors-1]
+*/
+func (self *FactorizeMachine) SaveModel(path string) error {
+	header := NewModelHeader(self, self.init_params, len(self.w.data))
+	if header.Binary() {
+		w := NewBinaryWriter(header.Format)
+		w.Int(self.params.FactorNumber).Float64(self.params.LearningRate).Float64(self.params.Regularization)
+		self.w.WriteBinary(w)
+		for _, vk := range self.v {
+			vk.WriteBinary(w)
+		}
+		return WriteModelFile(path, header, w.Bytes())
+	}
+	sb := StringBuilder{}
+	sb.Int(self.params.FactorNumber)
+	sb.Write("\t")
+	sb.Float(self.params.LearningRate)
+	sb.Write("\t")
+	sb.Float(self.params.Regularization)
+	sb.Write("\n")
+	for f, g := range self.w.data {
+		sb.Int64(f)
+		sb.Write("\t")
+		sb.Float(g)
+		for _, vk := range self.v {
+			sb.Write("\t")
+			sb.Float(vk.GetValue(f))
+		}
+		sb.Write("\n")
+	}
+	return WriteModelFile(path, header, sb.Bytes())
 }
 
-func (self *FactorizeMachine) LoadModel(path string){
-	
-}
+func (self *FactorizeMachine) LoadModel(path string) error {
+	header, body, err := ReadModelBody(path, self)
+	if err != nil {
+		return err
+	}
+	if header.Binary() {
+		r := NewBinaryReader(body, header.Format)
+		self.params.FactorNumber = r.Int()
+		self.params.LearningRate = r.Float64()
+		self.params.Regularization = r.Float64()
+		self.w = NewVector()
+		self.w.ReadBinary(r)
+		self.v = []*Vector{}
+		for i := 0; i < self.params.FactorNumber && r.Err() == nil; i++ {
+			vk := NewVector()
+			vk.ReadBinary(r)
+			self.v = append(self.v, vk)
+		}
+		return r.Err()
+	}
 
-func (c *FactorizeMachine) Predict(sample * Sample) float64 {
-	for _, f := range sample.Features{
-		c.w.RandomInit(f.Id, 0.1)
-		for k, _ := range c.v{
-			c.v[k].RandomInit(f.Id, 0.1)
+	bad := errors.New("bad fm model file " + path)
+	scaner := NewModelScanner(body)
+	if !scaner.Scan() {
+		return bad
+	}
+	tks := strings.Split(scaner.Text(), "\t")
+	if len(tks) != 3 {
+		return bad
+	}
+	factor_number, err := strconv.Atoi(tks[0])
+	if err != nil || factor_number < 0 {
+		return bad
+	}
+	self.params.FactorNumber = factor_number
+	if self.params.LearningRate, err = strconv.ParseFloat(tks[1], 64); err != nil {
+		return bad
+	}
+	if self.params.Regularization, err = strconv.ParseFloat(tks[2], 64); err != nil {
+		return bad
+	}
+	self.w = NewVector()
+	self.v = []*Vector{}
+	for i := 0; i < self.params.FactorNumber; i++{
+		self.v = append(self.v, NewVector())
+	}
+	for scaner.Scan() {
+		if scaner.Text() == "" {
+			continue
+		}
+		tks := strings.Split(scaner.Text(), "\t")
+		if len(tks) != self.params.FactorNumber + 2 {
+			return bad
+		}
+		fid, err := strconv.ParseInt(tks[0], 10, 64)
+		if err != nil {
+			return bad
+		}
+		fw, err := strconv.ParseFloat(tks[1], 64)
+		if err != nil {
+			return bad
+		}
+		self.w.SetValue(fid, fw)
+		for k, vk := range self.v {
+			vkf, err := strconv.ParseFloat(tks[k + 2], 64)
+			if err != nil {
+				return bad
+			}
+			vk.SetValue(fid, vkf)
 		}
 	}
+	return scaner.Err()
+}
+
+func (c *FactorizeMachine) Predict(sample * Sample) float64 {
 	ret := c.w.DotFeatures(sample.Features)
 	for k, _ := range c.v {
 		a := c.v[k].DotFeatures(sample.Features)
@@ -44,16 +145,40 @@ func (c *FactorizeMachine) Predict(sample * Sample) float64 {
 	return Sigmoid(ret)
 }
 
-func (c *FactorizeMachine) Init(params map[string]string) {
+var FactorizeMachineParamSchema = ParamSchema{
+	IntParam("factors", 10, 1, math.Inf(1), "factor number in factorized machine"),
+	PositiveFloatParam("learning-rate", 0.01, math.Inf(1), "learning rate"),
+	FloatParam("regularization", 0.01, 0.0, math.Inf(1), "regularization"),
+	SeedParam,
+}
+
+func init() {
+	RegisterAlgorithm(Algorithm{
+		Name: "fm",
+		Description: "factorization machine",
+		Types: AlgorithmTypeEnum.BINARY,
+		Params: FactorizeMachineParamSchema,
+		New: func() interface{} { return &(FactorizeMachine{}) },
+	})
+}
+
+func (c *FactorizeMachine) Init(params map[string]string) error {
 	c.w = NewVector()
-	factor_number, _ := strconv.ParseInt(params["factors"], 10, 64)
-	c.params.FactorNumber = int(factor_number)
-	c.params.LearningRate, _ = strconv.ParseFloat(params["learning-rate"], 64)
-	c.params.Regularization, _ = strconv.ParseFloat(params["regularization"], 64)
+	values, err := FactorizeMachineParamSchema.Parse(params)
+	if err != nil {
+		return err
+	}
+	c.params.FactorNumber = values.Int("factors")
+	c.params.LearningRate = values.Float("learning-rate")
+	c.params.Regularization = values.Float("regularization")
+	c.rng = NewRand(int64(values.Int("seed")))
 	
+	c.v = []*Vector{}
 	for i := 0; i < c.params.FactorNumber; i++{
 		c.v = append(c.v, NewVector())
 	}
+	c.init_params = params
+	return nil
 }
 
 func (c *FactorizeMachine) Train(dataset * DataSet) {
@@ -63,23 +188,45 @@ func (c *FactorizeMachine) Train(dataset * DataSet) {
 		if n % 10000 == 0{
 			c.params.LearningRate *= 0.9
 		}
-		pred := c.Predict(sample)
-		err := sample.LabelDoubleValue() - pred
-		
-		vx := []float64{}
-		for _, vf := range c.v{
-			vx = append(vx, vf.DotFeatures(sample.Features))
+		c.TrainSample(sample)
+	}
+}
+
+func (c *FactorizeMachine) TrainOnline(dataset * StreamingDataSet) {
+	n := 0
+	for sample := range dataset.Samples {
+		n += 1
+		if n % 10000 == 0{
+			c.params.LearningRate *= 0.9
 		}
-		for _, f := range sample.Features{
-			fweight := c.w.GetValue(f.Id)
-			fweight += c.params.LearningRate * (err * f.Value - c.params.Regularization * fweight)
-			c.w.SetValue(f.Id, fweight)
-			
-			for k,_ := range c.v {
-				vkx := c.v[k].GetValue(f.Id)
-				vkx += c.params.LearningRate * (err * (f.Value * vx[k] - f.Value * f.Value * vkx) - c.params.Regularization * vkx)
-				c.v[k].SetValue(f.Id, vkx)
-			}
+		c.TrainSample(sample)
+	}
+}
+
+func (c *FactorizeMachine) TrainSample(sample * Sample) {
+	for _, f := range sample.Features{
+		c.w.RandomInit(f.Id, 0.1, c.rng)
+		for k, _ := range c.v{
+			c.v[k].RandomInit(f.Id, 0.1, c.rng)
+		}
+	}
+	pred := c.Predict(sample)
+	// gradients of the sample are scaled by its weight
+	err := (sample.LabelDoubleValue() - pred) * sample.GetWeight()
+	
+	vx := []float64{}
+	for _, vf := range c.v{
+		vx = append(vx, vf.DotFeatures(sample.Features))
+	}
+	for _, f := range sample.Features{
+		fweight := c.w.GetValue(f.Id)
+		fweight += c.params.LearningRate * (err * f.Value - c.params.Regularization * fweight)
+		c.w.SetValue(f.Id, fweight)
+		
+		for k,_ := range c.v {
+			vkx := c.v[k].GetValue(f.Id)
+			vkx += c.params.LearningRate * (err * (f.Value * vx[k] - f.Value * f.Value * vkx) - c.params.Regularization * vkx)
+			c.v[k].SetValue(f.Id, vkx)
 		}
 	}
 }
\ No newline at end of file
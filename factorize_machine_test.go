@@ -0,0 +1,61 @@
+package hector
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"testing"
+)
+
+func TestFactorizeMachineSaveLoad(t *testing.T) {
+	train_dataset := LinearDataSet(1000)
+	test_dataset := LinearDataSet(500)
+
+	params := DefaultParams()
+	params["factors"] = "5"
+	params["learning-rate"] = "0.05"
+
+	fm := FactorizeMachine{}
+	err := fm.Init(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fm.Train(train_dataset)
+
+	file, _ := ioutil.TempFile("", "hector-fm")
+	file.Close()
+	defer os.Remove(file.Name())
+	err = fm.SaveModel(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := FactorizeMachine{}
+	err = loaded.Init(DefaultParams())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = loaded.LoadModel(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded.params.FactorNumber != 5 {
+		t.Errorf("factor number is %d after load, expect 5", loaded.params.FactorNumber)
+	}
+	for i, sample := range test_dataset.Samples {
+		p1 := fm.Predict(sample)
+		p2 := loaded.Predict(sample)
+		if math.Abs(p1 - p2) > 1e-9 {
+			t.Fatalf("prediction of sample %d changes from %f to %f after load", i, p1, p2)
+		}
+	}
+
+	// a line of less factor values than the factor number fails instead of panicking
+	for _, body := range []string{"5\t0.05\t0.01\n3\t0.1\t0.2\n", "5\t0.05\n", "5\t0.05\t0.01\n3\t0.1\t0.2\t0.3\t0.4\tx\t0.6\n"} {
+		ioutil.WriteFile(file.Name(), []byte(body), 0644)
+		if loaded.LoadModel(file.Name()) == nil {
+			t.Errorf("bad model file %q is loaded", body)
+		}
+	}
+}
@@ -5,9 +5,13 @@ import(
 	"math"
 )
 
+/*
+WeightLabel is the value (weight) of a feature and the label of a sample, sample_weight is the weight of the sample
+*/
 type WeightLabel struct {
 	weight float64
 	label int
+	sample_weight float64
 }
 
 func (self *WeightLabel) LabelDoubleValue() float64{
@@ -21,6 +25,8 @@ type FeatureLabelDistribution struct {
 type WeightGoal struct {
 	weight float64
 	goal float64
+	hessian float64
+	sample_weight float64
 }
 
 type FeatureGoalDistribution struct {
@@ -39,13 +45,18 @@ func NewFeatureGoalDistribution() *FeatureGoalDistribution{
 	return &ret
 }
 
-func (f *FeatureLabelDistribution) AddWeightLabel(weight float64, label int){
-	wl := WeightLabel{weight:weight, label:label}
+func (f *FeatureLabelDistribution) AddWeightLabel(weight float64, label int, sample_weight float64){
+	wl := WeightLabel{weight:weight, label:label, sample_weight:sample_weight}
 	f.weight_label = append(f.weight_label, wl)
 }
 
-func (f *FeatureGoalDistribution) AddWeightGoal(weight float64, goal float64){
-	wl := WeightGoal{weight:weight, goal:goal}
+func (f *FeatureGoalDistribution) AddWeightGoal(weight float64, goal float64, sample_weight float64){
+	wl := WeightGoal{weight:weight, goal:goal, sample_weight:sample_weight}
+	f.weight_goal = append(f.weight_goal, wl)
+}
+
+func (f *FeatureGoalDistribution) AddWeightGoalHessian(weight float64, goal float64, hessian float64){
+	wl := WeightGoal{weight:weight, goal:goal, hessian:hessian, sample_weight:1.0}
 	f.weight_goal = append(f.weight_goal, wl)
 }
 
@@ -84,7 +95,7 @@ func (f *FeatureLabelDistribution) PositiveCount() int {
 func (f *FeatureLabelDistribution) LabelDistribution() *ArrayVector {
 	ret := NewArrayVector()
 	for _, e := range f.weight_label {
-		ret.AddValue(e.label, 1.0)
+		ret.AddValue(e.label, e.sample_weight)
 	}
 	return ret
 }
@@ -110,15 +121,45 @@ func (f *FeatureGoalDistribution) BestSplitByVariance(sum_left, sum_left2, count
 			}	
 		}
 		prev_weight = wl.weight
+		sum_left += wl.sample_weight * wl.goal
+		sum_left2 += wl.sample_weight * wl.goal * wl.goal
+		count_left += wl.sample_weight
+
+		sum_right -= wl.sample_weight * wl.goal
+		sum_right2 -= wl.sample_weight * wl.goal * wl.goal
+		count_right -= wl.sample_weight
+	}
+	return split, min_vari
+}
+
+/*
+BestSplitByObjective finds the split of largest gain of objective, goals are negative gradients.
+It returns false if no split can be made
+*/
+func (f *FeatureGoalDistribution) BestSplitByObjective(objective *TreeObjective, sum_left, hess_left, count_left, sum_right, hess_right, count_right float64) (float64, float64, bool) {
+	max_gain := 0.0
+	split := f.weight_goal[0].weight - 1.0
+	found := false
+	prev_weight := f.weight_goal[0].weight - 1.0
+	for _, wl := range f.weight_goal{
+		if prev_weight != wl.weight && count_left > 0 && count_right > 0 {
+			gain, ok := objective.Gain(-sum_left, hess_left, -sum_right, hess_right)
+			if ok && (!found || gain > max_gain) {
+				max_gain = gain
+				split = wl.weight
+				found = true
+			}
+		}
+		prev_weight = wl.weight
 		sum_left += wl.goal
-		sum_left2 += wl.goal * wl.goal
+		hess_left += wl.hessian
 		count_left += 1.0
 
 		sum_right -= wl.goal
-		sum_right2 -= wl.goal * wl.goal
+		hess_right -= wl.hessian
 		count_right -= 1.0
 	}
-	return split, min_vari
+	return split, max_gain, found
 }
 
 /*
@@ -155,6 +196,28 @@ func Gini(left_dis, right_dis *ArrayVector) float64 {
 	return (left_sum * left_gini + right_sum * right_gini) / (left_sum + right_sum)
 }
 
+/*
+GiniGain is the decrease of gini impurity, weighted by sample counts, of splitting samples of label
+distribution left_dis + right_dis into left_dis and right_dis
+*/
+func GiniGain(left_dis, right_dis *ArrayVector) float64 {
+	parent_dis := left_dis.Copy()
+	parent_dis.AddVector(right_dis, 1.0)
+	return weightedImpurity(parent_dis) - weightedImpurity(left_dis) - weightedImpurity(right_dis)
+}
+
+func weightedImpurity(dis *ArrayVector) float64 {
+	sum := dis.Sum()
+	if sum == 0.0 {
+		return 0.0
+	}
+	impurity := 1.0
+	for _, p := range dis.data {
+		impurity -= (p / sum) * (p / sum)
+	}
+	return sum * impurity
+}
+
 /*
 func (f *FeatureLabelDistribution) BestSplitByGini(total, positive int) (float64, float64) {
 	pright := float64(f.PositiveCount())
@@ -182,11 +245,27 @@ func (f *FeatureLabelDistribution) BestSplitByGini(total, positive int) (float64
 }
 */
 
+/*
+BestSplitByGini finds the split of least gini, samples without the feature are taken as smaller than all values
+*/
 func (self *FeatureLabelDistribution) BestSplitByGini(total_dis *ArrayVector) (float64, float64) {
 	left_dis := total_dis.Copy()
 	right_dis := self.LabelDistribution()
 	left_dis.AddVector(right_dis, -1.0)
+	return self.bestSplitByGini(left_dis, right_dis)
+}
 
+/*
+BestSplitByGiniMissingHigh is BestSplitByGini with samples without the feature taken as larger than all values
+*/
+func (self *FeatureLabelDistribution) BestSplitByGiniMissingHigh(total_dis *ArrayVector) (float64, float64) {
+	return self.bestSplitByGini(NewArrayVector(), total_dis.Copy())
+}
+
+/*
+bestSplitByGini moves samples from right_dis to left_dis in order of values
+*/
+func (self *FeatureLabelDistribution) bestSplitByGini(left_dis, right_dis *ArrayVector) (float64, float64) {
 	min_gini := Gini(left_dis, right_dis)
 	split := self. weight_label[0].weight
 	prev_weight := self.weight_label[0].weight
@@ -199,18 +278,26 @@ func (self *FeatureLabelDistribution) BestSplitByGini(total_dis *ArrayVector) (f
 			}
 		}
 		prev_weight = wl.weight
-		left_dis.AddValue(wl.label, 1.0)
-		right_dis.AddValue(wl.label, -1.0)
+		left_dis.AddValue(wl.label, wl.sample_weight)
+		right_dis.AddValue(wl.label, -wl.sample_weight)
 	}
 	return split, min_gini
 }
 
-func (f *FeatureLabelDistribution) InformationValue(global_total, global_positive int) float64 {
-	with_total := len(f.weight_label)
-	with_positive := f.PositiveCount()
+/*
+InformationValue of the feature, global_total and global_positive are weights of all samples and of positive samples.
+Values are put into quantile buckets by rank, and samples count by their weights in buckets
+*/
+func (f *FeatureLabelDistribution) InformationValue(global_total, global_positive float64) float64 {
+	with_total := 0.0
+	with_positive := 0.0
+	for _, e := range f.weight_label {
+		with_total += e.sample_weight
+		with_positive += float64(e.label) * e.sample_weight
+	}
 	
-	positives := []int{}
-	negatives := []int{}
+	positives := []float64{}
+	negatives := []float64{}
 	
 	positives = append(positives, global_positive - with_positive)
 	negatives = append(negatives, (global_total - global_positive) - (with_total - with_positive))
@@ -218,29 +305,29 @@ func (f *FeatureLabelDistribution) InformationValue(global_total, global_positiv
 	sort.Sort(f)
 	
 	prev_c := -1
-	pos := 0
-	total := 0
+	pos := 0.0
+	total := 0.0
 	for i, e := range f.weight_label {
-		c := int(200.0 * float64(i) / float64(with_total))
+		c := int(200.0 * float64(i) / float64(len(f.weight_label)))
 		if c != prev_c {
 			if total > 0{
 				positives = append(positives, pos)
 				negatives = append(negatives, total - pos)
-				pos = 0
-				total = 0
+				pos = 0.0
+				total = 0.0
 			}	
 		}
 		prev_c = c
-		pos += int(e.label)
-		total += 1
+		pos += float64(e.label) * e.sample_weight
+		total += e.sample_weight
 	}
 	if total > 0{
 		positives = append(positives, pos)
 		negatives = append(negatives, total - pos)
 	}
 	
-	sum_positive := 0
-	sum_negative := 0
+	sum_positive := 0.0
+	sum_negative := 0.0
 	for _, v := range positives{
 		sum_positive += v
 	}
@@ -249,8 +336,8 @@ func (f *FeatureLabelDistribution) InformationValue(global_total, global_positiv
 	}
 	iv := 0.0
 	for i := range positives{
-		positive_ratio := float64(positives[i]) / float64(sum_positive)
-		negative_ratio := float64(negatives[i]) / float64(sum_negative)
+		positive_ratio := positives[i] / sum_positive
+		negative_ratio := negatives[i] / sum_negative
 		iv += (positive_ratio - negative_ratio) * math.Log((0.00001 + positive_ratio) / (0.00001 + negative_ratio))
 	}
 	return iv
@@ -258,17 +345,17 @@ func (f *FeatureLabelDistribution) InformationValue(global_total, global_positiv
 
 func InformationValue(dataset *DataSet) map[int64]float64 {
 	feature_weight_labels := make(map[int64]*FeatureLabelDistribution)
-	total := 0
-	positive := 0
+	total := 0.0
+	positive := 0.0
 	for _,sample := range dataset.Samples {
-		total += 1
-		positive += int(sample.Label)
+		total += sample.GetWeight()
+		positive += float64(sample.Label) * sample.GetWeight()
 		for _, feature := range sample.Features {
 			_, ok := feature_weight_labels[feature.Id]
 			if !ok {
 				feature_weight_labels[feature.Id] = NewFeatureLabelDistribution()
 			}
-			feature_weight_labels[feature.Id].AddWeightLabel(feature.Value, sample.Label)
+			feature_weight_labels[feature.Id].AddWeightLabel(feature.Value, sample.Label, sample.GetWeight())
 		}
 	}
 	
@@ -0,0 +1,140 @@
+package hector
+
+import (
+	"sort"
+)
+
+/*
+FeatureBins maps values of each feature to at most MaxBins bins by quantiles. Thresholds of a
+feature are lower bounds of its bins, so they are split values of trees : values go left if they
+are not less than the threshold of their bin. Samples keep bins of features of samples in the dataset
+*/
+type FeatureBins struct {
+	MaxBins int
+	Thresholds map[int64][]float64
+	Samples [][]BinnedFeature
+}
+
+type BinnedFeature struct {
+	Id int64
+	Bin int
+}
+
+func NewFeatureBins(dataset *DataSet, max_bins int) *FeatureBins {
+	ret := FeatureBins{MaxBins: max_bins, Thresholds: make(map[int64][]float64)}
+	values := make(map[int64][]float64)
+	for _, sample := range dataset.Samples {
+		for _, feature := range sample.Features {
+			values[feature.Id] = append(values[feature.Id], feature.Value)
+		}
+	}
+	for fid, fvalues := range values {
+		sort.Float64s(fvalues)
+		thresholds := []float64{}
+		for i := 0; i < max_bins; i++ {
+			value := fvalues[i * len(fvalues) / max_bins]
+			if len(thresholds) == 0 || thresholds[len(thresholds) - 1] < value {
+				thresholds = append(thresholds, value)
+			}
+		}
+		ret.Thresholds[fid] = thresholds
+	}
+	ret.Samples = make([][]BinnedFeature, len(dataset.Samples))
+	for i, sample := range dataset.Samples {
+		ret.Samples[i] = make([]BinnedFeature, len(sample.Features))
+		for j, feature := range sample.Features {
+			ret.Samples[i][j] = BinnedFeature{Id: feature.Id, Bin: ret.Bin(feature.Id, feature.Value)}
+		}
+	}
+	return &ret
+}
+
+/*
+Bin returns the bin of a value, values less than all thresholds are in bin 0
+*/
+func (b *FeatureBins) Bin(fid int64, value float64) int {
+	thresholds := b.Thresholds[fid]
+	k := sort.Search(len(thresholds), func(i int) bool { return thresholds[i] > value }) - 1
+	if k < 0 {
+		return 0
+	}
+	return k
+}
+
+/*
+FeatureBins returns bins of the dataset, they are computed once and kept until samples change
+*/
+func (d *DataSet) FeatureBins(max_bins int) *FeatureBins {
+	if d.bins == nil || d.bins.MaxBins != max_bins || len(d.bins.Samples) != len(d.Samples) {
+		d.bins = NewFeatureBins(d, max_bins)
+	}
+	return d.bins
+}
+
+/*
+Histogram keeps width statistics per bin of each feature, e.g. sum and count of goals of samples.
+Histogram of a child node can be got by subtracting the histogram of its sibling from its parent
+*/
+type Histogram struct {
+	width int
+	features map[int64][]float64
+}
+
+func NewHistogram(width int) *Histogram {
+	return &(Histogram{width: width, features: make(map[int64][]float64)})
+}
+
+func (h *Histogram) Add(bins *FeatureBins, k int, stats ...float64) {
+	for _, feature := range bins.Samples[k] {
+		values, ok := h.features[feature.Id]
+		if !ok {
+			values = make([]float64, len(bins.Thresholds[feature.Id]) * h.width)
+			h.features[feature.Id] = values
+		}
+		for i, stat := range stats {
+			values[feature.Bin * h.width + i] += stat
+		}
+	}
+}
+
+/*
+Subtract subtracts other from h, other must be built from a subset of samples of h
+*/
+func (h *Histogram) Subtract(other *Histogram) {
+	for fid, other_values := range other.features {
+		values := h.features[fid]
+		for i, value := range other_values {
+			values[i] -= value
+		}
+	}
+}
+
+/*
+Stats returns statistics of bin of feature fid
+*/
+func (h *Histogram) Stats(fid int64, bin int) []float64 {
+	values := h.features[fid]
+	return values[bin * h.width : (bin + 1) * h.width]
+}
+
+func (h *Histogram) Bins(fid int64) int {
+	return len(h.features[fid]) / h.width
+}
+
+/*
+setChildHistograms builds the histogram of the smaller child of node by build, and gets the histogram
+of the other child by subtracting it from the histogram of node. Nothing is done if node has no histogram
+*/
+func setChildHistograms(node, left, right *TreeNode, build func(node *TreeNode) *Histogram) {
+	if node.histogram == nil {
+		return
+	}
+	small, large := left, right
+	if len(left.samples) > len(right.samples) {
+		small, large = right, left
+	}
+	small.histogram = build(small)
+	node.histogram.Subtract(small.histogram)
+	large.histogram = node.histogram
+	node.histogram = nil
+}
@@ -0,0 +1,119 @@
+package hector
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// labels of samples in the circle of radius 0.4 at (0.5, 0.5) are 1
+func circleDataSet(n int) *DataSet {
+	ret := NewDataSet()
+	for i := 0; i < n; i++ {
+		x := rand.Float64()
+		y := rand.Float64()
+		sample := NewSample()
+		if (x - 0.5) * (x - 0.5) + (y - 0.5) * (y - 0.5) < 0.16 {
+			sample.Label = 1
+		}
+		sample.AddFeature(Feature{Id: 1, Value: x})
+		sample.AddFeature(Feature{Id: 2, Value: y})
+		ret.AddSample(sample)
+	}
+	return ret
+}
+
+func TestFeatureBins(t *testing.T) {
+	dataset := circleDataSet(1000)
+	bins := dataset.FeatureBins(16)
+	if bins != dataset.FeatureBins(16) {
+		t.Error("bins are computed again for the same dataset")
+	}
+	for fid, thresholds := range bins.Thresholds {
+		if len(thresholds) > 16 {
+			t.Errorf("feature %d has %d bins", fid, len(thresholds))
+		}
+	}
+	for i, sample := range dataset.Samples {
+		for j, feature := range sample.Features {
+			thresholds := bins.Thresholds[feature.Id]
+			b := bins.Samples[i][j].Bin
+			if feature.Value < thresholds[b] || (b + 1 < len(thresholds) && feature.Value >= thresholds[b + 1]) {
+				t.Fatalf("value %f of feature %d is put in bin %d", feature.Value, feature.Id, b)
+			}
+		}
+	}
+	dataset.AddSample(NewSample())
+	if bins == dataset.FeatureBins(16) {
+		t.Error("bins are not computed again after samples change")
+	}
+}
+
+func TestHistogramSplitOfRegressionTree(t *testing.T) {
+	// goals are continuous and the dataset is seeded, so splits do not tie
+	rng := rand.New(rand.NewSource(1))
+	dataset := NewDataSet()
+	for i := 0; i < 500; i++ {
+		x, y := rng.Float64(), rng.Float64()
+		sample := NewSample()
+		sample.AddFeature(Feature{Id: 1, Value: x})
+		sample.AddFeature(Feature{Id: 2, Value: y})
+		sample.Prediction = x + y + rng.NormFloat64()
+		dataset.AddSample(sample)
+	}
+	params := DefaultParams()
+	params["max-depth"] = "6"
+	params["min-leaf-size"] = "5"
+
+	// with more bins than values, histogram finds the same splits as sorting values
+	exact := RegressionTree{}
+	exact.Init(params)
+	exact.Train(dataset)
+	params["max-bins"] = "1000"
+	binned := RegressionTree{}
+	binned.Init(params)
+	binned.Train(dataset)
+	if exact.tree.Size() != binned.tree.Size() {
+		t.Fatalf("tree has %d nodes with histogram, %d without", binned.tree.Size(), exact.tree.Size())
+	}
+	for i, node := range exact.tree.nodes {
+		if node.feature_split != binned.tree.nodes[i].feature_split {
+			t.Fatalf("split of node %d is %v with histogram, %v without", i, binned.tree.nodes[i].feature_split, node.feature_split)
+		}
+	}
+
+	train_dataset := circleDataSet(500)
+	test_dataset := circleDataSet(500)
+	params["tree-count"] = "10"
+	params["learning-rate"] = "0.3"
+	params["max-bins"] = "16"
+	c := GBDT{}
+	c.Init(params)
+	auc, _ := AlgorithmRunOnDataSet(&c, train_dataset, test_dataset, "", params)
+	t.Logf("auc of gbdt with 16 bins in circle dataset is %f", auc)
+	if auc < 0.9 {
+		t.Error("auc of gbdt with 16 bins is less than 0.9 in circle dataset")
+	}
+}
+
+func TestHistogramSplitOfCART(t *testing.T) {
+	params := DefaultParams()
+	params["max-depth"] = "8"
+	params["min-leaf-size"] = "5"
+	params["tree-count"] = "20"
+	params["feature-count"] = "1.0"
+	params["max-bins"] = "16"
+	for _, algo := range []string{"cart", "rf"} {
+		train_dataset := circleDataSet(1000)
+		test_dataset := circleDataSet(500)
+		classifier, _ := GetClassifier(algo)
+		err := classifier.Init(params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		auc, _ := AlgorithmRunOnDataSet(classifier, train_dataset, test_dataset, "", params)
+		t.Logf("auc of %s with 16 bins in circle dataset is %f", algo, auc)
+		if auc < 0.9 {
+			t.Errorf("auc of %s with 16 bins is less than 0.9 in circle dataset", algo)
+		}
+	}
+}
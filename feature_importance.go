@@ -0,0 +1,214 @@
+package hector
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"strconv"
+)
+
+var ImportanceTypeEnum = struct {
+	SPLIT, GAIN, PERMUTATION string
+}{"split", "gain", "permutation"}
+
+/*
+TreeModel is a model of trees, e.g. cart, rf, rdt and gbdt. Importance of features can be computed from their splits
+*/
+type TreeModel interface {
+	Trees() []*Tree
+}
+
+/*
+FeatureImportance is a row of importance tables, which are sorted by importance
+*/
+type FeatureImportance struct {
+	Id int64
+	Importance float64
+}
+
+/*
+SplitImportance counts splits of each feature in trees
+*/
+func SplitImportance(trees []*Tree) map[int64]float64 {
+	ret := make(map[int64]float64)
+	for _, tree := range trees {
+		for _, node := range tree.nodes {
+			if node.isSplit() {
+				ret[node.feature_split.Id] += 1.0
+			}
+		}
+	}
+	return ret
+}
+
+/*
+GainImportance sums gains of splits of each feature in trees. Gains are decreases of gini impurity of cart,
+rf and rdt, and of squared error or second-order objective of gbdt. Trees saved by format version 6 or
+older have no gains
+*/
+func GainImportance(trees []*Tree) map[int64]float64 {
+	ret := make(map[int64]float64)
+	for _, tree := range trees {
+		for _, node := range tree.nodes {
+			if node.isSplit() {
+				ret[node.feature_split.Id] += node.gain
+			}
+		}
+	}
+	return ret
+}
+
+/*
+PermutationImportance is the decrease of AUC of classifier on dataset after values of a feature are
+shuffled among samples, samples without the feature are shuffled as well. It works for any classifier
+*/
+func PermutationImportance(classifier Classifier, dataset *DataSet, rng *rand.Rand) map[int64]float64 {
+	auc := func(samples []*Sample) float64 {
+		predictions := []*LabelPrediction{}
+		for _, sample := range samples {
+			predictions = append(predictions, &(LabelPrediction{Label: sample.Label, Prediction: classifier.Predict(sample), Weight: sample.Weight, HasWeight: true}))
+		}
+		return AUC(predictions)
+	}
+	baseline := auc(dataset.Samples)
+
+	features := []int64{}
+	seen := make(map[int64]bool)
+	for _, sample := range dataset.Samples {
+		for _, feature := range sample.Features {
+			if !seen[feature.Id] {
+				seen[feature.Id] = true
+				features = append(features, feature.Id)
+			}
+		}
+	}
+	// features are sorted, so importance is reproducible by rng
+	sort.Slice(features, func(i, j int) bool { return features[i] < features[j] })
+
+	n := len(dataset.Samples)
+	ret := make(map[int64]float64)
+	values := make([]float64, n)
+	present := make([]bool, n)
+	permuted := make([]*Sample, n)
+	for _, fid := range features {
+		for i, sample := range dataset.Samples {
+			present[i] = false
+			for _, feature := range sample.Features {
+				if feature.Id == fid {
+					values[i] = feature.Value
+					present[i] = true
+				}
+			}
+		}
+		order := rng.Perm(n)
+		for i, sample := range dataset.Samples {
+			permuted_sample := Sample{Label: sample.Label, Weight: sample.Weight, Features: make([]Feature, 0, len(sample.Features) + 1)}
+			for _, feature := range sample.Features {
+				if feature.Id != fid {
+					permuted_sample.Features = append(permuted_sample.Features, feature)
+				}
+			}
+			if present[order[i]] {
+				permuted_sample.Features = append(permuted_sample.Features, Feature{Id: fid, Value: values[order[i]]})
+			}
+			permuted[i] = &permuted_sample
+		}
+		ret[fid] = baseline - auc(permuted)
+	}
+	return ret
+}
+
+/*
+ModelFeatureImportance computes importance of type importance_type of features of model. Split and gain
+importance need a TreeModel, permutation importance needs a Classifier and a dataset
+*/
+func ModelFeatureImportance(model interface{}, importance_type string, dataset *DataSet, rng *rand.Rand) (map[int64]float64, error) {
+	switch importance_type {
+	case ImportanceTypeEnum.SPLIT, ImportanceTypeEnum.GAIN:
+		tree_model, ok := model.(TreeModel)
+		if !ok {
+			return nil, errors.New(importance_type + " importance needs a model of trees")
+		}
+		if importance_type == ImportanceTypeEnum.SPLIT {
+			return SplitImportance(tree_model.Trees()), nil
+		}
+		return GainImportance(tree_model.Trees()), nil
+	case ImportanceTypeEnum.PERMUTATION:
+		classifier, ok := model.(Classifier)
+		if !ok || dataset == nil {
+			return nil, errors.New("permutation importance needs a classifier and a dataset")
+		}
+		return PermutationImportance(classifier, dataset, rng), nil
+	}
+	return nil, errors.New("unknown importance type " + importance_type)
+}
+
+/*
+SortFeatureImportance turns importance into a table sorted by decreasing importance, ties are sorted by
+feature id. It also sorts results of InformationValue
+*/
+func SortFeatureImportance(importance map[int64]float64) []FeatureImportance {
+	ret := []FeatureImportance{}
+	for fid, value := range importance {
+		ret = append(ret, FeatureImportance{Id: fid, Importance: value})
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Importance > ret[j].Importance || (ret[i].Importance == ret[j].Importance && ret[i].Id < ret[j].Id)
+	})
+	return ret
+}
+
+/*
+WriteFeatureImportance writes a line of feature id and importance, separated by tab, for each row
+*/
+func WriteFeatureImportance(w io.Writer, table []FeatureImportance) error {
+	for _, row := range table {
+		_, err := fmt.Fprintf(w, "%d\t%s\n", row.Id, strconv.FormatFloat(row.Importance, 'g', -1, 64))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+AlgorithmImportance loads the model of params and computes importance of its features by importance-type.
+Permutation importance is computed on the test file
+*/
+func AlgorithmImportance(classifier Classifier, test_path string, params map[string]string) ([]FeatureImportance, error) {
+	global, _ := strconv.ParseInt(params["global"], 10, 64)
+	model_path, _ := params["model"]
+	if model_path == "" {
+		return nil, errors.New("feature importance needs a model file")
+	}
+	err := classifier.Init(params)
+	if err != nil {
+		return nil, err
+	}
+	err = classifier.LoadModel(model_path)
+	if err != nil {
+		return nil, err
+	}
+	header, err := ReadModelHeader(model_path)
+	if err == nil {
+		global = header.Global
+	}
+
+	importance_type := params["importance-type"]
+	var dataset *DataSet
+	if importance_type == ImportanceTypeEnum.PERMUTATION {
+		dataset = NewDataSet()
+		err = dataset.Load(test_path, global)
+		if err != nil {
+			return nil, err
+		}
+	}
+	seed, _ := strconv.ParseInt(params["seed"], 10, 64)
+	importance, err := ModelFeatureImportance(classifier, importance_type, dataset, NewRand(seed))
+	if err != nil {
+		return nil, err
+	}
+	return SortFeatureImportance(importance), nil
+}
@@ -0,0 +1,88 @@
+package hector
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"testing"
+)
+
+// labels of samples are 1 if feature 1 is not less than 0.5, feature 2 is noise
+func importanceDataSet(n int) *DataSet {
+	ret := NewDataSet()
+	for i := 0; i < n; i++ {
+		x := rand.Float64()
+		sample := NewSample()
+		if x >= 0.5 {
+			sample.Label = 1
+		}
+		sample.AddFeature(Feature{Id: 1, Value: x})
+		sample.AddFeature(Feature{Id: 2, Value: rand.Float64()})
+		ret.AddSample(sample)
+	}
+	return ret
+}
+
+func TestFeatureImportance(t *testing.T) {
+	test_dataset := importanceDataSet(500)
+	params := DefaultParams()
+	params["max-depth"] = "4"
+	params["min-leaf-size"] = "5"
+	params["tree-count"] = "10"
+	params["learning-rate"] = "0.3"
+	for _, method := range []string{"cart", "rf", "rdt", "gbdt"} {
+		classifier, _ := GetClassifier(method)
+		classifier.Init(params)
+		// rdt releases samples of its train dataset
+		classifier.Train(importanceDataSet(1000))
+		for _, importance_type := range []string{ImportanceTypeEnum.GAIN, ImportanceTypeEnum.PERMUTATION} {
+			importance, err := ModelFeatureImportance(classifier, importance_type, test_dataset, rand.New(rand.NewSource(1)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			table := SortFeatureImportance(importance)
+			t.Logf("%s importance of %s is %v", importance_type, method, table)
+			if len(table) == 0 || table[0].Id != 1 {
+				t.Errorf("feature 1 is not the most important by %s importance of %s", importance_type, method)
+			}
+		}
+	}
+
+	cart := CART{}
+	cart.Init(params)
+	cart.Train(importanceDataSet(1000))
+	split := SplitImportance(cart.Trees())
+	count := 0
+	for _, node := range cart.tree.nodes {
+		if node.isSplit() {
+			count++
+		}
+	}
+	if int(split[1] + split[2]) != count {
+		t.Errorf("split importance counts %v splits of %d", split, count)
+	}
+
+	file, _ := ioutil.TempFile("", "hector-cart")
+	file.Close()
+	defer os.Remove(file.Name())
+	gain := GainImportance(cart.Trees())
+	for _, format := range []string{MODEL_FORMAT_TEXT, MODEL_FORMAT_BINARY} {
+		cart.init_params["model-format"] = format
+		cart.SaveModel(file.Name())
+		loaded := CART{}
+		err := loaded.LoadModel(file.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		loaded_gain := GainImportance(loaded.Trees())
+		for fid, value := range gain {
+			if loaded_gain[fid] != value {
+				t.Errorf("gain importance of feature %d changes from %f to %f after load in %s format", fid, value, loaded_gain[fid], format)
+			}
+		}
+	}
+
+	if _, err := ModelFeatureImportance(&(LogisticRegression{}), ImportanceTypeEnum.GAIN, nil, nil); err == nil {
+		t.Error("gain importance is computed for a model without trees")
+	}
+}
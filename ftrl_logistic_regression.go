@@ -3,8 +3,6 @@ package hector
 import (
 	"math"
 	"strconv"
-	"os"
-	"bufio"
 	"strings"
 )
 
@@ -29,9 +27,19 @@ func (w *FTRLFeatureWeight) Wi(p FTRLLogisticRegressionParams) float64 {
 type FTRLLogisticRegression struct {
 	Model map[int64]FTRLFeatureWeight
 	Params FTRLLogisticRegressionParams
+	init_params map[string]string
 }
 
-func (algo *FTRLLogisticRegression) SaveModel(path string) {
+func (algo *FTRLLogisticRegression) SaveModel(path string) error {
+	header := NewModelHeader(algo, algo.init_params, len(algo.Model))
+	if header.Binary() {
+		w := NewBinaryWriter(header.Format)
+		w.Int(len(algo.Model))
+		for f, g := range algo.Model {
+			w.Varint(f).Float(g.ni).Float(g.zi)
+		}
+		return WriteModelFile(path, header, w.Bytes())
+	}
 	sb := StringBuilder{}
 	for f, g := range algo.Model {
 		sb.Int64(f)
@@ -41,14 +49,25 @@ func (algo *FTRLLogisticRegression) SaveModel(path string) {
 		sb.Float(g.zi)
 		sb.Write("\n")
 	}
-	sb.WriteToFile(path)
+	return WriteModelFile(path, header, sb.Bytes())
 }
 
-func (algo *FTRLLogisticRegression) LoadModel(path string) {
-	file, _ := os.Open(path)
-	defer file.Close()
+func (algo *FTRLLogisticRegression) LoadModel(path string) error {
+	header, body, err := ReadModelBody(path, algo)
+	if err != nil {
+		return err
+	}
+	if header.Binary() {
+		r := NewBinaryReader(body, header.Format)
+		n := r.Len()
+		for i := 0; i < n; i++ {
+			fid := r.Varint()
+			algo.Model[fid] = FTRLFeatureWeight{ni: r.Float(), zi: r.Float()}
+		}
+		return r.Err()
+	}
 
-	scaner := bufio.NewScanner(file)
+	scaner := NewModelScanner(body)
 	for scaner.Scan() {
 		line := scaner.Text()
 		tks := strings.Split(line, "\t")
@@ -58,6 +77,7 @@ func (algo *FTRLLogisticRegression) LoadModel(path string) {
 		g := FTRLFeatureWeight{ni: ni, zi: zi}
 		algo.Model[fid] = g
 	}
+	return nil
 }
 
 func (algo *FTRLLogisticRegression) Predict(sample * Sample) float64 {
@@ -71,14 +91,37 @@ func (algo *FTRLLogisticRegression) Predict(sample * Sample) float64 {
 	return Sigmoid(ret)
 }
 
-func (algo *FTRLLogisticRegression) Init(params map[string]string) {
+var FTRLLogisticRegressionParamSchema = ParamSchema{
+	PositiveFloatParam("alpha", 0.1, math.Inf(1), "alpha of ftrl"),
+	BetaParam,
+	FloatParam("lambda1", 0.1, 0.0, math.Inf(1), "lambda1 of ftrl"),
+	FloatParam("lambda2", 0.1, 0.0, math.Inf(1), "lambda2 of ftrl"),
+	IntParam("steps", 1, 1, math.Inf(1), "steps before convergent"),
+}
+
+func init() {
+	RegisterAlgorithm(Algorithm{
+		Name: "ftrl",
+		Description: "FTRL-proximal logistic regression with L1 regularization",
+		Types: AlgorithmTypeEnum.BINARY,
+		Params: FTRLLogisticRegressionParamSchema,
+		New: func() interface{} { return &(FTRLLogisticRegression{}) },
+	})
+}
+
+func (algo *FTRLLogisticRegression) Init(params map[string]string) error {
 	algo.Model = make(map[int64]FTRLFeatureWeight)
-	algo.Params.Alpha, _ = strconv.ParseFloat(params["alpha"], 64)
-	algo.Params.Lambda1, _ = strconv.ParseFloat(params["lambda1"], 64)
-	algo.Params.Lambda2, _ = strconv.ParseFloat(params["lambda2"], 64)
-	algo.Params.Beta, _ = strconv.ParseFloat(params["beta"], 64)
-	steps, _ := strconv.ParseInt(params["steps"], 10, 32)
-	algo.Params.Steps = int(steps)
+	values, err := FTRLLogisticRegressionParamSchema.Parse(params)
+	if err != nil {
+		return err
+	}
+	algo.Params.Alpha = values.Float("alpha")
+	algo.Params.Lambda1 = values.Float("lambda1")
+	algo.Params.Lambda2 = values.Float("lambda2")
+	algo.Params.Beta = values.Float("beta")
+	algo.Params.Steps = values.Int("steps")
+	algo.init_params = params
+	return nil
 }
 
 func (algo *FTRLLogisticRegression) Clear(){
@@ -89,22 +132,33 @@ func (algo *FTRLLogisticRegression) Clear(){
 func (algo *FTRLLogisticRegression) Train(dataset * DataSet) {
 	for step := 0; step < algo.Params.Steps; step++ {
 		for _,sample := range dataset.Samples {
-			prediction := algo.Predict(sample)
-			err := sample.LabelDoubleValue() - prediction
-			for _, feature := range sample.Features {
-				model_feature_value, ok := algo.Model[feature.Id]
-				if !ok {
-					model_feature_value = FTRLFeatureWeight{0.0, 0.0}
-				}
-				zi := model_feature_value.zi
-				ni := model_feature_value.ni
-				gi := -1 * err * feature.Value
-				sigma := (math.Sqrt(ni + gi * gi) - math.Sqrt(ni)) / algo.Params.Alpha
-				wi := model_feature_value.Wi(algo.Params)
-				zi += gi - sigma * wi
-				ni += gi * gi
-				algo.Model[feature.Id] = FTRLFeatureWeight{zi: zi, ni: ni}
-			}
+			algo.TrainSample(sample)
+		}
+	}
+}
+
+func (algo *FTRLLogisticRegression) TrainOnline(dataset * StreamingDataSet) {
+	for sample := range dataset.Samples {
+		algo.TrainSample(sample)
+	}
+}
+
+func (algo *FTRLLogisticRegression) TrainSample(sample * Sample) {
+	prediction := algo.Predict(sample)
+	// gradients of the sample are scaled by its weight
+	err := (sample.LabelDoubleValue() - prediction) * sample.GetWeight()
+	for _, feature := range sample.Features {
+		model_feature_value, ok := algo.Model[feature.Id]
+		if !ok {
+			model_feature_value = FTRLFeatureWeight{0.0, 0.0}
 		}
+		zi := model_feature_value.zi
+		ni := model_feature_value.ni
+		gi := -1 * err * feature.Value
+		sigma := (math.Sqrt(ni + gi * gi) - math.Sqrt(ni)) / algo.Params.Alpha
+		wi := model_feature_value.Wi(algo.Params)
+		zi += gi - sigma * wi
+		ni += gi * gi
+		algo.Model[feature.Id] = FTRLFeatureWeight{zi: zi, ni: ni}
 	}
 }
\ No newline at end of file
@@ -1,91 +1,747 @@
 package hector
 
 import (
-	"strconv"
+	"context"
 	"math"
+	"math/rand"
 	"fmt"
-	"os"
-	"bufio"
+	"sort"
+	"strconv"
+	"strings"
+	"errors"
 )
 
+/*
+GBDT predicts Output(prior + shrink * sum of tree values) of its loss. Trees are fitted to
+negative gradients of the loss, and their leaves take the values which minimize the loss.
+If labels are larger than 1 and loss is logistic, GBDT is trained by softmax loss : each round
+grows one tree per label, and label k is scored by priors[k] plus trees of label k.
+With a validation dataset and early-stopping-rounds, training stops when the validation metric
+has not improved for early-stopping-rounds rounds, and prediction uses the best rounds.
+Each tree can be trained on subsample of samples and colsample-bytree of features, and each level
+of trees splits by colsample-bylevel of features of the tree. Sampling is reproducible by seed.
+If second-order is 1, trees split by the regularized objective of gradients and hessians of the loss,
+and leaves take its values, see TreeObjective
+*/
 type GBDT struct {
 	dts []*RegressionTree
+	tree_labels []int
 	tree_count int
 	shrink float64
+	loss_name string
+	loss GBDTLoss
+	priors []float64
+	huber_delta float64
+	validation *DataSet
+	eval_metric string
+	early_stopping_rounds int
+	best_rounds int
+	predict_rounds int
+	subsample float64
+	colsample_bytree float64
+	colsample_bylevel float64
+	seed int64
+	rng *rand.Rand
+	features []int64
+	objective *TreeObjective
+	init_params map[string]string
 }
 
-func (self *GBDT) SaveModel(path string){
-	file, _ := os.Create(path)
-	defer file.Close()
+const GBDT_SOFTMAX_LOSS = "softmax"
+
+/*
+Model file starts with loss and priors, and best rounds of early stopping since format version 4,
+followed by trees. Trees of softmax loss are labeled. Files of format version 2 or older only have
+trees, they are loaded with squared loss and zero prior, which is what they were trained by
+*/
+func (self *GBDT) SaveModel(path string) error {
+	trees := []*Tree{}
 	for _, dt := range self.dts {
-		buf := dt.tree.ToString()
-		file.Write(buf)
-		file.WriteString("\n#\n")
+		trees = append(trees, &(dt.tree))
 	}
+	header := NewModelHeader(self, self.init_params, TreesFeatureCount(trees))
+	if header.Binary() {
+		w := NewBinaryWriter(header.Format)
+		w.WriteBytes([]byte(self.loss_name)).Int(len(self.priors))
+		for _, prior := range self.priors {
+			w.Float64(prior)
+		}
+		w.Int(self.best_rounds)
+		TreesToBinary(w, trees)
+		if self.multiClass() {
+			for _, label := range self.tree_labels {
+				w.Int(label)
+			}
+		}
+		return WriteModelFile(path, header, w.Bytes())
+	}
+	sb := StringBuilder{}
+	sb.Write(self.loss_name)
+	for _, prior := range self.priors {
+		sb.Write("\t").Float(prior)
+	}
+	sb.Write("\n")
+	sb.Int(self.best_rounds).Write("\n")
+	if self.multiClass() {
+		sb.WriteBytes(LabeledTreesToString(trees, self.tree_labels))
+	} else {
+		sb.WriteBytes(TreesToString(trees))
+	}
+	return WriteModelFile(path, header, sb.Bytes())
 }
 
-func (self *GBDT) LoadModel(path string){
-	file, _ := os.Open(path)
-	defer file.Close()
-
+func (self *GBDT) LoadModel(path string) error {
+	header, body, err := ReadModelBody(path, self)
+	if err != nil {
+		return err
+	}
+	loss_name := GBDTLossEnum.SQUARED
+	priors := []float64{0.0}
+	best_rounds := 0
+	var trees []*Tree
+	var labels []int
+	if header.Binary() {
+		r := NewBinaryReader(body, header.Format).WithVersion(header.Version)
+		if header.Version >= 3 {
+			loss_name = string(r.ReadBytes())
+			priors = make([]float64, r.Len())
+			for k, _ := range priors {
+				priors[k] = r.Float64()
+			}
+		}
+		if header.Version >= 4 {
+			best_rounds = r.Int()
+		}
+		trees = TreesFromBinary(r)
+		labels = make([]int, len(trees))
+		if loss_name == GBDT_SOFTMAX_LOSS {
+			for i, _ := range labels {
+				labels[i] = r.Int()
+			}
+		}
+		if r.Err() != nil {
+			return r.Err()
+		}
+	} else {
+		if header.Version >= 3 {
+			var line string
+			line, body = nextModelLine(body)
+			tks := strings.Split(line, "\t")
+			if len(tks) < 2 {
+				return errors.New("bad loss line in gbdt model " + path)
+			}
+			loss_name = tks[0]
+			priors = make([]float64, len(tks) - 1)
+			for k, tk := range tks[1:] {
+				priors[k], err = strconv.ParseFloat(tk, 64)
+				if err != nil {
+					return errors.New("bad prior in gbdt model " + path)
+				}
+			}
+		}
+		if header.Version >= 4 {
+			var line string
+			line, body = nextModelLine(body)
+			best_rounds, err = strconv.Atoi(line)
+			if err != nil {
+				return errors.New("bad best rounds in gbdt model " + path)
+			}
+		}
+		trees, labels = LabeledTreesFromString(body)
+	}
+	if loss_name == GBDT_SOFTMAX_LOSS {
+		self.loss = &GBDTLogisticLoss{}
+	} else {
+		self.loss, err = NewGBDTLoss(loss_name, self.huber_delta)
+		if err != nil {
+			return err
+		}
+	}
+	for _, label := range labels {
+		if label < 0 || label >= len(priors) {
+			return fmt.Errorf("bad label %d of tree in gbdt model %s", label, path)
+		}
+	}
+	self.loss_name = loss_name
+	self.priors = priors
+	self.best_rounds = best_rounds
+	self.predict_rounds = 0
+	self.tree_labels = labels
 	self.dts = []*RegressionTree{}
-	scanner := bufio.NewScanner(file)
-	text := ""
-	for scanner.Scan() {
-		line := scanner.Text()
-		if line == "#" {
-			tree := Tree{}
-			tree.FromString(text)
-			dt := RegressionTree{tree: tree}
-			self.dts = append(self.dts, &dt)
-			text = ""
+	for _, tree := range trees {
+		dt := RegressionTree{tree: *tree}
+		self.dts = append(self.dts, &dt)
+	}
+	return nil
+}
+
+var GBDTParamSchema = MergeParamSchemas(ParamSchema{
+	IntParam("tree-count", 10, 1, math.Inf(1), "tree count in rdt/rf/gbdt"),
+	PositiveFloatParam("learning-rate", 0.01, math.Inf(1), "learning rate"),
+	// losses can be registered by RegisterGBDTLoss, so they are checked in Init
+	StringParam("loss", GBDTLossEnum.LOGISTIC, "loss of gbdt, logistic (predictions are probabilities), squared, absolute, huber or a loss registered by RegisterGBDTLoss"),
+	PositiveFloatParam("huber-delta", 0.5, math.Inf(1), "residuals larger than huber-delta are taken as absolute loss by huber loss"),
+	IntParam("early-stopping-rounds", 0, 0, math.Inf(1), "gbdt stops if validation metric has not improved for this many rounds, 0 never stops early"),
+	StringParam("eval-metric", GBDTMetricEnum.LOGLOSS, "validation metric of gbdt, multi-class gbdt always uses logloss",
+		GBDTMetricEnum.AUC, GBDTMetricEnum.LOGLOSS, GBDTMetricEnum.RMSE),
+	PositiveFloatParam("subsample", 1.0, 1.0, "ratio of samples each tree of gbdt is trained on"),
+	PositiveFloatParam("colsample-bytree", 1.0, 1.0, "ratio of features each tree of gbdt splits by"),
+	PositiveFloatParam("colsample-bylevel", 1.0, 1.0, "ratio of features of the tree each level of gbdt trees splits by"),
+	SeedParam,
+	IntParam("second-order", 0, 0, 1, "gbdt trees split by regularized gain of gradients and hessians if 1, and by variance of gradients if 0"),
+	FloatParam("reg-lambda", 1.0, 0.0, math.Inf(1), "L2 regularization of leaf values of second-order gbdt"),
+	FloatParam("reg-alpha", 0.0, 0.0, math.Inf(1), "L1 regularization of leaf values of second-order gbdt"),
+	FloatParam("gamma", 0.0, 0.0, math.Inf(1), "min gain of splits of second-order gbdt"),
+	FloatParam("min-child-weight", 1.0, 0.0, math.Inf(1), "min sum of hessians of each child of splits of second-order gbdt"),
+}, RegressionTreeParamSchema)
+
+func init() {
+	RegisterAlgorithm(Algorithm{
+		Name: "gbdt",
+		Description: "gradient boosting decision tree with logistic, squared, absolute or huber loss",
+		Types: AlgorithmTypeEnum.BINARY | AlgorithmTypeEnum.REGRESSION | AlgorithmTypeEnum.MULTI_CLASS,
+		Params: GBDTParamSchema,
+		New: func() interface{} { return &(GBDT{}) },
+	})
+}
+
+func (c *GBDT) Init(params map[string]string) error {
+	values, err := GBDTParamSchema.Parse(params)
+	if err != nil {
+		return err
+	}
+	// check params of trees, trees are created in Train
+	dt := RegressionTree{}
+	err = dt.Init(params)
+	if err != nil {
+		return err
+	}
+	c.tree_count = values.Int("tree-count")
+	c.dts = []*RegressionTree{}
+	c.tree_labels = []int{}
+	c.shrink = values.Float("learning-rate")
+	c.loss_name = values.String("loss")
+	c.huber_delta = values.Float("huber-delta")
+	c.loss, err = NewGBDTLoss(c.loss_name, c.huber_delta)
+	if err != nil {
+		return err
+	}
+	c.priors = []float64{0.0}
+	c.eval_metric = values.String("eval-metric")
+	c.early_stopping_rounds = values.Int("early-stopping-rounds")
+	c.best_rounds = 0
+	c.predict_rounds = 0
+	c.subsample = values.Float("subsample")
+	c.colsample_bytree = values.Float("colsample-bytree")
+	c.colsample_bylevel = values.Float("colsample-bylevel")
+	c.seed = int64(values.Int("seed"))
+	c.objective = nil
+	if values.Int("second-order") == 1 {
+		c.objective = &(TreeObjective{
+			Lambda: values.Float("reg-lambda"),
+			Alpha: values.Float("reg-alpha"),
+			Gamma: values.Float("gamma"),
+			MinChildWeight: values.Float("min-child-weight"),
+		})
+	}
+	c.init_params = params
+	return nil
+}
+
+/*
+prepareSampling creates the random generator of training, and sorted features of dataset for column subsampling
+*/
+func (c *GBDT) prepareSampling(dataset *DataSet) {
+	c.rng = NewRand(c.seed)
+	c.features = nil
+	if c.colsample_bytree < 1.0 || c.colsample_bylevel < 1.0 {
+		seen := make(map[int64]bool)
+		for _, sample := range dataset.Samples {
+			for _, feature := range sample.Features {
+				if !seen[feature.Id] {
+					seen[feature.Id] = true
+					c.features = append(c.features, feature.Id)
+				}
+			}
+		}
+		sort.Slice(c.features, func(i, j int) bool { return c.features[i] < c.features[j] })
+	}
+}
+
+/*
+growTree trains a tree on subsample of samples and colsample-bytree of features, leaf values are
+fitted on the same samples. hessians of samples are used by the second-order objective.
+It returns the node where each sample of dataset stops
+*/
+func (c *GBDT) growTree(dataset *DataSet, hessians []float64, leaf_value func(samples []int) float64) (*RegressionTree, []int) {
+	dt := c.newTree()
+	if c.objective != nil {
+		dt.objective = c.objective
+		dt.hessians = hessians
+	}
+	var rows []int
+	var in_bag []bool
+	n := len(dataset.Samples)
+	if c.subsample < 1.0 && n > 0 {
+		rows = []int{}
+		in_bag = make([]bool, n)
+		for i, _ := range in_bag {
+			if c.rng.Float64() < c.subsample {
+				in_bag[i] = true
+				rows = append(rows, i)
+			}
+		}
+		if len(rows) == 0 {
+			i := c.rng.Intn(n)
+			in_bag[i] = true
+			rows = append(rows, i)
+		}
+	}
+	var features []int64
+	if c.features != nil {
+		features = SampleFeatures(c.features, c.colsample_bytree, c.rng)
+		dt.level_ratio = c.colsample_bylevel
+		dt.rng = c.rng
+	}
+	dt.TrainSubset(dataset, rows, features)
+	dt.hessians = nil
+	return dt, c.fitLeaves(dt, dataset, leaf_value, in_bag)
+}
+
+var GBDTMetricEnum = struct {
+	AUC, LOGLOSS, RMSE string
+}{"auc", "logloss", "rmse"}
+
+/*
+SetValidation sets the dataset monitored in training, it is used by early stopping
+*/
+func (c *GBDT) SetValidation(dataset *DataSet) {
+	c.validation = dataset
+}
+
+/*
+SetPredictRounds makes predictions by trees of the first rounds, which are rounds * labels trees
+of multi-class models. 0 uses best rounds of early stopping, or all trees
+*/
+func (c *GBDT) SetPredictRounds(rounds int) {
+	c.predict_rounds = rounds
+}
+
+func (c *GBDT) treesPerRound() int {
+	if c.multiClass() {
+		return len(c.priors)
+	}
+	return 1
+}
+
+func (c *GBDT) predictTrees() int {
+	rounds := c.predict_rounds
+	if rounds <= 0 {
+		rounds = c.best_rounds
+	}
+	if rounds <= 0 || rounds * c.treesPerRound() > len(c.dts) {
+		return len(c.dts)
+	}
+	return rounds * c.treesPerRound()
+}
+
+/*
+gbdtValidation keeps scores of validation samples during training, scores of
+binary losses are kept at label 0
+*/
+type gbdtValidation struct {
+	dataset *DataSet
+	scores []*ArrayVector
+	best float64
+	best_rounds int
+}
+
+func (c *GBDT) newValidation() *gbdtValidation {
+	if c.validation == nil || len(c.validation.Samples) == 0 {
+		return nil
+	}
+	v := gbdtValidation{dataset: c.validation}
+	v.scores = make([]*ArrayVector, len(c.validation.Samples))
+	for i, _ := range v.scores {
+		v.scores[i] = NewArrayVector()
+		for k, prior := range c.priors {
+			v.scores[i].SetValue(k, prior)
+		}
+	}
+	return &v
+}
+
+func (v *gbdtValidation) addTree(dt *RegressionTree, label int, shrink float64) {
+	for i, sample := range v.dataset.Samples {
+		v.scores[i].AddValue(label, shrink * dt.Predict(sample))
+	}
+}
+
+func (v *gbdtValidation) evaluate(c *GBDT) float64 {
+	predictions := []*LabelPrediction{}
+	for i, sample := range v.dataset.Samples {
+		if c.multiClass() {
+			p := v.scores[i].SoftMaxNorm().GetValue(sample.Label)
+			predictions = append(predictions, &(LabelPrediction{Label: 1, Prediction: p, Weight: sample.Weight, HasWeight: true}))
 		} else {
-			text += line + "\n"
+			p := c.loss.Output(v.scores[i].GetValue(0))
+			predictions = append(predictions, &(LabelPrediction{Label: sample.Label, Prediction: p, Weight: sample.Weight, HasWeight: true}))
 		}
 	}
+	switch c.evalMetric() {
+	case GBDTMetricEnum.AUC:
+		return AUC(predictions)
+	case GBDTMetricEnum.RMSE:
+		return RMSE(predictions)
+	}
+	return LogLoss(predictions)
 }
 
-func (c *GBDT) Init(params map[string]string) {
-	tree_count,_ := strconv.ParseInt(params["tree-count"], 10, 64)
-	c.tree_count = int(tree_count)
-	for i := 0; i < c.tree_count; i++{
-		dt := RegressionTree{}
-		dt.Init(params)
-		c.dts = append(c.dts, &dt)
+func (c *GBDT) evalMetric() string {
+	if c.multiClass() {
+		return GBDTMetricEnum.LOGLOSS
 	}
-	c.shrink, _ = strconv.ParseFloat(params["learning-rate"], 64)
+	return c.eval_metric
+}
+
+/*
+endRound evaluates the validation dataset after rounds rounds, and returns true if training should stop
+*/
+func (c *GBDT) endRound(v *gbdtValidation, rounds int, sink ProgressSink) bool {
+	if v == nil {
+		return false
+	}
+	value := v.evaluate(c)
+	metric := c.evalMetric()
+	better := value < v.best
+	if metric == GBDTMetricEnum.AUC {
+		better = value > v.best
+	}
+	if v.best_rounds == 0 || better {
+		v.best = value
+		v.best_rounds = rounds
+	}
+	sink.Send(TrainEvent{Kind: TrainEventEnum.VALIDATION, Step: rounds, Total: c.tree_count, Metric: metric, Value: value})
+	if c.early_stopping_rounds > 0 && rounds - v.best_rounds >= c.early_stopping_rounds {
+		sink.Send(TrainEvent{Kind: TrainEventEnum.EARLY_STOPPING, Step: v.best_rounds, Metric: metric, Value: v.best})
+		c.best_rounds = v.best_rounds
+		return true
+	}
+	if c.early_stopping_rounds > 0 {
+		c.best_rounds = v.best_rounds
+	}
+	return false
+}
+
+func (c *GBDT) newTree() *RegressionTree {
+	dt := RegressionTree{}
+	dt.Init(c.init_params)
+	return &dt
+}
+
+func (c *GBDT) multiClass() bool {
+	return c.loss_name == GBDT_SOFTMAX_LOSS
 }
 
 func (c *GBDT) RMSE(dataset *DataSet) float64 {
 	rmse := 0.0
 	n := 0.0
 	for _, sample := range dataset.Samples {
-		rmse += (sample.Prediction) * (sample.Prediction)
-		n += 1.0
+		rmse += sample.GetWeight() * (sample.Prediction) * (sample.Prediction)
+		n += sample.GetWeight()
 	}
 	return math.Sqrt(rmse / n)
 }
 
-func (c *GBDT) Train(dataset *DataSet){
+func (c *GBDT) Train(dataset *DataSet) {
+	c.TrainContext(context.Background(), dataset, nil)
+}
+
+/*
+TrainContext keeps scores of samples, Prediction of samples is set to the negative gradient
+which is fitted by the next tree. With the second-order objective, gradients and hessians are
+multiplied by weights of samples as in XGBoost. It stops before the next round when ctx is done,
+the model keeps the rounds trained
+*/
+func (c *GBDT) TrainContext(ctx context.Context, dataset *DataSet, sink ProgressSink) error {
+	if c.multiClass() {
+		// softmax loss comes from logistic loss, see trainMultiClass
+		c.loss_name = GBDTLossEnum.LOGISTIC
+	}
+	max_label := 0
 	for _, sample := range dataset.Samples {
-		sample.Prediction = sample.LabelDoubleValue()
+		if max_label < sample.Label {
+			max_label = sample.Label
+		}
 	}
-	for k, dt := range c.dts {
-		dt.Train(dataset)
-		for _, sample := range dataset.Samples {
-			sample.Prediction -= c.shrink * dt.Predict(sample)
+	if max_label > 1 && c.loss_name == GBDTLossEnum.LOGISTIC {
+		return c.trainMultiClass(ctx, dataset, max_label, sink)
+	}
+
+	labels := make([]float64, len(dataset.Samples))
+	scores := make([]float64, len(dataset.Samples))
+	weights := make([]float64, len(dataset.Samples))
+	for i, sample := range dataset.Samples {
+		labels[i] = sample.LabelDoubleValue()
+		weights[i] = sample.GetWeight()
+	}
+	c.priors = []float64{c.loss.Prior(labels, weights)}
+	c.dts = []*RegressionTree{}
+	c.tree_labels = []int{}
+	c.best_rounds = 0
+	c.prepareSampling(dataset)
+	validation := c.newValidation()
+	var hessians []float64
+	if c.objective != nil {
+		hessians = make([]float64, len(dataset.Samples))
+	}
+	set_gradients := func() {
+		for i, sample := range dataset.Samples {
+			sample.Prediction = c.loss.NegativeGradient(labels[i], scores[i])
+			if hessians != nil {
+				sample.Prediction *= weights[i]
+				hessians[i] = weights[i] * c.loss.Hessian(labels[i], scores[i])
+			}
 		}
-		if k % 10 == 0 {
-			fmt.Println(c.RMSE(dataset))
+	}
+	for i, _ := range scores {
+		scores[i] = c.priors[0]
+	}
+	set_gradients()
+	node_labels := []float64{}
+	node_scores := []float64{}
+	node_weights := []float64{}
+	leaf_value := func(samples []int) float64 {
+		if c.objective != nil {
+			return c.objectiveLeafValue(dataset, hessians, samples)
+		}
+		node_labels = node_labels[:0]
+		node_scores = node_scores[:0]
+		node_weights = node_weights[:0]
+		for _, i := range samples {
+			node_labels = append(node_labels, labels[i])
+			node_scores = append(node_scores, scores[i])
+			node_weights = append(node_weights, weights[i])
+		}
+		return c.loss.LeafValue(node_labels, node_scores, node_weights)
+	}
+	// Prediction of samples are weighted gradients with the objective, so residuals are computed by scores
+	residual_rmse := func() float64 {
+		sum := 0.0
+		for i, _ := range scores {
+			r := c.loss.NegativeGradient(labels[i], scores[i])
+			sum += weights[i] * r * r
 		}
+		return math.Sqrt(sum / dataset.lossWeight())
 	}
+	for k := 0; k < c.tree_count; k++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		dt, ends := c.growTree(dataset, hessians, leaf_value)
+		for i, _ := range scores {
+			scores[i] += c.shrink * dt.tree.GetNode(ends[i]).prediction.GetValue(0)
+		}
+		set_gradients()
+		c.dts = append(c.dts, dt)
+		c.tree_labels = append(c.tree_labels, 0)
+		if sink != nil {
+			sink(TrainEvent{Kind: TrainEventEnum.ROUND, Step: k + 1, Total: c.tree_count, Metric: "residual-rmse", Value: residual_rmse()})
+		}
+		if validation != nil {
+			validation.addTree(dt, 0, c.shrink)
+			if c.endRound(validation, k + 1, sink) {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+/*
+trainMultiClass grows max_label + 1 trees per round on residuals of softmax probabilities, leaf values
+are one Newton step as in "Greedy Function Approximation: A Gradient Boosting Machine", or values of
+the second-order objective by hessians p * (1 - p). Priors, residuals and hessians are weighted by samples
+*/
+func (c *GBDT) trainMultiClass(ctx context.Context, dataset *DataSet, max_label int, sink ProgressSink) error {
+	n := len(dataset.Samples)
+	labels := max_label + 1
+	c.loss_name = GBDT_SOFTMAX_LOSS
+	c.priors = make([]float64, labels)
+	counts := make([]float64, labels)
+	for _, sample := range dataset.Samples {
+		counts[sample.Label] += sample.GetWeight()
+	}
+	total_weight := dataset.lossWeight()
+	for k, count := range counts {
+		c.priors[k] = math.Log(math.Max(count / total_weight, 1e-6))
+	}
+	scores := make([]*ArrayVector, n)
+	for i, _ := range scores {
+		scores[i] = NewArrayVector()
+		for k, prior := range c.priors {
+			scores[i].SetValue(k, prior)
+		}
+	}
+	c.dts = []*RegressionTree{}
+	c.tree_labels = []int{}
+	c.best_rounds = 0
+	c.prepareSampling(dataset)
+	validation := c.newValidation()
+	residuals := make([]float64, n)
+	hessians := make([]float64, n)
+	leaf_value := func(samples []int) float64 {
+		if c.objective != nil {
+			return c.objectiveLeafValue(dataset, hessians, samples)
+		}
+		numerator := 0.0
+		denominator := 0.0
+		for _, i := range samples {
+			r := residuals[i]
+			w := dataset.Samples[i].GetWeight()
+			numerator += w * r
+			denominator += w * math.Abs(r) * (1 - math.Abs(r))
+		}
+		if denominator < 1e-150 {
+			return 0.0
+		}
+		return float64(labels - 1) / float64(labels) * numerator / denominator
+	}
+	for round := 0; round < c.tree_count; round++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		probabilities := make([]*ArrayVector, n)
+		for i, _ := range scores {
+			probabilities[i] = scores[i].SoftMaxNorm()
+		}
+		for k := 0; k < labels; k++ {
+			for i, sample := range dataset.Samples {
+				residuals[i] = -probabilities[i].GetValue(k)
+				if sample.Label == k {
+					residuals[i] += 1.0
+				}
+				sample.Prediction = residuals[i]
+				hessians[i] = math.Abs(residuals[i]) * (1 - math.Abs(residuals[i]))
+				if c.objective != nil {
+					sample.Prediction *= sample.GetWeight()
+					hessians[i] *= sample.GetWeight()
+				}
+			}
+			dt, ends := c.growTree(dataset, hessians, leaf_value)
+			for i, _ := range scores {
+				scores[i].AddValue(k, c.shrink * dt.tree.GetNode(ends[i]).prediction.GetValue(0))
+			}
+			c.dts = append(c.dts, dt)
+			c.tree_labels = append(c.tree_labels, k)
+			if validation != nil {
+				validation.addTree(dt, k, c.shrink)
+			}
+		}
+		if sink != nil {
+			logloss := 0.0
+			for i, sample := range dataset.Samples {
+				logloss -= sample.GetWeight() * math.Log(math.Max(scores[i].SoftMaxNorm().GetValue(sample.Label), 1e-15))
+			}
+			sink(TrainEvent{Kind: TrainEventEnum.ROUND, Step: round + 1, Total: c.tree_count, Metric: GBDTMetricEnum.LOGLOSS, Value: logloss / total_weight})
+		}
+		if c.endRound(validation, round + 1, sink) {
+			break
+		}
+	}
+	return nil
+}
+
+/*
+objectiveLeafValue is the value of the second-order objective of samples, Prediction of samples are negative gradients.
+Both gradients and hessians are weighted by samples
+*/
+func (c *GBDT) objectiveLeafValue(dataset *DataSet, hessians []float64, samples []int) float64 {
+	g := 0.0
+	h := 0.0
+	for _, i := range samples {
+		g -= dataset.Samples[i].Prediction
+		h += hessians[i]
+	}
+	return c.objective.LeafValue(g, h)
+}
+
+/*
+fitLeaves sets the value of every node of dt by leaf_value of training samples passing it, as
+prediction stops at an inner node if its child is missing. Only samples in_bag are used if it is not nil.
+It returns the node where each sample stops
+*/
+func (c *GBDT) fitLeaves(dt *RegressionTree, dataset *DataSet, leaf_value func(samples []int) float64, in_bag []bool) []int {
+	tree := &(dt.tree)
+	node_samples := make([][]int, tree.Size())
+	ends := make([]int, len(dataset.Samples))
+	for i, sample := range dataset.Samples {
+		msample := sample.ToMapBasedSample()
+		k := 0
+		for {
+			if in_bag == nil || in_bag[i] {
+				node_samples[k] = append(node_samples[k], i)
+			}
+			node := tree.GetNode(k)
+			next := node.right
+			if dt.GoLeft(msample, node) {
+				next = node.left
+			}
+			if next < 0 || next >= tree.Size() {
+				break
+			}
+			k = next
+		}
+		ends[i] = k
+	}
+	for k, node := range tree.nodes {
+		if len(node_samples[k]) > 0 {
+			node.prediction.SetValue(0, leaf_value(node_samples[k]))
+		}
+	}
+	return ends
+}
+
+/*
+Trees returns trees used by prediction, which are trees of the best rounds of early stopping
+*/
+func (c *GBDT) Trees() []*Tree {
+	ret := []*Tree{}
+	for _, dt := range c.dts[:c.predictTrees()] {
+		ret = append(ret, &(dt.tree))
+	}
+	return ret
 }
 
 func (c *GBDT) Predict(sample *Sample) float64 {
-	ret := 0.0
-	for _, dt := range c.dts {
+	if c.multiClass() {
+		return c.PredictMultiClass(sample).GetValue(1)
+	}
+	ret := c.priors[0]
+	for _, dt := range c.dts[:c.predictTrees()] {
 		ret += c.shrink * dt.Predict(sample)
 	}
-	return ret
+	return c.loss.Output(ret)
 }
 
+/*
+PredictMultiClass returns softmax probabilities of labels, models of binary losses give
+probabilities of label 0 and 1
+*/
+func (c *GBDT) PredictMultiClass(sample *Sample) *ArrayVector {
+	if !c.multiClass() {
+		p := c.Predict(sample)
+		ret := NewArrayVector()
+		ret.SetValue(0, 1 - p)
+		ret.SetValue(1, p)
+		return ret
+	}
+	scores := NewArrayVector()
+	for k, prior := range c.priors {
+		scores.SetValue(k, prior)
+	}
+	for i, dt := range c.dts[:c.predictTrees()] {
+		scores.AddValue(c.tree_labels[i], c.shrink * dt.Predict(sample))
+	}
+	return scores.SoftMaxNorm()
+}
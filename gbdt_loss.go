@@ -0,0 +1,282 @@
+package hector
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+/*
+GBDTLoss is the loss minimized by GBDT. Trees are fitted to the negative gradient of the loss
+at current scores, then every leaf takes the value which minimizes the loss of samples in it.
+With the second-order objective, trees and leaves are fitted by gradients and hessians instead.
+weights are weights of samples, the loss of a sample is multiplied by its weight
+*/
+type GBDTLoss interface {
+	// Prior is the initial score of all samples
+	Prior(labels, weights []float64) float64
+	NegativeGradient(label, score float64) float64
+	Hessian(label, score float64) float64
+	LeafValue(labels, scores, weights []float64) float64
+	// Output turns the score of a sample into its prediction
+	Output(score float64) float64
+}
+
+var GBDTLossEnum = struct {
+	LOGISTIC, SQUARED, ABSOLUTE, HUBER string
+}{"logistic", "squared", "absolute", "huber"}
+
+var gbdt_losses = make(map[string]GBDTLoss)
+
+/*
+RegisterGBDTLoss makes loss usable by gbdt with --loss name. Models trained by it can only be loaded
+where it is registered
+*/
+func RegisterGBDTLoss(name string, loss GBDTLoss) {
+	gbdt_losses[name] = loss
+}
+
+func NewGBDTLoss(name string, huber_delta float64) (GBDTLoss, error) {
+	if loss, ok := gbdt_losses[name]; ok {
+		return loss, nil
+	}
+	switch name {
+	case GBDTLossEnum.LOGISTIC:
+		return &GBDTLogisticLoss{}, nil
+	case GBDTLossEnum.SQUARED:
+		return &GBDTSquaredLoss{}, nil
+	case GBDTLossEnum.ABSOLUTE:
+		return &GBDTAbsoluteLoss{}, nil
+	case GBDTLossEnum.HUBER:
+		return &GBDTHuberLoss{Delta: huber_delta}, nil
+	}
+	return nil, errors.New("unknown gbdt loss " + name)
+}
+
+/*
+GBDTLogisticLoss is binomial deviance, scores are log-odds. Leaf values are one Newton step,
+sum of residuals divided by sum of p * (1 - p)
+*/
+type GBDTLogisticLoss struct{}
+
+func (l *GBDTLogisticLoss) Prior(labels, weights []float64) float64 {
+	if len(labels) == 0 {
+		return 0.0
+	}
+	p := weightedMean(labels, weights)
+	p = math.Min(math.Max(p, 1e-6), 1 - 1e-6)
+	return math.Log(p / (1 - p))
+}
+
+func (l *GBDTLogisticLoss) NegativeGradient(label, score float64) float64 {
+	return label - Sigmoid(score)
+}
+
+func (l *GBDTLogisticLoss) Hessian(label, score float64) float64 {
+	p := Sigmoid(score)
+	return p * (1 - p)
+}
+
+func (l *GBDTLogisticLoss) LeafValue(labels, scores, weights []float64) float64 {
+	numerator := 0.0
+	denominator := 0.0
+	for i, label := range labels {
+		p := Sigmoid(scores[i])
+		numerator += weights[i] * (label - p)
+		denominator += weights[i] * p * (1 - p)
+	}
+	if denominator < 1e-150 {
+		return 0.0
+	}
+	return numerator / denominator
+}
+
+func (l *GBDTLogisticLoss) Output(score float64) float64 {
+	return Sigmoid(score)
+}
+
+type GBDTSquaredLoss struct{}
+
+func (l *GBDTSquaredLoss) Prior(labels, weights []float64) float64 {
+	return weightedMean(labels, weights)
+}
+
+func (l *GBDTSquaredLoss) NegativeGradient(label, score float64) float64 {
+	return label - score
+}
+
+func (l *GBDTSquaredLoss) Hessian(label, score float64) float64 {
+	return 1.0
+}
+
+func (l *GBDTSquaredLoss) LeafValue(labels, scores, weights []float64) float64 {
+	return weightedMean(residuals(labels, scores), weights)
+}
+
+func (l *GBDTSquaredLoss) Output(score float64) float64 {
+	return score
+}
+
+/*
+GBDTAbsoluteLoss has zero hessian, its hessian is taken as 1 as squared loss
+*/
+type GBDTAbsoluteLoss struct{}
+
+func (l *GBDTAbsoluteLoss) Prior(labels, weights []float64) float64 {
+	return weightedMedian(labels, weights)
+}
+
+func (l *GBDTAbsoluteLoss) NegativeGradient(label, score float64) float64 {
+	return Signum(label - score)
+}
+
+func (l *GBDTAbsoluteLoss) Hessian(label, score float64) float64 {
+	return 1.0
+}
+
+func (l *GBDTAbsoluteLoss) LeafValue(labels, scores, weights []float64) float64 {
+	return weightedMedian(residuals(labels, scores), weights)
+}
+
+func (l *GBDTAbsoluteLoss) Output(score float64) float64 {
+	return score
+}
+
+/*
+GBDTHuberLoss is squared for residuals not larger than Delta and absolute for others. Leaf values
+follow "Greedy Function Approximation: A Gradient Boosting Machine" : weighted median of residuals
+plus weighted mean of clipped deviations from the median
+*/
+type GBDTHuberLoss struct {
+	Delta float64
+}
+
+func (l *GBDTHuberLoss) Prior(labels, weights []float64) float64 {
+	return weightedMedian(labels, weights)
+}
+
+func (l *GBDTHuberLoss) NegativeGradient(label, score float64) float64 {
+	r := label - score
+	if math.Abs(r) <= l.Delta {
+		return r
+	}
+	return l.Delta * Signum(r)
+}
+
+/*
+Hessian is 1 for all residuals as in LightGBM. The true hessian is 0 for residuals larger than Delta,
+then leaves of only such residuals have no hessian and their values are only bounded by reg-lambda
+*/
+func (l *GBDTHuberLoss) Hessian(label, score float64) float64 {
+	return 1.0
+}
+
+func (l *GBDTHuberLoss) LeafValue(labels, scores, weights []float64) float64 {
+	rs := residuals(labels, scores)
+	if len(rs) == 0 {
+		return 0.0
+	}
+	m := weightedMedian(rs, weights)
+	clipped := make([]float64, len(rs))
+	for i, r := range rs {
+		clipped[i] = Signum(r - m) * math.Min(l.Delta, math.Abs(r - m))
+	}
+	return m + weightedMean(clipped, weights)
+}
+
+func (l *GBDTHuberLoss) Output(score float64) float64 {
+	return score
+}
+
+/*
+GBDTCustomLoss is a loss given by its gradient and hessian, leaf values are one Newton step.
+Prior is 0, and Output is the score if OutputFunc is nil
+*/
+type GBDTCustomLoss struct {
+	GradientFunc func(label, score float64) float64
+	HessianFunc func(label, score float64) float64
+	OutputFunc func(score float64) float64
+}
+
+func (l *GBDTCustomLoss) Prior(labels, weights []float64) float64 {
+	return 0.0
+}
+
+func (l *GBDTCustomLoss) NegativeGradient(label, score float64) float64 {
+	return -l.GradientFunc(label, score)
+}
+
+func (l *GBDTCustomLoss) Hessian(label, score float64) float64 {
+	return l.HessianFunc(label, score)
+}
+
+func (l *GBDTCustomLoss) LeafValue(labels, scores, weights []float64) float64 {
+	g := 0.0
+	h := 0.0
+	for i, label := range labels {
+		g += weights[i] * l.GradientFunc(label, scores[i])
+		h += weights[i] * l.HessianFunc(label, scores[i])
+	}
+	if h < 1e-150 {
+		return 0.0
+	}
+	return -g / h
+}
+
+func (l *GBDTCustomLoss) Output(score float64) float64 {
+	if l.OutputFunc == nil {
+		return score
+	}
+	return l.OutputFunc(score)
+}
+
+func residuals(labels, scores []float64) []float64 {
+	ret := make([]float64, len(labels))
+	for i, label := range labels {
+		ret[i] = label - scores[i]
+	}
+	return ret
+}
+
+func weightedMean(values, weights []float64) float64 {
+	sum := 0.0
+	total := 0.0
+	for i, value := range values {
+		sum += weights[i] * value
+		total += weights[i]
+	}
+	if total <= 0 {
+		return 0.0
+	}
+	return sum / total
+}
+
+/*
+weightedMedian is the value where half of the weights are smaller and half are larger, it is the
+mean of two values if the weights split between them, as the median of values of the same weight
+*/
+func weightedMedian(values, weights []float64) float64 {
+	order := []int{}
+	total := 0.0
+	for i, _ := range values {
+		if weights[i] > 0 {
+			order = append(order, i)
+			total += weights[i]
+		}
+	}
+	if len(order) == 0 {
+		return 0.0
+	}
+	sort.Slice(order, func(a, b int) bool { return values[order[a]] < values[order[b]] })
+	sum := 0.0
+	for k, i := range order {
+		sum += weights[i]
+		if sum == 0.5 * total && k + 1 < len(order) {
+			return 0.5 * (values[i] + values[order[k + 1]])
+		}
+		if sum >= 0.5 * total {
+			return values[i]
+		}
+	}
+	return values[order[len(order) - 1]]
+}
@@ -0,0 +1,281 @@
+package hector
+
+import (
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"testing"
+)
+
+func gbdtTestParams() map[string]string {
+	params := DefaultParams()
+	params["max-depth"] = "4"
+	params["min-leaf-size"] = "5"
+	params["tree-count"] = "30"
+	params["learning-rate"] = "0.3"
+	return params
+}
+
+func TestGBDTLogisticLoss(t *testing.T) {
+	train_dataset := XORDataSet(1000)
+	test_dataset := XORDataSet(500)
+	params := gbdtTestParams()
+
+	logistic := GBDT{}
+	logistic.Init(params)
+	logistic.Train(train_dataset)
+	params["loss"] = GBDTLossEnum.SQUARED
+	squared := GBDT{}
+	squared.Init(params)
+	squared.Train(train_dataset)
+
+	logloss := func(c *GBDT) float64 {
+		ret := 0.0
+		for _, sample := range test_dataset.Samples {
+			p := c.Predict(sample)
+			if p < 0 || p > 1 {
+				return math.Inf(1)
+			}
+			if sample.Label > 0 {
+				ret -= math.Log(math.Max(p, 1e-15))
+			} else {
+				ret -= math.Log(math.Max(1 - p, 1e-15))
+			}
+		}
+		return ret / float64(len(test_dataset.Samples))
+	}
+	t.Logf("logloss of gbdt by logistic loss is %f, by squared loss is %f", logloss(&logistic), logloss(&squared))
+	if logloss(&logistic) > 0.3 {
+		t.Error("logloss of gbdt by logistic loss is larger than 0.3 in xor dataset")
+	}
+	if math.Abs(logistic.priors[0]) > 0.5 {
+		t.Errorf("prior of balanced dataset is %f", logistic.priors[0])
+	}
+}
+
+func TestGBDTLosses(t *testing.T) {
+	train_dataset := XORDataSet(1000)
+	test_dataset := XORDataSet(500)
+	for _, loss := range []string{GBDTLossEnum.LOGISTIC, GBDTLossEnum.SQUARED, GBDTLossEnum.ABSOLUTE, GBDTLossEnum.HUBER} {
+		params := gbdtTestParams()
+		params["loss"] = loss
+		c := GBDT{}
+		c.Init(params)
+		auc, _ := AlgorithmRunOnDataSet(&c, train_dataset, test_dataset, "", params)
+		t.Logf("auc of gbdt by %s loss in xor dataset is %f", loss, auc)
+		if auc < 0.9 {
+			t.Errorf("auc of gbdt by %s loss is less than 0.9 in xor dataset", loss)
+		}
+	}
+}
+
+func TestGBDTMultiClass(t *testing.T) {
+	train_dataset := MultiClassDataSet(2000, 4)
+	test_dataset := MultiClassDataSet(500, 4)
+	params := gbdtTestParams()
+
+	file, _ := ioutil.TempFile("", "hector-gbdt")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	c := GBDT{}
+	c.Init(params)
+	accuracy := MultiClassRunOnDataSet(&c, train_dataset, test_dataset, "", params)
+	t.Logf("accuracy of gbdt in multi-class dataset is %f", accuracy)
+	if accuracy < 0.9 {
+		t.Error("accuracy of gbdt is less than 0.9 in multi-class dataset")
+	}
+	if len(c.dts) != 4 * c.tree_count {
+		t.Errorf("gbdt grows %d trees in %d rounds of 4 labels", len(c.dts), c.tree_count)
+	}
+
+	for _, format := range []string{MODEL_FORMAT_TEXT, MODEL_FORMAT_BINARY} {
+		c.init_params["model-format"] = format
+		err := c.SaveModel(file.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := LoadAnyMultiClassModel(file.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, sample := range test_dataset.Samples {
+			p1 := c.PredictMultiClass(sample)
+			p2 := loaded.PredictMultiClass(sample)
+			sum := 0.0
+			for k := 0; k < 4; k++ {
+				sum += p1.GetValue(k)
+				if math.Abs(p1.GetValue(k) - p2.GetValue(k)) > 1e-9 {
+					t.Fatalf("probability of label %d on sample %d changes from %f to %f after load in %s format", k, i, p1.GetValue(k), p2.GetValue(k), format)
+				}
+			}
+			if math.Abs(sum - 1) > 1e-9 {
+				t.Fatalf("probabilities of sample %d sum to %f", i, sum)
+			}
+		}
+	}
+}
+
+func TestGBDTEarlyStopping(t *testing.T) {
+	// 20% of labels are flipped, so gbdt overfits the train dataset
+	noisy := func(n int) *DataSet {
+		ret := circleDataSet(n)
+		for _, sample := range ret.Samples {
+			if rand.Float64() < 0.2 {
+				sample.Label = 1 - sample.Label
+			}
+		}
+		return ret
+	}
+	train_dataset := noisy(1000)
+	validation_dataset := noisy(500)
+	params := gbdtTestParams()
+	params["tree-count"] = "300"
+	params["max-depth"] = "8"
+	params["min-leaf-size"] = "1"
+	params["early-stopping-rounds"] = "10"
+
+	file, _ := ioutil.TempFile("", "hector-gbdt")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	c := GBDT{}
+	c.Init(params)
+	c.SetValidation(validation_dataset)
+	c.Train(train_dataset)
+	t.Logf("gbdt stops after %d trees, best rounds is %d", len(c.dts), c.best_rounds)
+	if len(c.dts) >= 300 || c.best_rounds <= 0 || len(c.dts) != c.best_rounds + 10 {
+		t.Fatalf("gbdt does not stop early, %d trees are grown and best rounds is %d", len(c.dts), c.best_rounds)
+	}
+
+	sample := validation_dataset.Samples[0]
+	best := c.Predict(sample)
+	c.SetPredictRounds(len(c.dts))
+	all := c.Predict(sample)
+	c.SetPredictRounds(0)
+	if best == all {
+		t.Error("prediction does not stop at best rounds")
+	}
+
+	for _, format := range []string{MODEL_FORMAT_TEXT, MODEL_FORMAT_BINARY} {
+		c.init_params["model-format"] = format
+		err := c.SaveModel(file.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		loaded := GBDT{}
+		loaded.Init(params)
+		err = loaded.LoadModel(file.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if loaded.best_rounds != c.best_rounds || loaded.Predict(sample) != best {
+			t.Errorf("best rounds %d is loaded as %d in %s format", c.best_rounds, loaded.best_rounds, format)
+		}
+	}
+}
+
+func TestGBDTSubsampling(t *testing.T) {
+	train_dataset := LinearDataSet(1000)
+	test_dataset := LinearDataSet(500)
+	params := gbdtTestParams()
+	params["subsample"] = "0.5"
+	params["colsample-bytree"] = "0.5"
+	params["colsample-bylevel"] = "0.5"
+	params["seed"] = "7"
+
+	train := func() *GBDT {
+		c := GBDT{}
+		c.Init(params)
+		c.Train(train_dataset)
+		return &c
+	}
+	c1 := train()
+	c2 := train()
+	params["seed"] = "8"
+	c3 := train()
+	same := true
+	for i, sample := range test_dataset.Samples {
+		if c1.Predict(sample) != c2.Predict(sample) {
+			t.Fatalf("prediction of sample %d changes from %f to %f with the same seed", i, c1.Predict(sample), c2.Predict(sample))
+		}
+		same = same && c1.Predict(sample) == c3.Predict(sample)
+	}
+	if same {
+		t.Error("predictions do not change with seed")
+	}
+
+	for i, dt := range c1.dts {
+		features := make(map[int64]bool)
+		for _, fid := range dt.features {
+			features[fid] = true
+		}
+		if len(features) == 0 || len(features) >= 100 {
+			t.Fatalf("tree %d splits by %d features", i, len(features))
+		}
+		for _, node := range dt.tree.nodes {
+			if (node.left >= 0 || node.right >= 0) && !features[node.feature_split.Id] {
+				t.Fatalf("tree %d splits by feature %d which is not sampled", i, node.feature_split.Id)
+			}
+		}
+	}
+
+	auc, _ := AlgorithmRunOnDataSet(c1, nil, test_dataset, "", params)
+	t.Logf("auc of gbdt with subsampling in linear dataset is %f", auc)
+	if auc < 0.85 {
+		t.Error("auc of gbdt with subsampling is less than 0.85 in linear dataset")
+	}
+}
+
+func TestGBDTSecondOrder(t *testing.T) {
+	train_dataset := XORDataSet(1000)
+	test_dataset := XORDataSet(500)
+	RegisterGBDTLoss("test-squared", &(GBDTCustomLoss{
+		GradientFunc: func(label, score float64) float64 { return score - label },
+		HessianFunc: func(label, score float64) float64 { return 1.0 },
+	}))
+	for _, loss := range []string{GBDTLossEnum.LOGISTIC, GBDTLossEnum.HUBER, "test-squared"} {
+		params := gbdtTestParams()
+		params["loss"] = loss
+		params["second-order"] = "1"
+		// splits of logistic loss are found by sorted values, others by histograms
+		if loss != GBDTLossEnum.LOGISTIC {
+			params["max-bins"] = "16"
+		}
+		c := GBDT{}
+		err := c.Init(params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		auc, _ := AlgorithmRunOnDataSet(&c, train_dataset, test_dataset, "", params)
+		t.Logf("auc of second-order gbdt by %s loss in xor dataset is %f", loss, auc)
+		if auc < 0.9 {
+			t.Errorf("auc of second-order gbdt by %s loss is less than 0.9 in xor dataset", loss)
+		}
+	}
+
+	// no split gains gamma, so every tree is a single leaf
+	params := gbdtTestParams()
+	params["second-order"] = "1"
+	params["gamma"] = "1000"
+	c := GBDT{}
+	c.Init(params)
+	c.Train(train_dataset)
+	for i, dt := range c.dts {
+		if dt.tree.Size() != 1 {
+			t.Fatalf("tree %d of gbdt with large gamma has %d nodes", i, dt.tree.Size())
+		}
+	}
+
+	objective := TreeObjective{Lambda: 1.0, Alpha: 0.5, MinChildWeight: 2.0}
+	if math.Abs(objective.LeafValue(-3.5, 2.0) - 1.0) > 1e-9 || objective.LeafValue(0.3, 2.0) != 0.0 {
+		t.Error("leaf values of objective are not shrunk by alpha and lambda")
+	}
+	if _, ok := objective.Gain(-3.0, 1.0, 3.0, 5.0); ok {
+		t.Error("objective splits a child of hessian sum less than min child weight")
+	}
+	if gain, ok := objective.Gain(-3.5, 2.0, 3.5, 2.0); !ok || math.Abs(gain - 3.0) > 1e-9 {
+		t.Errorf("gain of objective is %f", gain)
+	}
+}
@@ -1,29 +1,99 @@
 package hector
 
 import (
-	"strconv"
 	"math"
 	"math/rand"
+	"strconv"
+	"strings"
 )
 
 type KNN struct {
 	sv []*Vector
 	labels []int
 	k int
+	rng *rand.Rand
+	init_params map[string]string
 }
 
 
-func (self *KNN) SaveModel(path string){
+func (self *KNN) SaveModel(path string) error {
+	header := NewModelHeader(self, self.init_params, FeatureCountOfVectors(self.sv...))
+	if header.Binary() {
+		w := NewBinaryWriter(header.Format)
+		w.Int(len(self.sv))
+		for i, sv := range self.sv {
+			w.Int(self.labels[i])
+			sv.WriteBinary(w)
+		}
+		return WriteModelFile(path, header, w.Bytes())
+	}
+	sb := StringBuilder{}
+	for i, sv := range self.sv {
+		sb.Int(self.labels[i])
+		sb.Write("\t")
+		sb.WriteBytes(sv.ToString())
+		sb.Write("\n")
+	}
+	return WriteModelFile(path, header, sb.Bytes())
+}
 
+func (self *KNN) LoadModel(path string) error {
+	header, body, err := ReadModelBody(path, self)
+	if err != nil {
+		return err
+	}
+	if header.Binary() {
+		r := NewBinaryReader(body, header.Format)
+		self.sv = []*Vector{}
+		self.labels = []int{}
+		n := r.Len()
+		for i := 0; i < n; i++ {
+			self.labels = append(self.labels, r.Int())
+			sv := NewVector()
+			sv.ReadBinary(r)
+			self.sv = append(self.sv, sv)
+		}
+		return r.Err()
+	}
+
+	self.sv = []*Vector{}
+	self.labels = []int{}
+	scaner := NewModelScanner(body)
+	for scaner.Scan() {
+		tks := strings.Split(scaner.Text(), "\t")
+		label, _ := strconv.Atoi(tks[0])
+		sv := NewVector()
+		sv.FromString(tks[1])
+		self.sv = append(self.sv, sv)
+		self.labels = append(self.labels, label)
+	}
+	return nil
 }
 
-func (self *KNN) LoadModel(path string){
-	
+var KNNParamSchema = ParamSchema{
+	IntParam("k", 3, 1, math.Inf(1), "neighborhood size of knn"),
+	SeedParam,
 }
 
-func (c *KNN) Init(params map[string]string){
-	K, _ := strconv.ParseInt(params["k"], 10, 64)
-	c.k = int(K)
+func init() {
+	RegisterAlgorithm(Algorithm{
+		Name: "knn",
+		Description: "k-nearest neighbor classification",
+		Types: AlgorithmTypeEnum.BINARY | AlgorithmTypeEnum.MULTI_CLASS,
+		Params: KNNParamSchema,
+		New: func() interface{} { return &(KNN{}) },
+	})
+}
+
+func (c *KNN) Init(params map[string]string) error {
+	values, err := KNNParamSchema.Parse(params)
+	if err != nil {
+		return err
+	}
+	c.k = values.Int("k")
+	c.rng = NewRand(int64(values.Int("seed")))
+	c.init_params = params
+	return nil
 }
 
 func (c *KNN) Kernel(x, y *Vector) float64{
@@ -65,7 +135,7 @@ func (c *KNN) Train(dataset *DataSet) {
 	c.sv = []*Vector{}
 	c.labels = []int{}
 	for i := 0; i < 1000; i++ {
-		k := rand.Intn(len(dataset.Samples))
+		k := c.rng.Intn(len(dataset.Samples))
 		c.sv = append(c.sv, dataset.Samples[k].GetFeatureVector())
 		c.labels = append(c.labels, dataset.Samples[k].Label)
 	}
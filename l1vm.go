@@ -2,8 +2,9 @@ package hector
 
 import (
 	"math"
-	"strconv"
 	"math/rand"
+	"strconv"
+	"strings"
 )
 
 func Distance(x, y *Vector) float64 {
@@ -24,22 +25,122 @@ type L1VM struct {
 	ftrl *FTRLLogisticRegression
 	radius float64
 	count int
+	rng *rand.Rand
+	init_params map[string]string
 }
 
-func (self *L1VM) SaveModel(path string){
-	
+/*
+Model file starts with a line of support vector count, followed by one line per support vector,
+then the kernel weights in the same layout as FTRLLogisticRegression : fid, ni and zi
+*/
+func (self *L1VM) SaveModel(path string) error {
+	header := NewModelHeader(self, self.init_params, FeatureCountOfVectors(self.sv...))
+	if header.Binary() {
+		w := NewBinaryWriter(header.Format)
+		w.Int(len(self.sv))
+		for _, sv := range self.sv {
+			sv.WriteBinary(w)
+		}
+		w.Int(len(self.ftrl.Model))
+		for f, g := range self.ftrl.Model {
+			w.Varint(f).Float(g.ni).Float(g.zi)
+		}
+		return WriteModelFile(path, header, w.Bytes())
+	}
+	sb := StringBuilder{}
+	sb.Int(len(self.sv))
+	sb.Write("\n")
+	for _, sv := range self.sv {
+		sb.WriteBytes(sv.ToString())
+		sb.Write("\n")
+	}
+	for f, g := range self.ftrl.Model {
+		sb.Int64(f)
+		sb.Write("\t")
+		sb.Float(g.ni)
+		sb.Write("\t")
+		sb.Float(g.zi)
+		sb.Write("\n")
+	}
+	return WriteModelFile(path, header, sb.Bytes())
 }
 
-func (self *L1VM) LoadModel(path string){
-	
+func (self *L1VM) LoadModel(path string) error {
+	header, body, err := ReadModelBody(path, self)
+	if err != nil {
+		return err
+	}
+	if header.Binary() {
+		r := NewBinaryReader(body, header.Format)
+		self.sv = []*Vector{}
+		self.ftrl.Model = make(map[int64]FTRLFeatureWeight)
+		n := r.Len()
+		for i := 0; i < n; i++ {
+			sv := NewVector()
+			sv.ReadBinary(r)
+			self.sv = append(self.sv, sv)
+		}
+		n = r.Len()
+		for i := 0; i < n; i++ {
+			fid := r.Varint()
+			self.ftrl.Model[fid] = FTRLFeatureWeight{ni: r.Float(), zi: r.Float()}
+		}
+		return r.Err()
+	}
+
+	self.sv = []*Vector{}
+	self.ftrl.Model = make(map[int64]FTRLFeatureWeight)
+	scaner := NewModelScanner(body)
+	count := 0
+	if scaner.Scan() {
+		count, _ = strconv.Atoi(scaner.Text())
+	}
+	for i := 0; i < count && scaner.Scan(); i++ {
+		sv := NewVector()
+		sv.FromString(scaner.Text())
+		self.sv = append(self.sv, sv)
+	}
+	for scaner.Scan() {
+		tks := strings.Split(scaner.Text(), "\t")
+		fid, _ := strconv.ParseInt(tks[0], 10, 64)
+		ni, _ := strconv.ParseFloat(tks[1], 64)
+		zi, _ := strconv.ParseFloat(tks[2], 64)
+		self.ftrl.Model[fid] = FTRLFeatureWeight{ni: ni, zi: zi}
+	}
+	return nil
 }
 
-func (c *L1VM) Init(params map[string]string){
+var L1VMParamSchema = MergeParamSchemas(ParamSchema{
+	PositiveFloatParam("radius", 1.0, math.Inf(1), "radius of RBF kernel"),
+	IntParam("sv", 8, 1, math.Inf(1), "support vector count for l1vm"),
+	SeedParam,
+}, FTRLLogisticRegressionParamSchema)
+
+func init() {
+	RegisterAlgorithm(Algorithm{
+		Name: "l1vm",
+		Description: "vector machine with L1 regularization by RBF kernel",
+		Types: AlgorithmTypeEnum.BINARY,
+		Params: L1VMParamSchema,
+		New: func() interface{} { return &(L1VM{}) },
+	})
+}
+
+func (c *L1VM) Init(params map[string]string) error {
+	values, err := L1VMParamSchema.Parse(params)
+	if err != nil {
+		return err
+	}
 	c.ftrl = &(FTRLLogisticRegression{})
-	c.ftrl.Init(params)
-	c.radius, _ = strconv.ParseFloat(params["radius"], 64)
-	count, _ := strconv.ParseInt(params["sv"], 10, 64)
-	c.count = int(count)
+	err = c.ftrl.Init(params)
+	if err != nil {
+		return err
+	}
+	c.radius = values.Float("radius")
+	c.count = values.Int("sv")
+	c.rng = NewRand(int64(values.Int("seed")))
+	c.init_params = params
+	return nil
 }
 
 
@@ -72,7 +173,7 @@ func (c *L1VM) Train(dataset *DataSet) {
 		}
 	}
 
-	perm_positive := rand.Perm(len(positive))
+	perm_positive := c.rng.Perm(len(positive))
 
 	for i, k := range perm_positive {
 		if i > c.count{
@@ -81,7 +182,7 @@ func (c *L1VM) Train(dataset *DataSet) {
 		c.sv = append(c.sv, dataset.Samples[positive[k]].GetFeatureVector())
 	}
 
-	perm_negative := rand.Perm(len(negative))
+	perm_negative := c.rng.Perm(len(negative))
 
 	for i, k := range perm_negative {
 		if i > c.count{
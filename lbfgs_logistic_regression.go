@@ -0,0 +1,161 @@
+package hector
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"strings"
+)
+
+/*
+LogisticLoss is the weighted average log loss of a logistic regression model on a dataset, plus L2 regularization.
+It implements DiffFunction, so the model can be trained by LBFGSMinimizer
+*/
+type LogisticLoss struct {
+	dataset *DataSet
+	l2 float64
+}
+
+func (f *LogisticLoss) Value(pos *Vector) float64 {
+	ret := 0.0
+	for _, sample := range f.dataset.Samples {
+		z := pos.DotFeatures(sample.Features)
+		y := sample.LabelDoubleValue()
+		// log(1 + exp(z)) - y * z, written in a way which does not overflow
+		if z > 0 {
+			ret += sample.GetWeight() * (z + math.Log1p(math.Exp(-z)) - y * z)
+		} else {
+			ret += sample.GetWeight() * (math.Log1p(math.Exp(z)) - y * z)
+		}
+	}
+	ret /= f.dataset.lossWeight()
+	return ret + 0.5 * f.l2 * pos.SortedNormL2()
+}
+
+func (f *LogisticLoss) Gradient(pos *Vector) *Vector {
+	ret := NewVector()
+	n := f.dataset.lossWeight()
+	for _, sample := range f.dataset.Samples {
+		err := sample.GetWeight() * (Sigmoid(pos.DotFeatures(sample.Features)) - sample.LabelDoubleValue())
+		for _, feature := range sample.Features {
+			ret.AddValue(feature.Id, err * feature.Value / n)
+		}
+	}
+	for key, val := range pos.data {
+		ret.AddValue(key, f.l2 * val)
+	}
+	return ret
+}
+
+/*
+LBFGSLogisticRegression trains logistic regression on the whole dataset by L-BFGS, or by OWL-QN
+if l1-regularization is positive, which gives sparse models
+*/
+type LBFGSLogisticRegression struct {
+	Model *Vector
+	L1, L2 float64
+	Settings LBFGSSettings
+	verbose int
+	init_params map[string]string
+}
+
+var LBFGSLogisticRegressionParamSchema = ParamSchema{
+	FloatParam("regularization", 0.01, 0.0, math.Inf(1), "regularization"),
+	FloatParam("l1-regularization", 0.0, 0.0, math.Inf(1), "L1 regularization of lr-lbfgs, OWL-QN is used if it is positive"),
+	IntParam("lbfgs-history", 10, 1, math.Inf(1), "number of corrections kept by L-BFGS"),
+	IntParam("lbfgs-iterations", 200, 1, math.Inf(1), "max iterations of L-BFGS"),
+	PositiveFloatParam("lbfgs-gradient-tolerance", 1e-6, math.Inf(1), "L-BFGS stops when norm of gradient is not larger"),
+	PositiveFloatParam("lbfgs-cost-tolerance", 1e-4, math.Inf(1), "L-BFGS stops when relative improvement of cost is not larger"),
+	VerboseParam,
+}
+
+func init() {
+	RegisterAlgorithm(Algorithm{
+		Name: "lr-lbfgs",
+		Description: "batch logistic regression by L-BFGS with L2 regularization, or OWL-QN with L1 regularization",
+		Types: AlgorithmTypeEnum.BINARY,
+		Params: LBFGSLogisticRegressionParamSchema,
+		New: func() interface{} { return &(LBFGSLogisticRegression{}) },
+	})
+}
+
+func (algo *LBFGSLogisticRegression) Init(params map[string]string) error {
+	algo.Model = NewVector()
+	values, err := LBFGSLogisticRegressionParamSchema.Parse(params)
+	if err != nil {
+		return err
+	}
+	algo.L2 = values.Float("regularization")
+	algo.L1 = values.Float("l1-regularization")
+	algo.Settings = DefaultLBFGSSettings()
+	algo.Settings.HistorySize = values.Int("lbfgs-history")
+	algo.Settings.MaxIterations = values.Int("lbfgs-iterations")
+	algo.Settings.GradientTolerance = values.Float("lbfgs-gradient-tolerance")
+	algo.Settings.RelativeCostTolerance = values.Float("lbfgs-cost-tolerance")
+	algo.verbose = values.Int("verbose")
+	algo.init_params = params
+	return nil
+}
+
+func (algo *LBFGSLogisticRegression) Train(dataset * DataSet) {
+	algo.TrainContext(context.Background(), dataset, nil)
+}
+
+/*
+TrainContext stops L-BFGS after the current iteration when ctx is done, the model is the point reached
+*/
+func (algo *LBFGSLogisticRegression) TrainContext(ctx context.Context, dataset *DataSet, sink ProgressSink) error {
+	if len(dataset.Samples) == 0 {
+		return nil
+	}
+	loss := LogisticLoss{dataset: dataset, l2: algo.L2}
+	minimizer := LBFGSMinimizer{L1: algo.L1, Settings: algo.Settings}
+	minimizer.Callback = contextCallback(ctx, algo.Settings.MaxIterations, sink)
+	result := minimizer.Optimize(&loss, NewVector())
+	logVerbose(algo.verbose, "L-BFGS stops", "reason", result.Reason, "iterations", result.Iterations)
+	algo.Model = result.Position
+	return ctx.Err()
+}
+
+func (algo *LBFGSLogisticRegression) Predict(sample * Sample) float64 {
+	return Sigmoid(algo.Model.DotFeatures(sample.Features))
+}
+
+func (algo *LBFGSLogisticRegression) SaveModel(path string) error {
+	header := NewModelHeader(algo, algo.init_params, len(algo.Model.data))
+	if header.Binary() {
+		w := NewBinaryWriter(header.Format)
+		WeightsToBinary(w, algo.Model.data)
+		return WriteModelFile(path, header, w.Bytes())
+	}
+	sb := StringBuilder{}
+	for f, g := range algo.Model.data {
+		sb.Int64(f)
+		sb.Write("\t")
+		sb.Float(g)
+		sb.Write("\n")
+	}
+	return WriteModelFile(path, header, sb.Bytes())
+}
+
+func (algo *LBFGSLogisticRegression) LoadModel(path string) error {
+	header, body, err := ReadModelBody(path, algo)
+	if err != nil {
+		return err
+	}
+	algo.Model = NewVector()
+	if header.Binary() {
+		r := NewBinaryReader(body, header.Format)
+		WeightsFromBinary(r, algo.Model.data)
+		return r.Err()
+	}
+
+	scaner := NewModelScanner(body)
+	for scaner.Scan() {
+		tks := strings.Split(scaner.Text(), "\t")
+		fid, _ := strconv.ParseInt(tks[0], 10, 64)
+		fw, _ := strconv.ParseFloat(tks[1], 64)
+		algo.Model.SetValue(fid, fw)
+	}
+	return nil
+}
@@ -1,12 +1,112 @@
 package hector
 
-import ("fmt")
+import (
+    "context"
+    "math"
+)
 
-const numHist int = 10
-const maxIteration int = 200
+/*
+LBFGSSettings controls LBFGSMinimizer. Zero fields take values of DefaultLBFGSSettings.
+Minimization stops when the norm of gradient is not larger than GradientTolerance, or
+relative improvement of cost is not larger than RelativeCostTolerance. Line search is
+backtracking with sufficient decrease constant C1, it multiplies step by Backoff, or by
+InitialBackoff in the first iteration, at most MaxBacktracking times
+*/
+type LBFGSSettings struct {
+    HistorySize int
+    MaxIterations int
+    GradientTolerance float64
+    RelativeCostTolerance float64
+    MaxBacktracking int
+    C1 float64
+    Backoff float64
+    InitialBackoff float64
+}
+
+func DefaultLBFGSSettings() LBFGSSettings {
+    return LBFGSSettings{
+        HistorySize: 10,
+        MaxIterations: 200,
+        GradientTolerance: 1e-6,
+        RelativeCostTolerance: 1e-4,
+        MaxBacktracking: MAX_BACKTRACKING_ITER,
+        C1: 1e-4,
+        Backoff: 0.5,
+        InitialBackoff: 0.1,
+    }
+}
+
+func (s LBFGSSettings) withDefaults() LBFGSSettings {
+    d := DefaultLBFGSSettings()
+    if s.HistorySize <= 0 {
+        s.HistorySize = d.HistorySize
+    }
+    if s.MaxIterations <= 0 {
+        s.MaxIterations = d.MaxIterations
+    }
+    if s.GradientTolerance <= 0 {
+        s.GradientTolerance = d.GradientTolerance
+    }
+    if s.RelativeCostTolerance <= 0 {
+        s.RelativeCostTolerance = d.RelativeCostTolerance
+    }
+    if s.MaxBacktracking <= 0 {
+        s.MaxBacktracking = d.MaxBacktracking
+    }
+    if s.C1 <= 0 {
+        s.C1 = d.C1
+    }
+    if s.Backoff <= 0 {
+        s.Backoff = d.Backoff
+    }
+    if s.InitialBackoff <= 0 {
+        s.InitialBackoff = d.InitialBackoff
+    }
+    return s
+}
+
+type LBFGSTermination int
+
+var LBFGSTerminationEnum = struct {
+    GRADIENT_NORM LBFGSTermination
+    RELATIVE_COST LBFGSTermination
+    MAX_ITERATIONS LBFGSTermination
+    LINE_SEARCH LBFGSTermination
+    CALLBACK LBFGSTermination
+}{0, 1, 2, 3, 4}
+
+func (t LBFGSTermination) String() string {
+    return []string{"gradient norm", "relative cost", "max iterations", "line search", "callback"}[t]
+}
 
+/*
+LBFGSIteration is passed to the callback of LBFGSMinimizer after each iteration
+*/
+type LBFGSIteration struct {
+    Iteration int
+    Cost float64
+    Improvement float64
+    GradientNorm float64
+}
+
+type LBFGSResult struct {
+    Position *Vector
+    Reason LBFGSTermination
+    Iterations int
+    Cost float64
+    GradientNorm float64
+}
+
+/*
+LBFGSMinimizer minimizes a DiffFunction by L-BFGS. If L1 is positive, it minimizes
+f(x) + L1 * |x|_1 by OWL-QN, see "Scalable Training of L1-Regularized Log-Linear Models".
+Callback is called after each iteration if it is not nil, minimization stops if it returns true
+*/
 type LBFGSMinimizer struct {
 	costFun DiffFunction
+	L1 float64
+	Settings LBFGSSettings
+	Callback func(iteration *LBFGSIteration) (stop bool)
 }
 
 type DiffFunction interface {
@@ -15,37 +115,141 @@ type DiffFunction interface {
 }
 
 func (minimizer *LBFGSMinimizer) Minimize(costfun DiffFunction, init *Vector) *Vector {
+    return minimizer.Optimize(costfun, init).Position
+}
+
+func (minimizer *LBFGSMinimizer) Optimize(costfun DiffFunction, init *Vector) *LBFGSResult {
 	minimizer.costFun = costfun;
-    var cost float64 = costfun.Value(init)
+    settings := minimizer.Settings.withDefaults()
+    var cost float64 = minimizer.Evaluate(init)
     var grad *Vector = costfun.Gradient(init).Copy()
     var pos *Vector = init
 
-    var helper *QuasiNewtonHelper = NewQuasiNewtonHelper(numHist, minimizer, pos, grad)
-    fmt.Println("Iter\tcost\timprovement")
-    fmt.Printf("%d\t%eN/A\n", 0, cost)
-    for iter:=1; iter <= maxIteration; iter++ {
-        dir := grad.Copy()
+    var helper *QuasiNewtonHelper = NewQuasiNewtonHelper(settings.HistorySize, minimizer, pos, grad)
+    helper.maxBacktracking = settings.MaxBacktracking
+    helper.c1 = settings.C1
+    helper.backoff = settings.Backoff
+    helper.initialBackoff = settings.InitialBackoff
+
+    result := LBFGSResult{Reason: LBFGSTerminationEnum.MAX_ITERATIONS}
+    pseudoGrad := minimizer.pseudoGradient(pos, grad)
+    result.GradientNorm = math.Sqrt(pseudoGrad.SortedNormL2())
+    for iter:=1; iter <= settings.MaxIterations; iter++ {
+        if result.GradientNorm <= settings.GradientTolerance {
+            result.Reason = LBFGSTerminationEnum.GRADIENT_NORM
+            break
+        }
+        dir := pseudoGrad.Copy()
         dir.ApplyScale(-1.0)
         helper.ApplyQuasiInverseHession(dir)
-        newCost, newPos := helper.BackTrackingLineSearch(cost, pos, grad, dir, iter==1)
-        if cost <= newCost {
-            break
+        if minimizer.L1 > 0 {
+            fixDirSigns(dir, pseudoGrad)
         }
-        fmt.Printf("%d\t%e\t%e\n", iter, newCost, (cost-newCost)/cost)
-        if (cost-newCost)/cost <= 0.0001 {
+        newCost, newPos := helper.BackTrackingLineSearch(cost, pos, pseudoGrad, dir, iter==1)
+        if cost <= newCost {
+            result.Reason = LBFGSTerminationEnum.LINE_SEARCH
             break
         }
+        improvement := (cost-newCost)/math.Abs(cost)
         cost = newCost
         pos = newPos
         grad = costfun.Gradient(pos).Copy()
+        helper.updateState(pos, grad)
+        pseudoGrad = minimizer.pseudoGradient(pos, grad)
+        result.Iterations = iter
+        result.GradientNorm = math.Sqrt(pseudoGrad.SortedNormL2())
+        if minimizer.Callback != nil {
+            stop := minimizer.Callback(&(LBFGSIteration{Iteration: iter, Cost: cost, Improvement: improvement, GradientNorm: result.GradientNorm}))
+            if stop {
+                result.Reason = LBFGSTerminationEnum.CALLBACK
+                break
+            }
+        }
+        if improvement <= settings.RelativeCostTolerance {
+            result.Reason = LBFGSTerminationEnum.RELATIVE_COST
+            break
+        }
     }
-	return pos
+    result.Position = pos
+    result.Cost = cost
+	return &result
 }
 
 func (m *LBFGSMinimizer) Evaluate(pos *Vector) float64 {
-	return m.costFun.Value(pos)
+	cost := m.costFun.Value(pos)
+	if m.L1 > 0 {
+		// summed in order of keys, so models are reproducible
+		for _, key := range pos.SortedKeys() {
+			val := pos.data[key]
+			if val > 0 {
+				cost += m.L1 * val
+			} else {
+				cost -= m.L1 * val
+			}
+		}
+	}
+	return cost
 }
 
+/*
+NextPoint moves along dir. In OWL-QN, coordinates which cross zero are set to zero, so the
+point stays in the orthant of curPos. dir has been fixed to the sign of -pseudo gradient, so
+coordinates which are zero in curPos move to the orthant chosen by pseudo gradient
+*/
 func (m *LBFGSMinimizer) NextPoint(curPos *Vector, dir *Vector, alpha float64) *Vector {
-    return curPos.ElemWiseMultiplyAdd(dir, alpha)
-}
\ No newline at end of file
+    ret := curPos.ElemWiseMultiplyAdd(dir, alpha)
+    if m.L1 > 0 {
+        for key, val := range ret.data {
+            orthant := Signum(curPos.GetValue(key))
+            if orthant == 0 {
+                orthant = Signum(dir.GetValue(key))
+            }
+            if Signum(val) != orthant {
+                delete(ret.data, key)
+            }
+        }
+    }
+    return ret
+}
+
+/*
+pseudoGradient returns the gradient of f(x) + L1 * |x|_1, at zero coordinates it takes the
+one-sided derivative which decreases the cost, or zero if none does
+*/
+func (m *LBFGSMinimizer) pseudoGradient(pos *Vector, grad *Vector) *Vector {
+    if m.L1 <= 0 {
+        return grad
+    }
+    ret := NewVector()
+    for key, g := range grad.data {
+        val := pos.GetValue(key)
+        if val > 0 {
+            ret.SetValue(key, g + m.L1)
+        } else if val < 0 {
+            ret.SetValue(key, g - m.L1)
+        } else if g + m.L1 < 0 {
+            ret.SetValue(key, g + m.L1)
+        } else if g - m.L1 > 0 {
+            ret.SetValue(key, g - m.L1)
+        }
+    }
+    return ret
+}
+
+func fixDirSigns(dir *Vector, pseudoGrad *Vector) {
+    for key, val := range dir.data {
+        if val * pseudoGrad.GetValue(key) >= 0 {
+            delete(dir.data, key)
+        }
+    }
+}
+
+/*
+contextCallback is a Callback which sends cost of each iteration to sink, and stops minimization when ctx is done
+*/
+func contextCallback(ctx context.Context, max_iterations int, sink ProgressSink) func(iteration *LBFGSIteration) bool {
+	return func(iteration *LBFGSIteration) bool {
+		sink.Send(TrainEvent{Kind: TrainEventEnum.ITERATION, Step: iteration.Iteration, Total: max_iterations, Metric: "cost", Value: iteration.Cost})
+		return ctx.Err() != nil
+	}
+}
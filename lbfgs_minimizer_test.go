@@ -1,7 +1,6 @@
 package hector
 
 import(
-    "fmt"
     "testing"
     "math"
 )
@@ -24,8 +23,8 @@ func getMSECostFunction() *mseDiffFunction{
 
 func (f *mseDiffFunction) Value(x *Vector) float64 {
     var val float64 = 0
-    for n, val := range x.data {
-		diff := val - f.center.GetValue(n)
+    for n, x := range x.data {
+		diff := x - f.center.GetValue(n)
         val += f.weights.GetValue(n) * diff * diff
     }
     return 0.5 * val
@@ -39,14 +38,10 @@ func (f *mseDiffFunction) Gradient(x *Vector) *Vector {
     return &f.grad
 }	
 
-func (f *mseDiffFunction) testResult(result *Vector, tolerance float64, t *testing.T) {
-	fmt.Println("Index\tTrue\tResult")
-    for n, val := range result.data {
-		fmt.Printf("%d\t%e\t%e\n", n, val, result.GetValue(n))
-	}
-    for n, val := range result.data {
-		if math.Abs(val - f.center.GetValue(n)) <= tolerance {
-			t.Errorf("Mismatch\nIndex\tTrue\tResult\n%d\t%e\t%e", n, f.center.GetValue(n), val)
+func (f *mseDiffFunction) testResult(result *Vector, expect *Vector, tolerance float64, t *testing.T) {
+    for n, val := range expect.data {
+		if math.Abs(val - result.GetValue(n)) > tolerance {
+			t.Errorf("Mismatch\nIndex\tTrue\tResult\n%d\t%e\t%e", n, val, result.GetValue(n))
 		}
 	}
 }
@@ -55,5 +50,47 @@ func TestLBFGS(t *testing.T) {
 	diffFunc := getMSECostFunction()
 	minimizer := new(LBFGSMinimizer)
 	result := minimizer.Minimize(diffFunc, &(diffFunc.init))
-	diffFunc.testResult(result, 1e-6, t)
+	diffFunc.testResult(result, &(diffFunc.center), 1e-6, t)
+}
+
+func TestOWLQN(t *testing.T) {
+	diffFunc := getMSECostFunction()
+	diffFunc.center.data = map[int64]float64 {0:2, 1:1}
+	minimizer := LBFGSMinimizer{L1: 0.1}
+	result := minimizer.Minimize(diffFunc, &(diffFunc.init))
+	// weight of feature 1 is too small to pay for L1, so it becomes zero
+	expect := Vector{data: map[int64]float64 {0:1.9, 1:0}}
+	diffFunc.testResult(result, &expect, 1e-6, t)
+	if _, ok := result.data[1]; ok {
+		t.Error("OWL-QN does not remove zero weight")
+	}
+}
+
+func TestLBFGSSettings(t *testing.T) {
+	diffFunc := getMSECostFunction()
+	iterations := 0
+	minimizer := LBFGSMinimizer{Settings: LBFGSSettings{MaxIterations: 1}}
+	minimizer.Callback = func(iteration *LBFGSIteration) bool {
+		iterations++
+		if iteration.Iteration != iterations {
+			t.Errorf("callback gets iteration %d, expect %d", iteration.Iteration, iterations)
+		}
+		return false
+	}
+	result := minimizer.Optimize(diffFunc, &(diffFunc.init))
+	if result.Reason != LBFGSTerminationEnum.MAX_ITERATIONS || result.Iterations != 1 || iterations != 1 {
+		t.Errorf("minimizer stops by %s after %d iterations, expect max iterations after 1", result.Reason, result.Iterations)
+	}
+
+	minimizer = LBFGSMinimizer{Settings: LBFGSSettings{GradientTolerance: 1e-3}}
+	result = minimizer.Optimize(diffFunc, &(diffFunc.init))
+	if result.GradientNorm > 1e-3 {
+		t.Errorf("gradient norm is %e after minimization, expect not larger than 1e-3", result.GradientNorm)
+	}
+
+	minimizer = LBFGSMinimizer{Callback: func(iteration *LBFGSIteration) bool { return true }}
+	result = minimizer.Optimize(diffFunc, &(diffFunc.init))
+	if result.Reason != LBFGSTerminationEnum.CALLBACK {
+		t.Errorf("minimizer stops by %s, expect callback", result.Reason)
+	}
 }
@@ -2,17 +2,32 @@ package hector
 
 import(
 	"strconv"
-	"os"
-	"bufio"
 	"strings"
 )
 
 type LinearRegression struct {
 	Model map[int64]float64
 	Params LogisticRegressionParams
+	init_params map[string]string
 }
 
-func (algo *LinearRegression) SaveModel(path string) {
+func init() {
+	RegisterAlgorithm(Algorithm{
+		Name: "linear-regression",
+		Description: "linear regression with SGD and L2 regularization",
+		Types: AlgorithmTypeEnum.REGRESSION,
+		Params: LogisticRegressionParamSchema,
+		New: func() interface{} { return &(LinearRegression{}) },
+	})
+}
+
+func (algo *LinearRegression) SaveModel(path string) error {
+	header := NewModelHeader(algo, algo.init_params, len(algo.Model))
+	if header.Binary() {
+		w := NewBinaryWriter(header.Format)
+		WeightsToBinary(w, algo.Model)
+		return WriteModelFile(path, header, w.Bytes())
+	}
 	sb := StringBuilder{}
 	for f, g := range algo.Model {
 		sb.Int64(f)
@@ -20,14 +35,21 @@ func (algo *LinearRegression) SaveModel(path string) {
 		sb.Float(g)
 		sb.Write("\n")
 	}
-	sb.WriteToFile(path)
+	return WriteModelFile(path, header, sb.Bytes())
 }
 
-func (algo *LinearRegression) LoadModel(path string) {
-	file, _ := os.Open(path)
-	defer file.Close()
+func (algo *LinearRegression) LoadModel(path string) error {
+	header, body, err := ReadModelBody(path, algo)
+	if err != nil {
+		return err
+	}
+	if header.Binary() {
+		r := NewBinaryReader(body, header.Format)
+		WeightsFromBinary(r, algo.Model)
+		return r.Err()
+	}
 
-	scaner := bufio.NewScanner(file)
+	scaner := NewModelScanner(body)
 	for scaner.Scan() {
 		line := scaner.Text()
 		tks := strings.Split(line, "\t")
@@ -35,34 +57,53 @@ func (algo *LinearRegression) LoadModel(path string) {
 		fw, _ := strconv.ParseFloat(tks[1], 64)
 		algo.Model[fid] = fw
 	}
+	return nil
 }
 
-func (algo *LinearRegression) Init(params map[string]string) {
+func (algo *LinearRegression) Init(params map[string]string) error {
 	algo.Model = make(map[int64]float64)
 	
-	algo.Params.LearningRate, _ = strconv.ParseFloat(params["learning-rate"], 64)
-	algo.Params.Regularization, _ = strconv.ParseFloat(params["regularization"], 64)
+	values, err := LogisticRegressionParamSchema.Parse(params)
+	if err != nil {
+		return err
+	}
+	algo.Params.LearningRate = values.Float("learning-rate")
+	algo.Params.Regularization = values.Float("regularization")
+	algo.Params.Steps = values.Int("steps")
+	algo.init_params = params
+	return nil
 }
 
 func (algo *LinearRegression) Train(dataset * DataSet) {
 	algo.Model = make(map[int64]float64)
 	for step := 0; step < algo.Params.Steps; step++{
 		for _, sample := range dataset.Samples {
-			prediction := algo.Predict(sample)
-			err := sample.LabelDoubleValue() - prediction
-			for _, feature := range sample.Features {
-				model_feature_value, ok := algo.Model[feature.Id]
-				if !ok {
-					model_feature_value = 0.0
-				}
-				model_feature_value += algo.Params.LearningRate * (err * feature.Value - algo.Params.Regularization * model_feature_value)
-				algo.Model[feature.Id] = model_feature_value
-			}
+			algo.TrainSample(sample)
 		}
 		algo.Params.LearningRate *= 0.9
 	}
 }
 
+func (algo *LinearRegression) TrainOnline(dataset * StreamingDataSet) {
+	for sample := range dataset.Samples {
+		algo.TrainSample(sample)
+	}
+	algo.Params.LearningRate *= 0.9
+}
+
+func (algo *LinearRegression) TrainSample(sample * Sample) {
+	prediction := algo.Predict(sample)
+	err := sample.LabelDoubleValue() - prediction
+	for _, feature := range sample.Features {
+		model_feature_value, ok := algo.Model[feature.Id]
+		if !ok {
+			model_feature_value = 0.0
+		}
+		model_feature_value += algo.Params.LearningRate * (err * feature.Value - algo.Params.Regularization * model_feature_value)
+		algo.Model[feature.Id] = model_feature_value
+	}
+}
+
 func (algo *LinearRegression) Predict(sample * Sample) float64 {
 	ret := 0.0
 	for _, feature := range sample.Features {
@@ -1,12 +1,9 @@
 package hector
 
 import (
+	"context"
 	"math"
 	"strconv"
-	"math/rand"
-	"fmt"
-	"os"
-	"bufio"
 	"strings"
 	"runtime"
 )
@@ -25,9 +22,26 @@ type LinearSVM struct {
 	w *Vector
 
 	xx []float64
+	init_params map[string]string
 }
 
-func (self *LinearSVM) SaveModel(path string){
+func init() {
+	RegisterAlgorithm(Algorithm{
+		Name: "linear_svm",
+		Description: "linear svm with L1 regularization",
+		Types: AlgorithmTypeEnum.BINARY,
+		Params: SVMParamSchema,
+		New: func() interface{} { return &(LinearSVM{}) },
+	})
+}
+
+func (self *LinearSVM) SaveModel(path string) error {
+	header := NewModelHeader(self, self.init_params, len(self.w.data))
+	if header.Binary() {
+		w := NewBinaryWriter(header.Format)
+		self.w.WriteBinary(w)
+		return WriteModelFile(path, header, w.Bytes())
+	}
 	sb := StringBuilder{}
 	for f, g := range self.w.data {
 		sb.Int64(f)
@@ -35,14 +49,21 @@ func (self *LinearSVM) SaveModel(path string){
 		sb.Float(g)
 		sb.Write("\n")
 	}
-	sb.WriteToFile(path)
+	return WriteModelFile(path, header, sb.Bytes())
 }
 
-func (self *LinearSVM) LoadModel(path string){
-	file, _ := os.Open(path)
-	defer file.Close()
+func (self *LinearSVM) LoadModel(path string) error {
+	header, body, err := ReadModelBody(path, self)
+	if err != nil {
+		return err
+	}
+	if header.Binary() {
+		r := NewBinaryReader(body, header.Format)
+		self.w.ReadBinary(r)
+		return r.Err()
+	}
 
-	scaner := bufio.NewScanner(file)
+	scaner := NewModelScanner(body)
 	for scaner.Scan() {
 		line := scaner.Text()
 		tks := strings.Split(line, "\t")
@@ -50,18 +71,25 @@ func (self *LinearSVM) LoadModel(path string){
 		fw, _ := strconv.ParseFloat(tks[1], 64)
 		self.w.SetValue(fid, fw)
 	}
+	return nil
 }
 
-func (c *LinearSVM) Init(params map[string]string){
-	c.C,_ = strconv.ParseFloat(params["c"], 64)
-	c.e,_ = strconv.ParseFloat(params["e"], 64)
+func (c *LinearSVM) Init(params map[string]string) error {
+	values, err := SVMParamSchema.Parse(params)
+	if err != nil {
+		return err
+	}
+	c.C = values.Float("c")
+	c.e = values.Float("e")
 
 	c.w = NewVector()
+	c.init_params = params
+	return nil
 }
 
 func (c *LinearSVM) Predict(sample *Sample) float64 {
-	x := sample.GetFeatureVector()
-	return c.PredictVector(x)
+	// products are summed in order of features, unlike Dot of vectors whose order of maps is random
+	return c.w.DotFeatures(sample.Features)
 }
 
 func (c *LinearSVM) PredictVector(x *Vector) float64 {
@@ -70,27 +98,41 @@ func (c *LinearSVM) PredictVector(x *Vector) float64 {
 }
 
 func (c *LinearSVM) Train(dataset *DataSet) {
+	c.TrainContext(context.Background(), dataset, nil)
+}
+
+/*
+TrainContext stops dual coordinate descent after the current coordinate when ctx is done
+*/
+func (c *LinearSVM) TrainContext(ctx context.Context, dataset *DataSet, sink ProgressSink) error {
+	defer func() {
+		c.sv = nil
+		runtime.GC()
+	}()
 	c.sv = []*Vector{}
 	c.y = []float64{}
 	c.a = []float64{}
 	for k, sample := range dataset.Samples {
 		x := sample.GetFeatureVector()
 		c.sv = append(c.sv, x)
-		c.xx = append(c.xx, x.Dot(x))
+		c.xx = append(c.xx, x.DotFeatures(sample.Features))
 		if sample.Label > 0.0 {
 			c.y = append(c.y, 1.0)
 		} else {
 			c.y = append(c.y, -1.0)
 		}
-		c.a = append(c.a, c.C * rand.Float64() * 0.0)
+		c.a = append(c.a, 0.0)
 		c.w.AddVector(x, c.y[k] * c.a[k])
 	}
 
 	da0 := 0.0
-	for {
+	for iteration := 1; ; iteration++ {
 		da := 0.0
 		for i, ai := range c.a {
-			g := c.y[i] * c.w.Dot(c.sv[i]) - 1.0
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			g := c.y[i] * c.w.DotFeatures(dataset.Samples[i].Features) - 1.0
 			pg := g
 			if ai < 1e-9 {
 				pg = math.Min(0.0, g)
@@ -106,13 +148,11 @@ func (c *LinearSVM) Train(dataset *DataSet) {
 			}
 		}
 		da /= float64(len(c.a))
-		fmt.Println(da)
+		sink.Send(TrainEvent{Kind: TrainEventEnum.ITERATION, Step: iteration, Metric: "multiplier-change", Value: da})
 		if da < c.e || math.Abs(da - da0) < 1e-3 {
 			break
 		}
 		da0 = da
 	}
-
-	c.sv = nil
-	runtime.GC()
+	return nil
 }
\ No newline at end of file
@@ -0,0 +1,139 @@
+package hector
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+type LogLevel int
+
+var LogLevelEnum = struct {
+	DEBUG, INFO, WARN, ERROR LogLevel
+}{0, 1, 2, 3}
+
+func (level LogLevel) String() string {
+	switch level {
+	case LogLevelEnum.DEBUG:
+		return "debug"
+	case LogLevelEnum.INFO:
+		return "info"
+	case LogLevelEnum.WARN:
+		return "warn"
+	case LogLevelEnum.ERROR:
+		return "error"
+	}
+	return "level(" + fmt.Sprint(int(level)) + ")"
+}
+
+/*
+VerboseLogLevel is the least level logged for the verbose param : warnings without verbose, info of
+training with verbose 1, and debug messages with verbose 2 or more
+*/
+func VerboseLogLevel(verbose int) LogLevel {
+	if verbose >= 2 {
+		return LogLevelEnum.DEBUG
+	} else if verbose == 1 {
+		return LogLevelEnum.INFO
+	}
+	return LogLevelEnum.WARN
+}
+
+/*
+Logger receives messages of the library. fields are pairs of key and value, e.g. "iterations", 12.
+It may be called by many goroutines at the same time
+*/
+type Logger interface {
+	Log(level LogLevel, message string, fields ...interface{})
+}
+
+/*
+WriterLogger writes messages not less than Level as lines of W, like "info L-BFGS stops reason=gradient iterations=12"
+*/
+type WriterLogger struct {
+	W io.Writer
+	Level LogLevel
+	lock sync.Mutex
+}
+
+func NewWriterLogger(w io.Writer, level LogLevel) *WriterLogger {
+	return &(WriterLogger{W: w, Level: level})
+}
+
+func (l *WriterLogger) Log(level LogLevel, message string, fields ...interface{}) {
+	if level < l.Level {
+		return
+	}
+	sb := strings.Builder{}
+	sb.WriteString(level.String())
+	sb.WriteString(" ")
+	sb.WriteString(message)
+	for i := 0; i + 1 < len(fields); i += 2 {
+		fmt.Fprintf(&sb, " %v=%v", fields[i], fields[i + 1])
+	}
+	if len(fields) % 2 == 1 {
+		fmt.Fprintf(&sb, " %v", fields[len(fields) - 1])
+	}
+	sb.WriteString("\n")
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	io.WriteString(l.W, sb.String())
+}
+
+/*
+NopLogger drops all messages
+*/
+type NopLogger struct{}
+
+func (l NopLogger) Log(level LogLevel, message string, fields ...interface{}) {}
+
+var (
+	logger Logger = NewWriterLogger(os.Stderr, LogLevelEnum.WARN)
+	logger_lock sync.RWMutex
+)
+
+/*
+SetLogger replaces the logger of the library and returns the previous one. By default, warnings and
+errors are written to stderr, and nothing is written to stdout
+*/
+func SetLogger(l Logger) Logger {
+	if l == nil {
+		l = NopLogger{}
+	}
+	logger_lock.Lock()
+	defer logger_lock.Unlock()
+	prev := logger
+	logger = l
+	return prev
+}
+
+func GetLogger() Logger {
+	logger_lock.RLock()
+	defer logger_lock.RUnlock()
+	return logger
+}
+
+func logDebug(message string, fields ...interface{}) {
+	GetLogger().Log(LogLevelEnum.DEBUG, message, fields...)
+}
+
+func logInfo(message string, fields ...interface{}) {
+	GetLogger().Log(LogLevelEnum.INFO, message, fields...)
+}
+
+func logWarn(message string, fields ...interface{}) {
+	GetLogger().Log(LogLevelEnum.WARN, message, fields...)
+}
+
+/*
+logVerbose logs info of training if verbose is set for the classifier, and debug messages otherwise
+*/
+func logVerbose(verbose int, message string, fields ...interface{}) {
+	level := LogLevelEnum.DEBUG
+	if verbose > 0 {
+		level = LogLevelEnum.INFO
+	}
+	GetLogger().Log(level, message, fields...)
+}
@@ -0,0 +1,55 @@
+package hector
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+type recordLogger struct {
+	messages []string
+}
+
+func (l *recordLogger) Log(level LogLevel, message string, fields ...interface{}) {
+	l.messages = append(l.messages, level.String() + " " + message)
+}
+
+func TestLogger(t *testing.T) {
+	records := recordLogger{}
+	prev := SetLogger(&records)
+	defer SetLogger(prev)
+
+	// training does not write to stdout, messages go to the logger
+	read, write, _ := os.Pipe()
+	stdout := os.Stdout
+	os.Stdout = write
+	params := DefaultParams()
+	params["max-depth"] = "3"
+	params["verbose"] = "1"
+	for _, method := range []string{"cart", "svm", "sa", "lr-lbfgs"} {
+		classifier, _ := GetClassifier(method)
+		classifier.Init(params)
+		classifier.Train(LinearDataSet(200))
+	}
+	os.Stdout = stdout
+	write.Close()
+	out, _ := ioutil.ReadAll(read)
+	if len(out) > 0 {
+		t.Errorf("training writes %q to stdout", out)
+	}
+	expected := []string{"debug dataset of cart", "debug initial bias of svm", "debug model of sa", "info L-BFGS stops"}
+	if strings.Join(records.messages, "|") != strings.Join(expected, "|") {
+		t.Errorf("messages of training are %v", records.messages)
+	}
+
+	buffer := bytes.Buffer{}
+	logger := NewWriterLogger(&buffer, VerboseLogLevel(1))
+	logger.Log(LogLevelEnum.DEBUG, "dropped")
+	logger.Log(LogLevelEnum.INFO, "L-BFGS stops", "reason", "gradient", "iterations", 12)
+	logger.Log(LogLevelEnum.WARN, "odd", "field")
+	if buffer.String() != "info L-BFGS stops reason=gradient iterations=12\nwarn odd field\n" {
+		t.Errorf("messages are written as %q", buffer.String())
+	}
+}
@@ -1,10 +1,9 @@
 package hector
 
 import(
+	"math"
 	"strconv"
-	"os"
 	"strings"
-	"bufio"
 )
 
 type LogisticRegressionParams struct {
@@ -16,9 +15,16 @@ type LogisticRegressionParams struct {
 type LogisticRegression struct {
 	Model map[int64]float64
 	Params LogisticRegressionParams
+	init_params map[string]string
 }
 
-func (algo *LogisticRegression) SaveModel(path string) {
+func (algo *LogisticRegression) SaveModel(path string) error {
+	header := NewModelHeader(algo, algo.init_params, len(algo.Model))
+	if header.Binary() {
+		w := NewBinaryWriter(header.Format)
+		WeightsToBinary(w, algo.Model)
+		return WriteModelFile(path, header, w.Bytes())
+	}
 	sb := StringBuilder{}
 	for f, g := range algo.Model {
 		sb.Int64(f)
@@ -26,14 +32,21 @@ func (algo *LogisticRegression) SaveModel(path string) {
 		sb.Float(g)
 		sb.Write("\n")
 	}
-	sb.WriteToFile(path)
+	return WriteModelFile(path, header, sb.Bytes())
 }
 
-func (algo *LogisticRegression) LoadModel(path string) {
-	file, _ := os.Open(path)
-	defer file.Close()
+func (algo *LogisticRegression) LoadModel(path string) error {
+	header, body, err := ReadModelBody(path, algo)
+	if err != nil {
+		return err
+	}
+	if header.Binary() {
+		r := NewBinaryReader(body, header.Format)
+		WeightsFromBinary(r, algo.Model)
+		return r.Err()
+	}
 
-	scaner := bufio.NewScanner(file)
+	scaner := NewModelScanner(body)
 	for scaner.Scan() {
 		line := scaner.Text()
 		tks := strings.Split(line, "\t")
@@ -41,36 +54,70 @@ func (algo *LogisticRegression) LoadModel(path string) {
 		fw, _ := strconv.ParseFloat(tks[1], 64)
 		algo.Model[fid] = fw
 	}
+	return nil
+}
+
+var LogisticRegressionParamSchema = ParamSchema{
+	PositiveFloatParam("learning-rate", 0.01, math.Inf(1), "learning rate"),
+	FloatParam("regularization", 0.01, 0.0, math.Inf(1), "regularization"),
+	IntParam("steps", 1, 1, math.Inf(1), "steps before convergent"),
 }
 
-func (algo *LogisticRegression) Init(params map[string]string) {
+func init() {
+	RegisterAlgorithm(Algorithm{
+		Name: "lr",
+		Description: "logistic regression with SGD and L2 regularization",
+		Types: AlgorithmTypeEnum.BINARY,
+		Params: LogisticRegressionParamSchema,
+		New: func() interface{} { return &(LogisticRegression{}) },
+	})
+}
+
+func (algo *LogisticRegression) Init(params map[string]string) error {
 	algo.Model = make(map[int64]float64)
 	
-	algo.Params.LearningRate, _ = strconv.ParseFloat(params["learning-rate"], 64)
-	algo.Params.Regularization, _ = strconv.ParseFloat(params["regularization"], 64)
-	steps, _ := strconv.ParseInt(params["steps"], 10, 32)
-	algo.Params.Steps = int(steps)
+	values, err := LogisticRegressionParamSchema.Parse(params)
+	if err != nil {
+		return err
+	}
+	algo.Params.LearningRate = values.Float("learning-rate")
+	algo.Params.Regularization = values.Float("regularization")
+	algo.Params.Steps = values.Int("steps")
+	algo.init_params = params
+	return nil
 }
 
 func (algo *LogisticRegression) Train(dataset * DataSet) {
 	algo.Model = make(map[int64]float64)
 	for step := 0; step < algo.Params.Steps; step++{
 		for _, sample := range dataset.Samples {
-			prediction := algo.Predict(sample)
-			err := sample.LabelDoubleValue() - prediction
-			for _, feature := range sample.Features {
-				model_feature_value, ok := algo.Model[feature.Id]
-				if !ok {
-					model_feature_value = 0.0
-				}
-				model_feature_value += algo.Params.LearningRate * (err * feature.Value - algo.Params.Regularization * model_feature_value)
-				algo.Model[feature.Id] = model_feature_value
-			}
+			algo.TrainSample(sample)
 		}
 		algo.Params.LearningRate *= 0.9
 	}
 }
 
+func (algo *LogisticRegression) TrainOnline(dataset * StreamingDataSet) {
+	for sample := range dataset.Samples {
+		algo.TrainSample(sample)
+	}
+	algo.Params.LearningRate *= 0.9
+}
+
+func (algo *LogisticRegression) TrainSample(sample * Sample) {
+	prediction := algo.Predict(sample)
+	// gradients of the sample are scaled by its weight
+	err := (sample.LabelDoubleValue() - prediction) * sample.GetWeight()
+	for _, feature := range sample.Features {
+		model_feature_value, ok := algo.Model[feature.Id]
+		if !ok {
+			model_feature_value = 0.0
+		}
+		model_feature_value += algo.Params.LearningRate * (err * feature.Value - algo.Params.Regularization * model_feature_value)
+		algo.Model[feature.Id] = model_feature_value
+	}
+}
+
 func (algo *LogisticRegression) Predict(sample * Sample) float64 {
 	ret := 0.0
 	for _, feature := range sample.Features {
@@ -2,6 +2,8 @@ package hector
 
 import (
 	"math"
+	"math/rand"
+	"time"
 	"strconv"
 )
 
@@ -109,4 +111,33 @@ func (g *Gaussian) LowerTruncateGaussian(mean, vari, s float64){
 	}
 	g.mean = mean
 	g.vari = vari
-}
\ No newline at end of file
+}
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+/*
+NewRand returns a random generator of seed, or of current time if seed is 0. Each model has its own
+generator instead of the global one of math/rand, so models are reproducible by seed
+*/
+func NewRand(seed int64) *rand.Rand {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return rand.New(rand.NewSource(seed))
+}
+
+/*
+NewRands returns n random generators seeded by rng, one for each goroutine, e.g. goroutines building trees
+of forests, since generators are not safe for concurrent use
+*/
+func NewRands(rng *rand.Rand, n int) []*rand.Rand {
+	ret := make([]*rand.Rand, n)
+	for i := range ret {
+		ret[i] = rand.New(rand.NewSource(rng.Int63()))
+	}
+	return ret
+}
@@ -1,5 +1,10 @@
 package hector
 
+import (
+	"strconv"
+	"strings"
+)
+
 type Matrix struct {
 	data map[int64]*Vector
 }
@@ -10,6 +15,53 @@ func NewMatrix() *Matrix {
 	return &m
 }
 
+/*
+ToString writes one line per row : row id and the row vector
+*/
+func (m *Matrix) ToString() []byte {
+	sb := StringBuilder{}
+	for key, row := range m.data {
+		sb.Int64(key)
+		sb.Write("\t")
+		sb.WriteBytes(row.ToString())
+		sb.Write("\n")
+	}
+	return sb.Bytes()
+}
+
+func (m *Matrix) FromString(buf string) {
+	for _, line := range strings.Split(buf, "\n") {
+		if len(line) == 0 {
+			continue
+		}
+		tks := strings.Split(line, "\t")
+		key, _ := strconv.ParseInt(tks[0], 10, 64)
+		row := NewVector()
+		if len(tks) > 1 {
+			row.FromString(tks[1])
+		}
+		m.data[key] = row
+	}
+}
+
+func (m *Matrix) WriteBinary(w *BinaryWriter) {
+	w.Int(len(m.data))
+	for key, row := range m.data {
+		w.Varint(key)
+		row.WriteBinary(w)
+	}
+}
+
+func (m *Matrix) ReadBinary(r *BinaryReader) {
+	n := r.Len()
+	for i := 0; i < n; i++ {
+		key := r.Varint()
+		row := NewVector()
+		row.ReadBinary(r)
+		m.data[key] = row
+	}
+}
+
 func (m *Matrix) AddValue(k1, k2 int64, v float64){
 	_, ok := m.data[k1]
 	if !ok {
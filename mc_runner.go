@@ -9,11 +9,19 @@ Currently, it only support algorithms which can solve binary classification prob
 package hector
 
 import (
+	"context"
 	"strconv"
 	"os"
 )
 
 func MultiClassRun(classifier MultiClassClassifier, train_path string, test_path string, pred_path string, params map[string]string) (float64, error) {
+	return MultiClassRunContext(context.Background(), classifier, train_path, test_path, pred_path, params, nil)
+}
+
+/*
+MultiClassRunContext is MultiClassRun which stops training when ctx is done and sends progress of training to sink
+*/
+func MultiClassRunContext(ctx context.Context, classifier MultiClassClassifier, train_path string, test_path string, pred_path string, params map[string]string, sink ProgressSink) (float64, error) {
 	global, _ := strconv.ParseInt(params["global"], 10, 64)
 	train_dataset := NewDataSet()
 
@@ -28,13 +36,26 @@ func MultiClassRun(classifier MultiClassClassifier, train_path string, test_path
 	if err != nil{
 		return 0.5, err
 	}
-	classifier.Init(params)
-	accuracy := MultiClassRunOnDataSet(classifier, train_dataset, test_dataset, pred_path, params)
-
-	return accuracy, nil
+	err = classifier.Init(params)
+	if err != nil{
+		return 0.5, err
+	}
+	err = LoadValidation(classifier, params)
+	if err != nil{
+		return 0.5, err
+	}
+	return MultiClassRunOnDataSetContext(ctx, classifier, train_dataset, test_dataset, pred_path, params, sink)
 }
 
 func MultiClassTrain(classifier MultiClassClassifier, train_path string, params map[string]string) (error) {
+	return MultiClassTrainContext(context.Background(), classifier, train_path, params, nil)
+}
+
+/*
+MultiClassTrainContext is MultiClassTrain which stops training when ctx is done and sends progress of training
+to sink. The partial model of a ContextClassifier is still saved to the model file, and ctx.Err() is returned
+*/
+func MultiClassTrainContext(ctx context.Context, classifier MultiClassClassifier, train_path string, params map[string]string, sink ProgressSink) (error) {
 	global, _ := strconv.ParseInt(params["global"], 10, 64)
 	train_dataset := NewDataSet()
 
@@ -44,31 +65,54 @@ func MultiClassTrain(classifier MultiClassClassifier, train_path string, params
 		return err
 	}
 
-	classifier.Init(params)
-	classifier.Train(train_dataset)
+	err = classifier.Init(params)
+	if err != nil{
+		return err
+	}
+	err = LoadValidation(classifier, params)
+	if err != nil{
+		return err
+	}
+	err = TrainWithContext(ctx, classifier, train_dataset, sink)
+	if _, ok := classifier.(ContextClassifier); err != nil && !ok {
+		return err
+	}
 
 	model_path, _ := params["model"]
 
 	if model_path != "" {
-		classifier.SaveModel(model_path)
+		save_err := classifier.SaveModel(model_path)
+		if save_err != nil {
+			return save_err
+		}
 	}
 
-	return nil
+	return err
 }
 
 func MultiClassTest(classifier MultiClassClassifier, test_path string, pred_path string, params map[string]string) (float64, error) {
 	global, _ := strconv.ParseInt(params["global"], 10, 64)
 	
 	model_path, _ := params["model"]
-	classifier.Init(params)
+	err := classifier.Init(params)
+	if err != nil{
+		return 0.0, err
+	}
 	if model_path != "" {
-		classifier.LoadModel(model_path)
+		err = classifier.LoadModel(model_path)
+		if err != nil{
+			return 0.0, err
+		}
+		header, err := ReadModelHeader(model_path)
+		if err == nil {
+			global = header.Global
+		}
 	} else {
 		return 0.0, nil
 	}
 
 	test_dataset := NewDataSet()
-	err := test_dataset.Load(test_path, global)
+	err = test_dataset.Load(test_path, global)
 	if err != nil{
 		return 0.0, err
 	}
@@ -79,9 +123,20 @@ func MultiClassTest(classifier MultiClassClassifier, test_path string, pred_path
 }
 
 func MultiClassRunOnDataSet(classifier MultiClassClassifier, train_dataset, test_dataset *DataSet, pred_path string, params map[string]string) float64 {
-	
+	accuracy, _ := MultiClassRunOnDataSetContext(context.Background(), classifier, train_dataset, test_dataset, pred_path, params, nil)
+	return accuracy
+}
+
+/*
+MultiClassRunOnDataSetContext is MultiClassRunOnDataSet which stops training when ctx is done and sends progress
+of training to sink. The test dataset is not predicted by models whose training is stopped
+*/
+func MultiClassRunOnDataSetContext(ctx context.Context, classifier MultiClassClassifier, train_dataset, test_dataset *DataSet, pred_path string, params map[string]string, sink ProgressSink) (float64, error) {
 	if train_dataset != nil {
-		classifier.Train(train_dataset)
+		err := TrainWithContext(ctx, classifier, train_dataset, sink)
+		if err != nil {
+			return 0.0, err
+		}
 	}
 
 	var pred_file *os.File
@@ -105,5 +160,5 @@ func MultiClassRunOnDataSet(classifier MultiClassClassifier, train_dataset, test
 		defer pred_file.Close()
 	}
 		
-	return accuracy / total
+	return accuracy / total, nil
 }
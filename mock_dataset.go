@@ -50,4 +50,26 @@ func LinearDataSet(n int) *DataSet {
 		ret.AddSample(sample)
 	}
 	return ret
-}
\ No newline at end of file
+}
+/*
+MultiClassDataSet has labels from 0 to classes - 1, features 10 * k to 10 * k + 9 are likely
+to appear in samples of label k
+*/
+func MultiClassDataSet(n int, classes int) *DataSet {
+	ret := NewDataSet()
+	for i := 0; i < n; i++ {
+		sample := NewSample()
+		sample.Label = rand.Intn(classes)
+		for f := 0; f < 10 * classes; f++ {
+			p := 0.05
+			if f / 10 == sample.Label {
+				p = 0.5
+			}
+			if rand.Float64() < p {
+				sample.AddFeature(Feature{Id: int64(f), Value: 1.0})
+			}
+		}
+		ret.AddSample(sample)
+	}
+	return ret
+}
@@ -0,0 +1,199 @@
+package hector
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+const MODEL_FORMAT_TEXT = "text"
+const MODEL_FORMAT_BINARY = "binary"
+const MODEL_FORMAT_BINARY32 = "binary32"
+
+/*
+BinaryWriter encodes model bodies of binary model files. Integers are varints, weights written
+by Float are float64, or float32 in binary32 format. Float64 is always exact, it is used for split
+values of trees which must compare equal to feature values
+*/
+type BinaryWriter struct {
+	buf bytes.Buffer
+	float32_weights bool
+	tmp [binary.MaxVarintLen64]byte
+}
+
+func NewBinaryWriter(format string) *BinaryWriter {
+	return &(BinaryWriter{float32_weights: format == MODEL_FORMAT_BINARY32})
+}
+
+func (w *BinaryWriter) Varint(value int64) *BinaryWriter {
+	n := binary.PutVarint(w.tmp[:], value)
+	w.buf.Write(w.tmp[:n])
+	return w
+}
+
+func (w *BinaryWriter) Int(value int) *BinaryWriter {
+	return w.Varint(int64(value))
+}
+
+func (w *BinaryWriter) Float64(value float64) *BinaryWriter {
+	binary.LittleEndian.PutUint64(w.tmp[:8], math.Float64bits(value))
+	w.buf.Write(w.tmp[:8])
+	return w
+}
+
+func (w *BinaryWriter) Float(value float64) *BinaryWriter {
+	if !w.float32_weights {
+		return w.Float64(value)
+	}
+	binary.LittleEndian.PutUint32(w.tmp[:4], math.Float32bits(float32(value)))
+	w.buf.Write(w.tmp[:4])
+	return w
+}
+
+/*
+WriteBytes writes length prefixed bytes, it is used to nest model bodies
+*/
+func (w *BinaryWriter) WriteBytes(value []byte) *BinaryWriter {
+	w.Int(len(value))
+	w.buf.Write(value)
+	return w
+}
+
+func (w *BinaryWriter) Bytes() []byte {
+	return w.buf.Bytes()
+}
+
+/*
+BinaryReader decodes what BinaryWriter encodes. The first error is kept and returned by Err,
+reads after an error return zero values. Version is the format version of the model file, readers
+of shared structures like trees use it to read files of older versions
+*/
+type BinaryReader struct {
+	reader *bytes.Reader
+	float32_weights bool
+	version int
+	err error
+}
+
+func NewBinaryReader(body []byte, format string) *BinaryReader {
+	return &(BinaryReader{reader: bytes.NewReader(body), float32_weights: format == MODEL_FORMAT_BINARY32, version: MODEL_FORMAT_VERSION})
+}
+
+/*
+WithVersion sets the format version of the model file, it is the current version by default
+*/
+func (r *BinaryReader) WithVersion(version int) *BinaryReader {
+	r.version = version
+	return r
+}
+
+func (r *BinaryReader) Version() int {
+	return r.version
+}
+
+func (r *BinaryReader) fail(err error) {
+	if r.err == nil {
+		r.err = errors.New("binary model is damaged: " + err.Error())
+	}
+}
+
+func (r *BinaryReader) Varint() int64 {
+	if r.err != nil {
+		return 0
+	}
+	value, err := binary.ReadVarint(r.reader)
+	if err != nil {
+		r.fail(err)
+	}
+	return value
+}
+
+func (r *BinaryReader) Int() int {
+	return int(r.Varint())
+}
+
+/*
+Len reads a count of following items, each item takes at least one byte, so a count larger than
+the remaining bytes means the body is damaged. It protects loaders from huge allocations
+*/
+func (r *BinaryReader) Len() int {
+	n := r.Varint()
+	if n < 0 || n > int64(r.reader.Len()) {
+		r.fail(errors.New("bad length"))
+		return 0
+	}
+	return int(n)
+}
+
+func (r *BinaryReader) Float64() float64 {
+	var buf [8]byte
+	if r.read(buf[:]) {
+		return math.Float64frombits(binary.LittleEndian.Uint64(buf[:]))
+	}
+	return 0.0
+}
+
+func (r *BinaryReader) Float() float64 {
+	if !r.float32_weights {
+		return r.Float64()
+	}
+	var buf [4]byte
+	if r.read(buf[:]) {
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(buf[:])))
+	}
+	return 0.0
+}
+
+func (r *BinaryReader) ReadBytes() []byte {
+	ret := make([]byte, r.Len())
+	r.read(ret)
+	return ret
+}
+
+func (r *BinaryReader) read(buf []byte) bool {
+	if r.err != nil {
+		return false
+	}
+	_, err := io.ReadFull(r.reader, buf)
+	if err != nil {
+		r.fail(err)
+		return false
+	}
+	return true
+}
+
+func (r *BinaryReader) Err() error {
+	return r.err
+}
+
+/*
+NewModelScanner scans lines of a text model body. Lines are not limited to the 64KB of
+bufio.Scanner, as trees with large prediction vectors and support vectors can be longer
+*/
+func NewModelScanner(body []byte) *bufio.Scanner {
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 0, 64 * 1024), len(body) + 1)
+	return scanner
+}
+
+/*
+WeightsToBinary writes weights of linear models : count, then fid and weight of each feature
+*/
+func WeightsToBinary(w *BinaryWriter, weights map[int64]float64) {
+	w.Int(len(weights))
+	for fid, weight := range weights {
+		w.Varint(fid)
+		w.Float(weight)
+	}
+}
+
+func WeightsFromBinary(r *BinaryReader, weights map[int64]float64) {
+	n := r.Len()
+	for i := 0; i < n; i++ {
+		fid := r.Varint()
+		weights[fid] = r.Float()
+	}
+}
@@ -0,0 +1,317 @@
+package hector
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const MODEL_FILE_MAGIC = "#hector-model"
+const MODEL_FILE_HEADER_END = "#end-header"
+const MODEL_FORMAT_VERSION = 7
+
+/*
+ModelHeader is written before the body of every model file, so a model file tells which
+algorithm and params produced it. Checksum is crc32 of the body, Format tells whether the
+body is text or binary, see BinaryWriter
+*/
+type ModelHeader struct {
+	Algorithm string
+	Version int
+	Format string
+	Params map[string]string
+	Created time.Time
+	Global int64
+	FeatureCount int
+	Checksum uint32
+}
+
+/*
+NewModelHeader finds the registered name of model and keeps the params of its schema.
+The body format is taken from param model-format
+*/
+func NewModelHeader(model interface{}, params map[string]string, feature_count int) *ModelHeader {
+	ret := ModelHeader{Version: MODEL_FORMAT_VERSION, Params: make(map[string]string), Created: time.Now().UTC(), Global: -1, FeatureCount: feature_count}
+	algo := AlgorithmOf(model)
+	if algo != nil {
+		ret.Algorithm = algo.Name
+		for _, spec := range algo.Params {
+			value, ok := params[spec.Name]
+			if ok {
+				ret.Params[spec.Name] = value
+			}
+		}
+	}
+	global, err := strconv.ParseInt(params["global"], 10, 64)
+	if err == nil {
+		ret.Global = global
+	}
+	ret.Format = params["model-format"]
+	if ret.Format == "" {
+		ret.Format = MODEL_FORMAT_TEXT
+	}
+	return &ret
+}
+
+func (h *ModelHeader) Binary() bool {
+	return h.Format == MODEL_FORMAT_BINARY || h.Format == MODEL_FORMAT_BINARY32
+}
+
+func (h *ModelHeader) ToString() []byte {
+	sb := StringBuilder{}
+	sb.Write(MODEL_FILE_MAGIC, "\n")
+	sb.Write("algorithm\t", h.Algorithm, "\n")
+	sb.Write("version\t").Int(h.Version).Write("\n")
+	sb.Write("format\t", h.Format, "\n")
+	sb.Write("created\t", h.Created.Format(time.RFC3339), "\n")
+	sb.Write("global\t").Int64(h.Global).Write("\n")
+	sb.Write("features\t").Int(h.FeatureCount).Write("\n")
+	sb.Write("checksum\t", strconv.FormatUint(uint64(h.Checksum), 16), "\n")
+	names := []string{}
+	for name, _ := range h.Params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		sb.Write("param\t", name, "\t", h.Params[name], "\n")
+	}
+	sb.Write(MODEL_FILE_HEADER_END, "\n")
+	return sb.Bytes()
+}
+
+func (h *ModelHeader) parseLine(line string) error {
+	tks := strings.Split(line, "\t")
+	if len(tks) < 2 {
+		return errors.New("bad model header line: " + line)
+	}
+	var err error
+	switch tks[0] {
+	case "algorithm":
+		h.Algorithm = tks[1]
+	case "version":
+		h.Version, err = strconv.Atoi(tks[1])
+	case "format":
+		h.Format = tks[1]
+	case "created":
+		h.Created, err = time.Parse(time.RFC3339, tks[1])
+	case "global":
+		h.Global, err = strconv.ParseInt(tks[1], 10, 64)
+	case "features":
+		h.FeatureCount, err = strconv.Atoi(tks[1])
+	case "checksum":
+		var checksum uint64
+		checksum, err = strconv.ParseUint(tks[1], 16, 32)
+		h.Checksum = uint32(checksum)
+	case "param":
+		if len(tks) < 3 {
+			return errors.New("bad model header line: " + line)
+		}
+		h.Params[tks[1]] = tks[2]
+	}
+	if err != nil {
+		return errors.New("bad model header line: " + line)
+	}
+	return nil
+}
+
+func WriteModelFile(path string, header *ModelHeader, body []byte) error {
+	header.Checksum = crc32.ChecksumIEEE(body)
+	sb := StringBuilder{}
+	sb.WriteBytes(header.ToString())
+	sb.WriteBytes(body)
+	return sb.WriteToFile(path)
+}
+
+/*
+ReadModelFile splits a model file into header and body and verifies the checksum.
+Files written before headers existed have no header, then header is nil and body is the whole file
+*/
+func ReadModelFile(path string) (*ModelHeader, []byte, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !bytes.HasPrefix(buf, []byte(MODEL_FILE_MAGIC + "\n")) {
+		return nil, buf, nil
+	}
+	header := ModelHeader{Format: MODEL_FORMAT_TEXT, Params: make(map[string]string)}
+	reader := bufio.NewReader(bytes.NewReader(buf))
+	offset := 0
+	ended := false
+	for !ended {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, nil, errors.New("model header of " + path + " is not complete")
+		}
+		offset += len(line)
+		line = strings.TrimRight(line, "\n")
+		if line == MODEL_FILE_MAGIC {
+			continue
+		} else if line == MODEL_FILE_HEADER_END {
+			ended = true
+		} else {
+			err = header.parseLine(line)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	if header.Version > MODEL_FORMAT_VERSION {
+		return nil, nil, fmt.Errorf("model file %s has format version %d, this library reads up to %d", path, header.Version, MODEL_FORMAT_VERSION)
+	}
+	if header.Format != MODEL_FORMAT_TEXT && !header.Binary() {
+		return nil, nil, fmt.Errorf("model file %s has unknown format %s", path, header.Format)
+	}
+	body := buf[offset:]
+	if crc32.ChecksumIEEE(body) != header.Checksum {
+		return nil, nil, errors.New("checksum of model file " + path + " does not match, file is damaged")
+	}
+	return &header, body, nil
+}
+
+func ReadModelHeader(path string) (*ModelHeader, error) {
+	header, _, err := ReadModelFile(path)
+	if err == nil && header == nil {
+		err = errors.New(path + " has no model header")
+	}
+	return header, err
+}
+
+/*
+ReadModelBody reads the body of a model file and fails if the file was written by another algorithm.
+Files without header get a header of text format
+*/
+func ReadModelBody(path string, model interface{}) (*ModelHeader, []byte, error) {
+	header, body, err := ReadModelFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if header == nil {
+		return &(ModelHeader{Format: MODEL_FORMAT_TEXT, Params: make(map[string]string), Global: -1}), body, nil
+	}
+	algo := AlgorithmOf(model)
+	if algo != nil && algo.Name != header.Algorithm {
+		return nil, nil, fmt.Errorf("model file %s is written by %s, can not be loaded by %s", path, header.Algorithm, algo.Name)
+	}
+	return header, body, nil
+}
+
+func loadModelByHeader(path string) (interface{}, error) {
+	header, err := ReadModelHeader(path)
+	if err != nil {
+		return nil, err
+	}
+	algo, err := GetAlgorithm(header.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	params := DefaultParams()
+	for name, value := range header.Params {
+		params[name] = value
+	}
+	params["global"] = strconv.FormatInt(header.Global, 10)
+	model := algo.New()
+	if algo.Supports(AlgorithmTypeEnum.BINARY | AlgorithmTypeEnum.REGRESSION) {
+		classifier := model.(Classifier)
+		err = classifier.Init(params)
+		if err == nil {
+			err = classifier.LoadModel(path)
+		}
+	} else {
+		classifier := model.(MultiClassClassifier)
+		err = classifier.Init(params)
+		if err == nil {
+			err = classifier.LoadModel(path)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+/*
+LoadAnyModel creates the classifier named in the model file header, initializes it with
+the params in header and loads the model
+*/
+func LoadAnyModel(path string) (Classifier, error) {
+	model, err := loadModelByHeader(path)
+	if err != nil {
+		return nil, err
+	}
+	classifier, ok := model.(Classifier)
+	if !ok {
+		return nil, errors.New(path + " is not a model of binary classification or regression")
+	}
+	return classifier, nil
+}
+
+func LoadAnyMultiClassModel(path string) (MultiClassClassifier, error) {
+	model, err := loadModelByHeader(path)
+	if err != nil {
+		return nil, err
+	}
+	classifier, ok := model.(MultiClassClassifier)
+	if !ok {
+		return nil, errors.New(path + " is not a model of multi-class classification")
+	}
+	return classifier, nil
+}
+
+/*
+FeatureCountOfVectors counts distinct features in vectors, it is the feature count of
+models which keep support vectors or weight rows
+*/
+func FeatureCountOfVectors(vectors ...*Vector) int {
+	features := make(map[int64]bool)
+	for _, v := range vectors {
+		for fid, _ := range v.data {
+			features[fid] = true
+		}
+	}
+	return len(features)
+}
+
+/*
+SaveModelBytes saves model to a temporary file and returns the file, header included.
+It is used to nest models of any algorithm in one model file
+*/
+func SaveModelBytes(model interface{ SaveModel(path string) error }) ([]byte, error) {
+	file, err := ioutil.TempFile("", "hector-model")
+	if err != nil {
+		return nil, err
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+	err = model.SaveModel(file.Name())
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(file.Name())
+}
+
+/*
+LoadModelBytes creates the model from bytes returned by SaveModelBytes, the model is
+initialized by params in its header like LoadAnyModel
+*/
+func LoadModelBytes(buf []byte) (interface{}, error) {
+	file, err := ioutil.TempFile("", "hector-model")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(file.Name())
+	_, err = file.Write(buf)
+	file.Close()
+	if err != nil {
+		return nil, err
+	}
+	return loadModelByHeader(file.Name())
+}
@@ -0,0 +1,148 @@
+package hector
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestModelFileHeader(t *testing.T) {
+	params := DefaultParams()
+	params["learning-rate"] = "0.05"
+	params["global"] = "0"
+
+	lr := LogisticRegression{}
+	err := lr.Init(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lr.Train(LinearDataSet(300))
+
+	file, _ := ioutil.TempFile("", "hector-model")
+	file.Close()
+	defer os.Remove(file.Name())
+	err = lr.SaveModel(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header, err := ReadModelHeader(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Algorithm != "lr" || header.Version != MODEL_FORMAT_VERSION || header.Global != 0 {
+		t.Errorf("unexpected header %v", header)
+	}
+	if header.Params["learning-rate"] != "0.05" {
+		t.Errorf("learning-rate in header is %q, expect 0.05", header.Params["learning-rate"])
+	}
+	if _, ok := header.Params["k"]; ok {
+		t.Error("header keeps params of other algorithms")
+	}
+	if header.FeatureCount != len(lr.Model) {
+		t.Errorf("feature count in header is %d, expect %d", header.FeatureCount, len(lr.Model))
+	}
+
+	model, err := LoadAnyModel(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := model.(*LogisticRegression); !ok {
+		t.Errorf("LoadAnyModel returns %T, expect *LogisticRegression", model)
+	}
+
+	rf := RandomForest{}
+	rf.Init(params)
+	if rf.LoadModel(file.Name()) == nil {
+		t.Error("random forest loads model file of logistic regression")
+	}
+}
+
+func TestModelFileChecksum(t *testing.T) {
+	file, _ := ioutil.TempFile("", "hector-model")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	header := ModelHeader{Algorithm: "lr", Version: MODEL_FORMAT_VERSION, Params: map[string]string{}}
+	err := WriteModelFile(file.Name(), &header, []byte("1\t0.5\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf, _ := ioutil.ReadFile(file.Name())
+	ioutil.WriteFile(file.Name(), []byte(strings.Replace(string(buf), "0.5", "0.7", 1)), 0600)
+	if _, _, err := ReadModelFile(file.Name()); err == nil {
+		t.Error("damaged model file passes checksum")
+	}
+}
+
+func TestLegacyModelFile(t *testing.T) {
+	file, _ := ioutil.TempFile("", "hector-model")
+	file.WriteString("1\t0.5\n2\t-0.5\n")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	lr := LogisticRegression{}
+	lr.Init(DefaultParams())
+	err := lr.LoadModel(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lr.Model[1] != 0.5 || lr.Model[2] != -0.5 {
+		t.Errorf("legacy model is loaded as %v", lr.Model)
+	}
+	if _, err := LoadAnyModel(file.Name()); err == nil {
+		t.Error("LoadAnyModel loads model file without header")
+	}
+}
+
+func TestBinaryModelDamaged(t *testing.T) {
+	file, _ := ioutil.TempFile("", "hector-model")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	header := ModelHeader{Algorithm: "lr", Version: MODEL_FORMAT_VERSION, Format: MODEL_FORMAT_BINARY, Params: map[string]string{}}
+	w := NewBinaryWriter(MODEL_FORMAT_BINARY)
+	WeightsToBinary(w, map[int64]float64{1: 0.5, 2: -0.5})
+	body := w.Bytes()
+	err := WriteModelFile(file.Name(), &header, body[:len(body) - 3])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lr := LogisticRegression{}
+	lr.Init(DefaultParams())
+	if lr.LoadModel(file.Name()) == nil {
+		t.Error("truncated binary model is loaded without error")
+	}
+}
+
+func TestTextModelLongLine(t *testing.T) {
+	params := DefaultParams()
+	params["k"] = "1"
+	knn := KNN{}
+	knn.Init(params)
+	sv := NewVector()
+	for i := int64(0); i < 20000; i++ {
+		sv.SetValue(i, float64(i) + 0.5)
+	}
+	knn.sv = []*Vector{sv}
+	knn.labels = []int{1}
+
+	file, _ := ioutil.TempFile("", "hector-model")
+	file.Close()
+	defer os.Remove(file.Name())
+	err := knn.SaveModel(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := KNN{}
+	loaded.Init(params)
+	err = loaded.LoadModel(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.sv) != 1 || len(loaded.sv[0].data) != 20000 {
+		t.Error("support vector longer than 64KB is not loaded")
+	}
+}
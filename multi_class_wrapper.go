@@ -0,0 +1,321 @@
+package hector
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var MultiClassWrapperParamSchema = ParamSchema{
+	StringParam("base-method", "lr", "binary method trained by ovr and ovo"),
+}
+
+func init() {
+	RegisterAlgorithm(Algorithm{
+		Name: "ovr",
+		Description: "one-vs-rest, trains one model of base-method per label",
+		Types: AlgorithmTypeEnum.MULTI_CLASS,
+		Params: MultiClassWrapperParamSchema,
+		New: func() interface{} { return &(OneVsRest{}) },
+	})
+	RegisterAlgorithm(Algorithm{
+		Name: "ovo",
+		Description: "one-vs-one, trains one model of base-method per pair of labels and predicts by voting",
+		Types: AlgorithmTypeEnum.MULTI_CLASS,
+		Params: MultiClassWrapperParamSchema,
+		New: func() interface{} { return &(OneVsOne{}) },
+	})
+}
+
+/*
+SubModel is a binary model trained by a multi-class wrapper. It separates samples of label
+Positive from samples of label Negative, Negative is -1 for all other labels in one-vs-rest.
+Model is nil if there is no sample for it
+*/
+type SubModel struct {
+	Positive, Negative int
+	Model Classifier
+}
+
+/*
+multiClassWrapper holds what one-vs-rest and one-vs-one share : base method, sub models,
+training of sub models and the model file. Sub models are saved as nested model files, so they
+are loaded with their own params
+*/
+type multiClassWrapper struct {
+	BaseMethod string
+	MaxLabel int
+	Models []*SubModel
+	params map[string]string
+}
+
+func (w *multiClassWrapper) init(params map[string]string) error {
+	values, err := MultiClassWrapperParamSchema.Parse(params)
+	if err != nil {
+		return err
+	}
+	w.BaseMethod = values.String("base-method")
+	// check base method and its params now, as Train can not return errors
+	base, err := GetClassifier(w.BaseMethod)
+	if err != nil {
+		return err
+	}
+	err = base.Init(params)
+	if err != nil {
+		return err
+	}
+	w.MaxLabel = 0
+	w.Models = []*SubModel{}
+	w.params = params
+	return nil
+}
+
+func (w *multiClassWrapper) findMaxLabel(dataset * DataSet) {
+	w.MaxLabel = 0
+	for _, sample := range dataset.Samples {
+		if w.MaxLabel < sample.Label {
+			w.MaxLabel = sample.Label
+		}
+	}
+}
+
+/*
+train trains a sub model on samples of label positive and negative, or of all labels if negative is -1.
+Samples are copied as learners like GBDT change Prediction of samples
+*/
+func (w *multiClassWrapper) train(dataset * DataSet, positive, negative int) {
+	sub := SubModel{Positive: positive, Negative: negative}
+	binary_dataset := NewDataSet()
+	for _, sample := range dataset.Samples {
+		if negative >= 0 && sample.Label != positive && sample.Label != negative {
+			continue
+		}
+		binary_sample := Sample{Features: sample.Features, Label: 0, Weight: sample.Weight}
+		if sample.Label == positive {
+			binary_sample.Label = 1
+		}
+		binary_dataset.AddSample(&binary_sample)
+	}
+	if len(binary_dataset.Samples) > 0 {
+		sub.Model, _ = GetClassifier(w.BaseMethod)
+		sub.Model.Init(w.params)
+		sub.Model.Train(binary_dataset)
+	}
+	w.Models = append(w.Models, &sub)
+}
+
+/*
+normalize turns scores into probabilities, negative scores are taken as zero
+*/
+func (w *multiClassWrapper) normalize(scores *ArrayVector) *ArrayVector {
+	scores.Expand(w.MaxLabel + 1)
+	sum := 0.0
+	for i, score := range scores.data {
+		if score < 0 {
+			scores.data[i] = 0
+		} else {
+			sum += score
+		}
+	}
+	if sum == 0 {
+		for i, _ := range scores.data {
+			scores.data[i] = 1.0
+		}
+		sum = float64(len(scores.data))
+	}
+	scores.Scale(1.0 / sum)
+	return scores
+}
+
+/*
+Model file starts with a line of max label, followed by sub models. Each sub model is a line of positive
+label, negative label and size, followed by the nested model file. The base method is kept in params of
+the header
+*/
+func (w *multiClassWrapper) saveModel(path string, model interface{}) error {
+	// feature counts are kept in headers of sub models
+	header := NewModelHeader(model, w.params, 0)
+	subs := [][]byte{}
+	for _, sub := range w.Models {
+		buf := []byte{}
+		if sub.Model != nil {
+			var err error
+			buf, err = SaveModelBytes(sub.Model)
+			if err != nil {
+				return err
+			}
+		}
+		subs = append(subs, buf)
+	}
+	if header.Binary() {
+		bw := NewBinaryWriter(header.Format)
+		bw.Int(w.MaxLabel).Int(len(w.Models))
+		for i, sub := range w.Models {
+			bw.Int(sub.Positive).Int(sub.Negative).WriteBytes(subs[i])
+		}
+		return WriteModelFile(path, header, bw.Bytes())
+	}
+	sb := StringBuilder{}
+	sb.Int(w.MaxLabel).Write("\n")
+	for i, sub := range w.Models {
+		sb.Int(sub.Positive).Write("\t").Int(sub.Negative).Write("\t").Int(len(subs[i])).Write("\n")
+		sb.WriteBytes(subs[i])
+		sb.Write("\n")
+	}
+	return WriteModelFile(path, header, sb.Bytes())
+}
+
+func (w *multiClassWrapper) loadModel(path string, model interface{}) error {
+	header, body, err := ReadModelBody(path, model)
+	if err != nil {
+		return err
+	}
+	w.Models = []*SubModel{}
+	subs := [][]byte{}
+	if header.Binary() {
+		r := NewBinaryReader(body, header.Format)
+		w.MaxLabel = r.Int()
+		n := r.Len()
+		for i := 0; i < n; i++ {
+			sub := SubModel{Positive: r.Int(), Negative: r.Int()}
+			subs = append(subs, r.ReadBytes())
+			w.Models = append(w.Models, &sub)
+		}
+		if r.Err() != nil {
+			return r.Err()
+		}
+	} else {
+		line, body := nextModelLine(body)
+		w.MaxLabel, err = strconv.Atoi(line)
+		if err != nil {
+			return errors.New("bad multi-class model file " + path)
+		}
+		for len(body) > 0 {
+			line, body = nextModelLine(body)
+			tks := strings.Split(line, "\t")
+			if len(tks) < 3 {
+				return errors.New("bad multi-class model file " + path)
+			}
+			sub := SubModel{}
+			sub.Positive, _ = strconv.Atoi(tks[0])
+			sub.Negative, _ = strconv.Atoi(tks[1])
+			size, _ := strconv.Atoi(tks[2])
+			if size < 0 || size + 1 > len(body) {
+				return errors.New("bad multi-class model file " + path)
+			}
+			subs = append(subs, body[:size])
+			body = body[size + 1:]
+			w.Models = append(w.Models, &sub)
+		}
+	}
+	for i, sub := range w.Models {
+		if len(subs[i]) == 0 {
+			continue
+		}
+		loaded, err := LoadModelBytes(subs[i])
+		if err != nil {
+			return err
+		}
+		classifier, ok := loaded.(Classifier)
+		if !ok {
+			return fmt.Errorf("sub model %d of %s is not binary classifier", i, path)
+		}
+		sub.Model = classifier
+	}
+	return nil
+}
+
+func nextModelLine(body []byte) (string, []byte) {
+	i := bytes.IndexByte(body, '\n')
+	if i < 0 {
+		return string(body), nil
+	}
+	return string(body[:i]), body[i + 1:]
+}
+
+/*
+OneVsRest trains one binary model per label, which separates the label from all other labels
+*/
+type OneVsRest struct {
+	multiClassWrapper
+}
+
+func (c *OneVsRest) Init(params map[string]string) error {
+	return c.init(params)
+}
+
+func (c *OneVsRest) Train(dataset * DataSet) {
+	c.findMaxLabel(dataset)
+	c.Models = []*SubModel{}
+	for k := 0; k <= c.MaxLabel; k++ {
+		c.train(dataset, k, -1)
+	}
+}
+
+func (c *OneVsRest) PredictMultiClass(sample * Sample) * ArrayVector {
+	scores := NewArrayVector()
+	for _, sub := range c.Models {
+		if sub.Model != nil {
+			scores.SetValue(sub.Positive, sub.Model.Predict(sample))
+		}
+	}
+	return c.normalize(scores)
+}
+
+func (c *OneVsRest) SaveModel(path string) error {
+	return c.saveModel(path, c)
+}
+
+func (c *OneVsRest) LoadModel(path string) error {
+	return c.loadModel(path, c)
+}
+
+/*
+OneVsOne trains one binary model per pair of labels. In prediction, each model votes
+for its two labels by its prediction
+*/
+type OneVsOne struct {
+	multiClassWrapper
+}
+
+func (c *OneVsOne) Init(params map[string]string) error {
+	return c.init(params)
+}
+
+func (c *OneVsOne) Train(dataset * DataSet) {
+	c.findMaxLabel(dataset)
+	c.Models = []*SubModel{}
+	for i := 0; i <= c.MaxLabel; i++ {
+		for j := i + 1; j <= c.MaxLabel; j++ {
+			c.train(dataset, i, j)
+		}
+	}
+}
+
+func (c *OneVsOne) PredictMultiClass(sample * Sample) * ArrayVector {
+	scores := NewArrayVector()
+	for _, sub := range c.Models {
+		if sub.Model == nil {
+			continue
+		}
+		p := sub.Model.Predict(sample)
+		if p < 0 {
+			p = 0
+		} else if p > 1 {
+			p = 1
+		}
+		scores.AddValue(sub.Positive, p)
+		scores.AddValue(sub.Negative, 1 - p)
+	}
+	return c.normalize(scores)
+}
+
+func (c *OneVsOne) SaveModel(path string) error {
+	return c.saveModel(path, c)
+}
+
+func (c *OneVsOne) LoadModel(path string) error {
+	return c.loadModel(path, c)
+}
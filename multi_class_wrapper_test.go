@@ -0,0 +1,70 @@
+package hector
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"testing"
+)
+
+func TestMultiClassWrappers(t *testing.T) {
+	train_dataset := MultiClassDataSet(2000, 4)
+	test_dataset := MultiClassDataSet(500, 4)
+
+	file, _ := ioutil.TempFile("", "hector-mc")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	params := DefaultParams()
+	params["steps"] = "5"
+	params["learning-rate"] = "0.3"
+	params["tree-count"] = "20"
+	params["regularization"] = "0.0001"
+
+	for _, method := range []string{"ovr", "ovo"} {
+		for _, base := range []string{"ftrl", "gbdt"} {
+			params["base-method"] = base
+			params["model-format"] = MODEL_FORMAT_TEXT
+			if base == "gbdt" {
+				params["model-format"] = MODEL_FORMAT_BINARY
+			}
+			classifier, err := GetMutliClassClassifier(method)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = classifier.Init(params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			accuracy := MultiClassRunOnDataSet(classifier, train_dataset, test_dataset, "", params)
+			t.Logf("accuracy of %s with %s is %f", method, base, accuracy)
+			if accuracy < 0.8 {
+				t.Errorf("accuracy of %s with %s is less than 0.8", method, base)
+			}
+
+			err = classifier.SaveModel(file.Name())
+			if err != nil {
+				t.Fatal(err)
+			}
+			loaded, err := LoadAnyMultiClassModel(file.Name())
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, sample := range test_dataset.Samples {
+				p1 := classifier.PredictMultiClass(sample)
+				p2 := loaded.PredictMultiClass(sample)
+				for k := 0; k < 4; k++ {
+					if math.Abs(p1.GetValue(k) - p2.GetValue(k)) > 1e-9 {
+						t.Fatalf("%s with %s changes probability of label %d on sample %d from %f to %f after load", method, base, k, i, p1.GetValue(k), p2.GetValue(k))
+					}
+				}
+			}
+		}
+	}
+
+	params["base-method"] = "ovr"
+	ovr := OneVsRest{}
+	if ovr.Init(params) == nil {
+		t.Error("ovr accepts base method which is not binary")
+	}
+}
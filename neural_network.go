@@ -1,10 +1,11 @@
 package hector
 
 import(
-    "strconv"
+    "context"
     "math/rand"
     "math"
-    "fmt"
+    "strconv"
+    "strings"
 )
 
 type NeuralNetworkParams struct {
@@ -30,39 +31,125 @@ type NeuralNetwork struct {
     Model TwoLayerWeights
     MaxLabel int64
     Params NeuralNetworkParams
+    rng *rand.Rand
+    init_params map[string]string
 }
 
-func RandomInitVector(dim int64) *Vector{
-    v := NewVector()
-    var i int64
-    for i = 0; i < dim; i++ {
-        v.data[i] = (rand.Float64() - 0.5) / math.Sqrt(float64(dim))
+/*
+Model file starts with a line of hidden neuron number and max label, followed by rows of
+L1 and rows of L2, each layer ends with a line of "#"
+*/
+func (self *NeuralNetwork) SaveModel(path string) error {
+    rows := []*Vector{}
+    for _, row := range self.Model.L1.data {
+        rows = append(rows, row)
+    }
+    header := NewModelHeader(self, self.init_params, FeatureCountOfVectors(rows...))
+    if header.Binary() {
+        w := NewBinaryWriter(header.Format)
+        w.Varint(self.Params.Hidden).Varint(self.MaxLabel)
+        self.Model.L1.WriteBinary(w)
+        self.Model.L2.WriteBinary(w)
+        return WriteModelFile(path, header, w.Bytes())
     }
-    return v
+    sb := StringBuilder{}
+    sb.Int64(self.Params.Hidden)
+    sb.Write("\t")
+    sb.Int64(self.MaxLabel)
+    sb.Write("\n")
+    sb.WriteBytes(self.Model.L1.ToString())
+    sb.Write("#\n")
+    sb.WriteBytes(self.Model.L2.ToString())
+    sb.Write("#\n")
+    return WriteModelFile(path, header, sb.Bytes())
 }
 
-func (self *NeuralNetwork) SaveModel(path string){
+func (self *NeuralNetwork) LoadModel(path string) error {
+    header, body, err := ReadModelBody(path, self)
+    if err != nil {
+        return err
+    }
+    if header.Binary() {
+        r := NewBinaryReader(body, header.Format)
+        self.Params.Hidden = r.Varint()
+        self.MaxLabel = r.Varint()
+        self.Model = TwoLayerWeights{}
+        self.Model.L1 = NewMatrix()
+        self.Model.L2 = NewMatrix()
+        self.Model.L1.ReadBinary(r)
+        self.Model.L2.ReadBinary(r)
+        return r.Err()
+    }
 
+    self.Model = TwoLayerWeights{}
+    self.Model.L1 = NewMatrix()
+    self.Model.L2 = NewMatrix()
+    layers := []*Matrix{self.Model.L1, self.Model.L2}
+    scaner := NewModelScanner(body)
+    if scaner.Scan() {
+        tks := strings.Split(scaner.Text(), "\t")
+        self.Params.Hidden, _ = strconv.ParseInt(tks[0], 10, 64)
+        self.MaxLabel, _ = strconv.ParseInt(tks[1], 10, 64)
+    }
+    text := ""
+    for scaner.Scan() && len(layers) > 0 {
+        line := scaner.Text()
+        if line == "#" {
+            layers[0].FromString(text)
+            layers = layers[1:]
+            text = ""
+        } else {
+            text += line + "\n"
+        }
+    }
+    return nil
 }
 
-func (self *NeuralNetwork) LoadModel(path string){
-    
+var NeuralNetworkParamSchema = ParamSchema{
+    PositiveFloatParam("learning-rate", 0.01, math.Inf(1), "learning rate"),
+    PositiveFloatParam("learning-rate-discount", 1.0, 1.0, "discount rate of learning rate per training step"),
+    FloatParam("regularization", 0.01, 0.0, math.Inf(1), "regularization"),
+    IntParam("steps", 1, 1, math.Inf(1), "steps before convergent"),
+    IntParam("hidden", 1, 1, math.Inf(1), "hidden neuron number"),
+    VerboseParam,
+    SeedParam,
 }
 
-func (algo *NeuralNetwork) Init(params map[string]string) {
-    algo.Params.LearningRate, _ = strconv.ParseFloat(params["learning-rate"], 64)
-    algo.Params.LearningRateDiscount, _ = strconv.ParseFloat(params["learning-rate-discount"], 64)
-    algo.Params.Regularization, _ = strconv.ParseFloat(params["regularization"], 64)
-    steps, _ := strconv.ParseInt(params["steps"], 10, 32)
-    hidden, _ := strconv.ParseInt(params["hidden"], 10, 64)
-    verbose, _ := strconv.ParseInt(params["verbose"], 10, 32)
-
-    algo.Params.Steps = int(steps)
-    algo.Params.Hidden = int64(hidden)
-    algo.Params.Verbose = int(verbose)
+func init() {
+    RegisterAlgorithm(Algorithm{
+        Name: "ann",
+        Description: "neural network with one hidden layer",
+        Types: AlgorithmTypeEnum.BINARY | AlgorithmTypeEnum.MULTI_CLASS,
+        Params: NeuralNetworkParamSchema,
+        New: func() interface{} { return &(NeuralNetwork{}) },
+    })
+}
+
+func (algo *NeuralNetwork) Init(params map[string]string) error {
+    values, err := NeuralNetworkParamSchema.Parse(params)
+    if err != nil {
+        return err
+    }
+    algo.Params.LearningRate = values.Float("learning-rate")
+    algo.Params.LearningRateDiscount = values.Float("learning-rate-discount")
+    algo.Params.Regularization = values.Float("regularization")
+    algo.Params.Steps = values.Int("steps")
+    algo.Params.Hidden = values.Int64("hidden")
+    algo.Params.Verbose = values.Int("verbose")
+    algo.rng = NewRand(int64(values.Int("seed")))
+    algo.init_params = params
+    return nil
 }
 
 func (algo *NeuralNetwork) Train(dataset * DataSet) {
+    algo.TrainContext(context.Background(), dataset, nil)
+}
+
+/*
+TrainContext stops after the current sample when ctx is done, the weights are kept. With verbose, events
+of epochs have the accuracy on the train dataset
+*/
+func (algo *NeuralNetwork) TrainContext(ctx context.Context, dataset *DataSet, sink ProgressSink) error {
     algo.Model = TwoLayerWeights{}
     algo.Model.L1 = NewMatrix()
     algo.Model.L2 = NewMatrix()
@@ -81,7 +168,7 @@ func (algo *NeuralNetwork) Train(dataset * DataSet) {
             _, ok := initalized[f.Id]
             if !ok{
                 for i := int64(0); i < algo.Params.Hidden; i++ {
-                    algo.Model.L1.SetValue(i, f.Id, (rand.Float64() - 0.5) / math.Sqrt(float64(algo.Params.Hidden)))               
+                    algo.Model.L1.SetValue(i, f.Id, (algo.rng.Float64() - 0.5) / math.Sqrt(float64(algo.Params.Hidden)))               
                 }
                 initalized[f.Id] = 1
             }
@@ -91,17 +178,15 @@ func (algo *NeuralNetwork) Train(dataset * DataSet) {
     
     for i := int64(0); i <= algo.Params.Hidden; i++ {
         for j := int64(0); j <= algo.MaxLabel; j++ {
-            algo.Model.L2.SetValue(i, j, (rand.NormFloat64() / math.Sqrt(float64(algo.MaxLabel) + 1.0)))
+            algo.Model.L2.SetValue(i, j, (algo.rng.NormFloat64() / math.Sqrt(float64(algo.MaxLabel) + 1.0)))
         }
     }
 
     for step := 0; step < algo.Params.Steps; step++{
-        if algo.Params.Verbose <= 0 {
-            fmt.Printf(".")            
-        }
-        total := len(dataset.Samples)
-        counter := 0
         for _, sample := range dataset.Samples {
+            if err := ctx.Err(); err != nil {
+                return err
+            }
             y := NewVector()
             z := NewVector()
             e := NewVector()
@@ -126,6 +211,8 @@ func (algo *NeuralNetwork) Train(dataset * DataSet) {
             z = z.SoftMaxNorm()
             e.SetValue(int64(sample.Label), 1.0)
             e.AddVector(z, -1.0)
+            // gradients of the sample are scaled by its weight
+            e.ApplyScale(sample.GetWeight())
 
             for i := int64(0); i <= algo.Params.Hidden; i++ {
                 delta := float64(0)
@@ -147,18 +234,17 @@ func (algo *NeuralNetwork) Train(dataset * DataSet) {
                     wi.SetValue(f.Id, wji)
                 }
             }
-            counter++
-            if algo.Params.Verbose > 0 && counter % 2000 == 0 {
-                fmt.Printf("Epoch %d %f%%\n", step+1, float64(counter)/float64(total)*100)
-            }
         }
 
+        event := TrainEvent{Kind: TrainEventEnum.EPOCH, Step: step + 1, Total: algo.Params.Steps}
         if algo.Params.Verbose > 0 {
-            algo.Evaluate(dataset)
+            event.Metric = "accuracy"
+            event.Value = algo.accuracy(dataset)
         }
+        sink.Send(event)
         algo.Params.LearningRate *= algo.Params.LearningRateDiscount
     }
-    fmt.Println()
+    return nil
 }
 
 func (algo *NeuralNetwork) PredictMultiClass(sample * Sample) * ArrayVector {
@@ -189,6 +275,10 @@ func (algo *NeuralNetwork) Predict(sample *Sample) float64 {
 }
 
 func (algo *NeuralNetwork) Evaluate(dataset *DataSet) {
+    logInfo("neural network evaluated", "accuracy", algo.accuracy(dataset))
+}
+
+func (algo *NeuralNetwork) accuracy(dataset *DataSet) float64 {
     accuracy := 0.0
     total := 0.0
     for _, sample := range dataset.Samples {
@@ -199,5 +289,5 @@ func (algo *NeuralNetwork) Evaluate(dataset *DataSet) {
         }
         total += 1.0
     }
-    fmt.Printf("accuracy %f%%\n", accuracy/total*100)
+    return accuracy / total
 }
@@ -0,0 +1,240 @@
+package hector
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type ParamType int
+
+var ParamTypeEnum = struct {
+	INT ParamType
+	FLOAT ParamType
+	STRING ParamType
+}{0, 1, 2}
+
+/*
+ParamSpec describes one parameter of an algorithm. Numeric values must be in [Min, Max],
+or in (Min, Max] if MinExclusive is set. String values must be one of Choices if it is not empty
+*/
+type ParamSpec struct {
+	Name string
+	Type ParamType
+	Default string
+	Min, Max float64
+	MinExclusive bool
+	Choices []string
+	Description string
+}
+
+func IntParam(name string, value int64, min, max float64, description string) ParamSpec {
+	return ParamSpec{Name: name, Type: ParamTypeEnum.INT, Default: strconv.FormatInt(value, 10), Min: min, Max: max, Description: description}
+}
+
+func FloatParam(name string, value float64, min, max float64, description string) ParamSpec {
+	return ParamSpec{Name: name, Type: ParamTypeEnum.FLOAT, Default: strconv.FormatFloat(value, 'g', -1, 64), Min: min, Max: max, Description: description}
+}
+
+func PositiveFloatParam(name string, value float64, max float64, description string) ParamSpec {
+	ret := FloatParam(name, value, 0.0, max, description)
+	ret.MinExclusive = true
+	return ret
+}
+
+func StringParam(name string, value string, description string, choices ...string) ParamSpec {
+	return ParamSpec{Name: name, Type: ParamTypeEnum.STRING, Default: value, Choices: choices, Description: description}
+}
+
+func (p *ParamSpec) Usage() string {
+	ret := p.Description
+	if p.Type == ParamTypeEnum.STRING {
+		if len(p.Choices) > 0 {
+			ret += ", one of " + strings.Join(p.Choices, "|")
+		}
+		return ret
+	}
+	if !math.IsInf(p.Min, -1) || !math.IsInf(p.Max, 1) {
+		left := "["
+		if p.MinExclusive {
+			left = "("
+		}
+		right := fmt.Sprintf("%g]", p.Max)
+		if math.IsInf(p.Max, 1) {
+			right = "inf)"
+		}
+		ret += fmt.Sprintf(", range %s%g, %s", left, p.Min, right)
+	}
+	return ret
+}
+
+func (p *ParamSpec) Check(value string) error {
+	number := 0.0
+	if p.Type == ParamTypeEnum.INT {
+		v, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("param %s: %q is not an integer", p.Name, value)
+		}
+		number = float64(v)
+	} else if p.Type == ParamTypeEnum.FLOAT {
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(v) {
+			return fmt.Errorf("param %s: %q is not a number", p.Name, value)
+		}
+		number = v
+	} else {
+		if len(p.Choices) == 0 {
+			return nil
+		}
+		for _, choice := range p.Choices {
+			if choice == value {
+				return nil
+			}
+		}
+		return fmt.Errorf("param %s: %q is not one of %s", p.Name, value, strings.Join(p.Choices, "|"))
+	}
+	if number < p.Min || number > p.Max || (p.MinExclusive && number == p.Min) {
+		return fmt.Errorf("param %s: %s is out of range, %s", p.Name, value, p.Usage())
+	}
+	return nil
+}
+
+type ParamSchema []ParamSpec
+
+func MergeParamSchemas(schemas ...ParamSchema) ParamSchema {
+	ret := ParamSchema{}
+	seen := make(map[string]bool)
+	for _, schema := range schemas {
+		for _, spec := range schema {
+			if !seen[spec.Name] {
+				seen[spec.Name] = true
+				ret = append(ret, spec)
+			}
+		}
+	}
+	return ret
+}
+
+func (s ParamSchema) Get(name string) (ParamSpec, bool) {
+	for _, spec := range s {
+		if spec.Name == name {
+			return spec, true
+		}
+	}
+	return ParamSpec{}, false
+}
+
+func (s ParamSchema) Defaults() map[string]string {
+	ret := make(map[string]string)
+	for _, spec := range s {
+		ret[spec.Name] = spec.Default
+	}
+	return ret
+}
+
+/*
+Parse checks params against the schema. It fails on keys which no algorithm knows,
+on keys of the schema which are missing and on values which can not be parsed or are out of range
+*/
+func (s ParamSchema) Parse(params map[string]string) (ParamValues, error) {
+	known := AllParamSchemas()
+	for key, _ := range params {
+		if _, ok := known.Get(key); !ok {
+			return nil, errors.New("unknown param " + key)
+		}
+	}
+	ret := make(ParamValues)
+	for _, spec := range s {
+		value, ok := params[spec.Name]
+		if !ok {
+			return nil, errors.New("missing param " + spec.Name)
+		}
+		err := spec.Check(value)
+		if err != nil {
+			return nil, err
+		}
+		ret[spec.Name] = value
+	}
+	return ret, nil
+}
+
+/*
+ParamValues holds params which have been checked by ParamSchema.Parse
+*/
+type ParamValues map[string]string
+
+func (p ParamValues) Int(name string) int {
+	return int(p.Int64(name))
+}
+
+func (p ParamValues) Int64(name string) int64 {
+	ret, _ := strconv.ParseInt(p[name], 10, 64)
+	return ret
+}
+
+func (p ParamValues) Float(name string) float64 {
+	ret, _ := strconv.ParseFloat(p[name], 64)
+	return ret
+}
+
+func (p ParamValues) String(name string) string {
+	return p[name]
+}
+
+var VerboseParam = IntParam("verbose", 0, 0, math.Inf(1), "verbose output if 1")
+
+/*
+SeedParam is the seed of the random generator of each model, models trained with the same seed on the
+same dataset are the same
+*/
+var SeedParam = IntParam("seed", 0, 0, math.Inf(1), "seed of random numbers in training, 0 seeds by time")
+
+/*
+BetaParam is shared by ftrl and ep, so that both accept the same values of beta when their schemas are merged
+*/
+var BetaParam = PositiveFloatParam("beta", 1.0, math.Inf(1), "beta of ftrl, variance of noise in ep")
+
+/*
+CommonParamSchema holds params which are used by runners instead of algorithms
+*/
+var CommonParamSchema = ParamSchema{
+	StringParam("method", "lr", "algorithm name"),
+	StringParam("action", "", "train or test, do both if action is empty string, importance writes feature importance of the model", "", "train", "test", "importance"),
+	StringParam("importance-type", ImportanceTypeEnum.GAIN, "feature importance by split count or gain of trees, or by permutation of features of the test file",
+		ImportanceTypeEnum.SPLIT, ImportanceTypeEnum.GAIN, ImportanceTypeEnum.PERMUTATION),
+	StringParam("model", "", "model file name"),
+	StringParam("validation", "", "validation file monitored in training by methods which support it, e.g. gbdt with early-stopping-rounds"),
+	StringParam("model-format", MODEL_FORMAT_TEXT, "format of saved model file, binary32 keeps weights in float32", MODEL_FORMAT_TEXT, MODEL_FORMAT_BINARY, MODEL_FORMAT_BINARY32),
+	StringParam("output", "", "output file path"),
+	StringParam("profile", "", "profile file name"),
+	IntParam("global", -1, -1, math.Inf(1), "feature id of global bias"),
+	IntParam("cv", 7, 2, math.Inf(1), "cross validation folder count"),
+	IntParam("stream", 0, 0, 1, "train online algorithms (lr, ftrl, ep, fm) from a stream of the train file if 1, train file can be .gz or - for stdin"),
+	VerboseParam,
+}
+
+/*
+AllParamSchemas returns common params followed by params of every algorithm
+*/
+func AllParamSchemas() ParamSchema {
+	schemas := []ParamSchema{CommonParamSchema}
+	for _, method := range Methods() {
+		schemas = append(schemas, algorithms[method].Params)
+	}
+	return MergeParamSchemas(schemas...)
+}
+
+func IsKnownParam(name string) bool {
+	_, ok := AllParamSchemas().Get(name)
+	return ok
+}
+
+/*
+DefaultParams returns default values of all params, it is a good start point to build
+params for Init without command line
+*/
+func DefaultParams() map[string]string {
+	return AllParamSchemas().Defaults()
+}
@@ -0,0 +1,58 @@
+package hector
+
+import (
+	"testing"
+)
+
+func TestParamSchema(t *testing.T) {
+	algo := LogisticRegression{}
+	params := DefaultParams()
+	err := algo.Init(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if algo.Params.Steps != 1 || algo.Params.LearningRate != 0.01 {
+		t.Error("default params are not used")
+	}
+
+	params["learning-rate"] = "0.0l"
+	if algo.Init(params) == nil {
+		t.Error("typo in learning rate should fail")
+	}
+
+	params = DefaultParams()
+	params["learning-rate"] = "-0.1"
+	if algo.Init(params) == nil {
+		t.Error("negative learning rate should fail")
+	}
+
+	params = DefaultParams()
+	params["learning_rate"] = "0.1"
+	if algo.Init(params) == nil {
+		t.Error("unknown param should fail")
+	}
+
+	params = DefaultParams()
+	delete(params, "steps")
+	if algo.Init(params) == nil {
+		t.Error("missing param should fail")
+	}
+
+	linear := LinearRegression{}
+	params = DefaultParams()
+	params["steps"] = "3"
+	err = linear.Init(params)
+	if err != nil || linear.Params.Steps != 3 {
+		t.Error("steps of linear regression is not set")
+	}
+
+	// beta is shared by ftrl and ep, both reject the same values
+	params = DefaultParams()
+	params["beta"] = "0.0"
+	for _, method := range []string{"ftrl", "ep"} {
+		classifier, _ := GetClassifier(method)
+		if classifier.Init(params) == nil {
+			t.Errorf("beta 0 of %s should fail", method)
+		}
+	}
+}
@@ -14,6 +14,8 @@ type QuasiNewtonHelper struct {
     // config
     numHist int64
     minimizer Minimizer
+    maxBacktracking int
+    c1, backoff, initialBackoff float64
     // historical data
     sList, yList []*Vector
     roList []float64
@@ -30,6 +32,10 @@ const MAX_BACKTRACKING_ITER = 50
 func NewQuasiNewtonHelper(numHist int, minimizer Minimizer, curPos *Vector, curGrad *Vector) (*QuasiNewtonHelper) {
     h := new(QuasiNewtonHelper)
     h.numHist = int64(numHist)
+    h.maxBacktracking = MAX_BACKTRACKING_ITER
+    h.c1 = 1e-4
+    h.backoff = 0.5
+    h.initialBackoff = 0.1
 	h.minimizer = minimizer
 	h.curPos = curPos
 	h.curGrad = curGrad
@@ -48,23 +54,23 @@ func (h *QuasiNewtonHelper) ApplyQuasiInverseHession(dir *Vector) {
     }
     alphas := make([]float64, count, count)
     for n:=count-1; n>=0; n-- {
-        alphas[n] = -dir.Dot(h.sList[n]) / h.roList[n]
+        alphas[n] = -dir.SortedDot(h.sList[n]) / h.roList[n]
         dir.ApplyElemWiseMultiplyAccumulation(h.yList[n], alphas[n])
     }
     lastY := h.yList[count-1]
-    yDotY := lastY.Dot(lastY)
+    yDotY := lastY.SortedDot(lastY)
     scalar := h.roList[count-1] / yDotY
     dir.ApplyScale(scalar)
 
     for n:=0; n<count; n++ {
-        beta := dir.Dot(h.yList[n]) / h.roList[n]
+        beta := dir.SortedDot(h.yList[n]) / h.roList[n]
         dir.ApplyElemWiseMultiplyAccumulation(h.sList[n], -alphas[n] - beta)
     }
 	return
 }
 
 func (h *QuasiNewtonHelper) BackTrackingLineSearch(cost float64, pos *Vector, grad *Vector, dir *Vector, isInit bool) (nextCost float64, nextPos *Vector) {
-    dotGradDir := grad.Dot(dir)
+    dotGradDir := grad.SortedDot(dir)
 	if dotGradDir == 0 {
 		return cost, pos
 	}
@@ -73,18 +79,19 @@ func (h *QuasiNewtonHelper) BackTrackingLineSearch(cost float64, pos *Vector, gr
 	}
 
     alpha := 1.0
-    backoff := 0.5
+    backoff := h.backoff
     if isInit {
-        normDir := math.Sqrt(dir.Dot(dir))
+        normDir := math.Sqrt(dir.SortedDot(dir))
         alpha = (1/normDir)
-        backoff = 0.1
+        backoff = h.initialBackoff
     }
 
-    var c1 float64 = 1e-4
-    for cntItr:=0; cntItr <= MAX_BACKTRACKING_ITER; cntItr++ {
+    c1 := h.c1
+    for cntItr:=0; cntItr <= h.maxBacktracking; cntItr++ {
         nextPos = h.minimizer.NextPoint(pos, dir, alpha)
         nextCost = h.minimizer.Evaluate(nextPos)
-        if (nextCost <= cost + c1 * dotGradDir * alpha) {
+        // NextPoint may project the point (OWL-QN), so use the real step instead of alpha * dir
+        if (nextCost <= cost + c1 * grad.SortedDot(nextPos.ElemWiseMultiplyAdd(pos, -1))) {
             break
 		}
         alpha *= backoff
@@ -92,19 +99,24 @@ func (h *QuasiNewtonHelper) BackTrackingLineSearch(cost float64, pos *Vector, gr
     return nextCost, nextPos
 }
 
+// Description: Keep the step and the change of gradient, pairs with non-positive
+//              curvature would make the inverse hessian indefinite, so they are dropped
 func (h *QuasiNewtonHelper) updateState(nextPos *Vector, nextGrad *Vector) (isOptimal bool) {
+    newS := nextPos.ElemWiseMultiplyAdd(h.curPos, -1)
+	newY := nextGrad.ElemWiseMultiplyAdd(h.curGrad, -1)	
+	ro := newS.SortedDot(newY)
+	h.curPos = nextPos
+	h.curGrad = nextGrad
+	if ro <= 0 {
+		return ro == 0
+	}
 	if int64(len(h.sList)) >= h.numHist {
 		h.sList = h.sList[1:]
 		h.yList = h.yList[1:]
 		h.roList = h.roList[1:]
 	}
-    newS := nextPos.ElemWiseMultiplyAdd(h.curPos, -1)
-	newY := nextGrad.ElemWiseMultiplyAdd(h.curGrad, -1)	
-	ro := newS.Dot(newY)
 	h.sList = append(h.sList, newS)
 	h.yList = append(h.yList, newY)
 	h.roList = append(h.roList, ro)
-	h.curPos = nextPos
-	h.curGrad = nextGrad
-	return ro == 0
+	return false
 }
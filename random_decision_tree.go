@@ -1,12 +1,14 @@
 package hector
 
 import (
+	"bytes"
+	"context"
+	"math"
 	"math/rand"
+	"sort"
 	"strconv"
 	"strings"
 	"container/list"
-	"sync"
-	"fmt"
 )
 
 type TreeNode struct {
@@ -15,12 +17,27 @@ type TreeNode struct {
 	sample_count		int
 	samples            []int
 	feature_split      Feature
+	// samples without the feature of feature_split go left if missing_left is set
+	missing_left       bool
+	// sorted categories going left if the feature is categorical, nil for numeric splits
+	categories         []float64
+	// decrease of impurity (gini, squared error or objective) of samples by the split
+	gain               float64
+	// histogram of samples, only used in training with max-bins
+	histogram          *Histogram
 }
 
 func (t *TreeNode) ToString() string {
 	return strconv.FormatInt(t.feature_split.Id, 10) + ":" + strconv.FormatFloat(t.feature_split.Value, 'g', 3, 64)
 }
 
+/*
+isSplit tells whether node splits samples to a child, splits whose children are too small to be kept are not
+*/
+func (t *TreeNode) isSplit() bool {
+	return t != nil && (t.left >= 0 || t.right >= 0)
+}
+
 func (t *TreeNode) AddSample(k int) {
 	t.samples = append(t.samples, k)
 }
@@ -61,6 +78,17 @@ func (t *Tree) ToString() []byte {
 		sb.Int64(node.feature_split.Id)
 		sb.Write("\t")
 		sb.Float(node.feature_split.Value)
+		sb.Write("\t")
+		sb.Int(boolToInt(node.missing_left))
+		sb.Write("\t")
+		for i, category := range node.categories {
+			if i > 0 {
+				sb.Write("|")
+			}
+			sb.Float(category)
+		}
+		sb.Write("\t")
+		sb.Float(node.gain)
 		sb.Write("\n")
 	}
 	return sb.Bytes()
@@ -86,10 +114,169 @@ func (t *Tree) FromString(buf string) {
 		node.feature_split = Feature{}
 		node.feature_split.Id, _ = strconv.ParseInt(tks[6], 10, 64)
 		node.feature_split.Value, _ = strconv.ParseFloat(tks[7], 64)
+		// trees written before missing values had directions send them right
+		if len(tks) > 8 {
+			node.missing_left = tks[8] == "1"
+		}
+		if len(tks) > 9 && len(tks[9]) > 0 {
+			for _, tk := range strings.Split(tks[9], "|") {
+				category, _ := strconv.ParseFloat(tk, 64)
+				node.categories = append(node.categories, category)
+			}
+		}
+		if len(tks) > 10 {
+			node.gain, _ = strconv.ParseFloat(tks[10], 64)
+		}
 		t.nodes[i] = &node
 	}
 }
 
+/*
+WriteBinary writes node count and then nodes in order, split values are kept in float64.
+Directions of missing values are written since format version 5, categories since version 6
+and gains of splits since version 7
+*/
+func (t *Tree) WriteBinary(w *BinaryWriter) {
+	w.Int(len(t.nodes))
+	for _, node := range t.nodes {
+		w.Int(node.left)
+		w.Int(node.right)
+		w.Int(node.depth)
+		node.prediction.WriteBinary(w)
+		w.Int(node.sample_count)
+		w.Varint(node.feature_split.Id)
+		w.Float64(node.feature_split.Value)
+		w.Int(boolToInt(node.missing_left))
+		w.Int(len(node.categories))
+		for _, category := range node.categories {
+			w.Float64(category)
+		}
+		w.Float64(node.gain)
+	}
+}
+
+func (t *Tree) ReadBinary(r *BinaryReader) {
+	size := r.Len()
+	t.nodes = make([]*TreeNode, 0, size)
+	for i := 0; i < size; i++ {
+		node := TreeNode{}
+		node.left = r.Int()
+		node.right = r.Int()
+		node.depth = r.Int()
+		node.prediction = NewArrayVector()
+		node.prediction.ReadBinary(r)
+		node.sample_count = r.Int()
+		node.feature_split = Feature{}
+		node.feature_split.Id = r.Varint()
+		node.feature_split.Value = r.Float64()
+		if r.Version() >= 5 {
+			node.missing_left = r.Int() == 1
+		}
+		if r.Version() >= 6 {
+			n := r.Len()
+			for k := 0; k < n; k++ {
+				node.categories = append(node.categories, r.Float64())
+			}
+		}
+		if r.Version() >= 7 {
+			node.gain = r.Float64()
+		}
+		t.nodes = append(t.nodes, &node)
+	}
+}
+
+/*
+TreesToString writes trees of a forest one after another, each tree is followed by a line of "#"
+*/
+func TreesToString(trees []*Tree) []byte {
+	sb := StringBuilder{}
+	for _, tree := range trees {
+		sb.WriteBytes(tree.ToString())
+		sb.Write("\n#\n")
+	}
+	return sb.Bytes()
+}
+
+func TreesFromString(buf []byte) []*Tree {
+	trees, _ := LabeledTreesFromString(buf)
+	return trees
+}
+
+/*
+LabeledTreesToString is TreesToString with the label of each tree in its "#" line, like "#\t2".
+It is used by models which grow trees per label
+*/
+func LabeledTreesToString(trees []*Tree, labels []int) []byte {
+	sb := StringBuilder{}
+	for i, tree := range trees {
+		sb.WriteBytes(tree.ToString())
+		sb.Write("\n#\t").Int(labels[i]).Write("\n")
+	}
+	return sb.Bytes()
+}
+
+/*
+LabeledTreesFromString reads trees written by TreesToString or LabeledTreesToString,
+trees without label get label 0
+*/
+func LabeledTreesFromString(buf []byte) ([]*Tree, []int) {
+	trees := []*Tree{}
+	labels := []int{}
+	scanner := NewModelScanner(buf)
+	text := bytes.Buffer{}
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) > 0 && line[0] == '#' {
+			tree := Tree{}
+			tree.FromString(text.String())
+			trees = append(trees, &tree)
+			label := 0
+			if len(line) > 2 && line[1] == '\t' {
+				label, _ = strconv.Atoi(string(line[2:]))
+			}
+			labels = append(labels, label)
+			text.Reset()
+		} else {
+			text.Write(line)
+			text.WriteByte('\n')
+		}
+	}
+	return trees, labels
+}
+
+func TreesToBinary(w *BinaryWriter, trees []*Tree) {
+	w.Int(len(trees))
+	for _, tree := range trees {
+		tree.WriteBinary(w)
+	}
+}
+
+func TreesFromBinary(r *BinaryReader) []*Tree {
+	n := r.Len()
+	trees := []*Tree{}
+	for i := 0; i < n; i++ {
+		tree := Tree{}
+		tree.ReadBinary(r)
+		trees = append(trees, &tree)
+	}
+	return trees
+}
+
+/*
+TreesFeatureCount counts distinct features used by splits of trees
+*/
+func TreesFeatureCount(trees []*Tree) int {
+	features := make(map[int64]bool)
+	for _, tree := range trees {
+		for _, node := range tree.nodes {
+			if node.isSplit() {
+				features[node.feature_split.Id] = true
+			}
+		}
+	}
+	return len(features)
+}
+
 type RDTParams struct {
 	TreeCount   int
 	MinLeafSize int
@@ -99,31 +286,54 @@ type RDTParams struct {
 type RandomDecisionTree struct {
 	trees []*Tree
 	params RDTParams
+	// random generator of training, each tree has its own
+	rng *rand.Rand
+	init_params map[string]string
 }
 
-func (self *RandomDecisionTree) SaveModel(path string){
-
+func (self *RandomDecisionTree) SaveModel(path string) error {
+	header := NewModelHeader(self, self.init_params, TreesFeatureCount(self.trees))
+	if header.Binary() {
+		w := NewBinaryWriter(header.Format)
+		TreesToBinary(w, self.trees)
+		return WriteModelFile(path, header, w.Bytes())
+	}
+	return WriteModelFile(path, header, TreesToString(self.trees))
 }
 
-func (self *RandomDecisionTree) LoadModel(path string){
-	
+func (self *RandomDecisionTree) LoadModel(path string) error {
+	header, body, err := ReadModelBody(path, self)
+	if err != nil {
+		return err
+	}
+	if header.Binary() {
+		r := NewBinaryReader(body, header.Format).WithVersion(header.Version)
+		self.trees = TreesFromBinary(r)
+		return r.Err()
+	}
+	self.trees = TreesFromString(body)
+	return nil
 }
 
 func (rdt *RandomDecisionTree) AppendNodeToTree(samples []*MapBasedSample, node *TreeNode, queue *list.List, tree *Tree) {
 	node.prediction = NewArrayVector()
 	for _, k := range node.samples {
-		node.prediction.AddValue(samples[k].Label, 1.0)
+		node.prediction.AddValue(samples[k].Label, samples[k].GetWeight())
 	}
-	node.prediction.Scale(1.0 / node.prediction.Sum())
+	normalizeLabels(node.prediction, samples, node.samples)
 
-	random_sample := samples[node.samples[rand.Intn(len(node.samples))]]
+	random_sample := samples[node.samples[rdt.rng.Intn(len(node.samples))]]
 
+	// features are sorted, since order of maps is random even for the same seed
+	fids := []int64{}
+	for fid, _ := range random_sample.Features {
+		fids = append(fids, fid)
+	}
+	sort.Slice(fids, func(i, j int) bool { return fids[i] < fids[j] })
 	split := Feature{Id:-1, Value: -1.0}
-	for fid, fvalue := range random_sample.Features {
-		if split.Id < 0 || rand.Intn(len(random_sample.Features)) == 0 {
-			split.Id = fid
-			split.Value = fvalue
-		}
+	if len(fids) > 0 {
+		split.Id = fids[rdt.rng.Intn(len(fids))]
+		split.Value = random_sample.Features[split.Id]
 	}
 
 	if split.Id < 0 || node.depth > rdt.params.MaxDepth {
@@ -134,13 +344,18 @@ func (rdt *RandomDecisionTree) AppendNodeToTree(samples []*MapBasedSample, node
 	left_node := TreeNode{depth: node.depth + 1, left: -1, right: -1, prediction: nil, sample_count: 0, samples: []int{}}
 	right_node := TreeNode{depth: node.depth + 1, left: -1, right: -1, prediction: nil, sample_count: 0, samples: []int{}}
 
+	left_dis := NewArrayVector()
+	right_dis := NewArrayVector()
 	for _, k := range node.samples {
-		if DTGoLeft(samples[k], node.feature_split) {
+		if DTGoLeft(samples[k], node) {
 			left_node.samples = append(left_node.samples, k)
+			left_dis.AddValue(samples[k].Label, samples[k].GetWeight())
 		} else {
 			right_node.samples = append(right_node.samples, k)
+			right_dis.AddValue(samples[k].Label, samples[k].GetWeight())
 		}
 	}
+	node.gain = GiniGain(left_dis, right_dis)
 	node.samples = nil
 
 	if len(left_node.samples) == 0 || len(right_node.samples) == 0 {
@@ -166,12 +381,12 @@ func (rdt *RandomDecisionTree) SingleTreeBuild(samples []*MapBasedSample) Tree {
 	root := TreeNode{depth: 0, left: -1, right: -1, prediction: NewArrayVector(), samples: []int{}}
 	
 	for i := 0; i < len(samples); i++{
-		k := rand.Intn(len(samples))
+		k := rdt.rng.Intn(len(samples))
 		root.AddSample(k)
-		root.prediction.AddValue(samples[k].Label, 1.0)
+		root.prediction.AddValue(samples[k].Label, samples[k].GetWeight())
 	}
 	root.sample_count = len(root.samples)
-	root.prediction.Scale(1.0 / root.prediction.Sum())
+	normalizeLabels(root.prediction, samples, root.samples)
 
 	queue.PushBack(&root)
 	tree.AddTreeNode(&root)
@@ -190,35 +405,51 @@ func (rdt *RandomDecisionTree) SingleTreeBuild(samples []*MapBasedSample) Tree {
 
 func (rdt *RandomDecisionTree) RandomShuffle(features []Feature){
 	for i := range features {
-	    j := rand.Intn(i + 1)
+	    j := rdt.rng.Intn(i + 1)
 	    features[i], features[j] = features[j], features[i]
 	}
 }
 
+/*
+withRand returns a copy of rdt which draws random numbers from rng, so trees can be built concurrently
+*/
+func (rdt *RandomDecisionTree) withRand(rng *rand.Rand) *RandomDecisionTree {
+	ret := *rdt
+	ret.rng = rng
+	return &ret
+}
+
 func (rdt *RandomDecisionTree) Train(dataset * DataSet) {
+	rdt.TrainContext(context.Background(), dataset, nil)
+}
+
+/*
+TrainContext builds trees until ctx is done, the forest keeps the trees built
+*/
+func (rdt *RandomDecisionTree) TrainContext(ctx context.Context, dataset *DataSet, sink ProgressSink) error {
 	samples := []*MapBasedSample{}
 	for _, sample := range dataset.Samples{
 		samples = append(samples, sample.ToMapBasedSample())
 	}
 	dataset.Samples = nil
 
-	forest := make(chan *Tree, rdt.params.TreeCount)
-	var wait sync.WaitGroup
-	wait.Add(rdt.params.TreeCount)
-	for k := 0; k < rdt.params.TreeCount; k++ {
-		go func() {
-			tree := rdt.SingleTreeBuild(samples)
-			forest <- &tree
-			fmt.Printf(".")
-			wait.Done()
-		}()
-	}
-	wait.Wait()
-	fmt.Println()
-	close(forest)
-	for tree := range forest{
-		rdt.trees = append(rdt.trees, tree)
+	// each tree has its own random generator and slot, so forests of the same seed are the same
+	rngs := NewRands(rdt.rng, rdt.params.TreeCount)
+	forest := make([]*Tree, rdt.params.TreeCount)
+	err := parallelSteps(ctx, rdt.params.TreeCount, TrainEventEnum.TREE, sink, func(k int) {
+		tree := rdt.withRand(rngs[k]).SingleTreeBuild(samples)
+		forest[k] = &tree
+	})
+	for _, tree := range forest {
+		if tree != nil {
+			rdt.trees = append(rdt.trees, tree)
+		}
 	}
+	return err
+}
+
+func (rdt *RandomDecisionTree) Trees() []*Tree {
+	return rdt.trees
 }
 
 func (rdt *RandomDecisionTree) Predict(sample * Sample) float64 {
@@ -230,6 +461,10 @@ func (rdt *RandomDecisionTree) Predict(sample * Sample) float64 {
 		ret += node.prediction.GetValue(1)
 		total += 1.0
 	}	
+	// a forest without trees, e.g. training is cancelled before the first tree, knows nothing
+	if total == 0 {
+		return 0.5
+	}
 	return ret / total
 }
 
@@ -242,14 +477,40 @@ func (rdt *RandomDecisionTree) PredictMultiClass(sample * Sample) *ArrayVector {
 		predictions.AddVector(node.prediction, 1.0)
 		total += 1.0
 	}
-	predictions.Scale(1.0 / total)
+	if total > 0 {
+		predictions.Scale(1.0 / total)
+	}
 	return predictions
 }
 
-func (rdt *RandomDecisionTree) Init(params map[string]string) {
+var RDTParamSchema = ParamSchema{
+	IntParam("tree-count", 10, 1, math.Inf(1), "tree count in rdt/rf/gbdt"),
+	IntParam("min-leaf-size", 10, 0, math.Inf(1), "min leaf size in dt"),
+	IntParam("max-depth", 10, 1, math.Inf(1), "max depth of dt"),
+	SeedParam,
+}
+
+func init() {
+	RegisterAlgorithm(Algorithm{
+		Name: "rdt",
+		Description: "random decision trees",
+		Types: AlgorithmTypeEnum.BINARY | AlgorithmTypeEnum.MULTI_CLASS,
+		Params: RDTParamSchema,
+		New: func() interface{} { return &(RandomDecisionTree{}) },
+	})
+}
+
+func (rdt *RandomDecisionTree) Init(params map[string]string) error {
 	rdt.trees = []*Tree{}
-	rdt.params.MinLeafSize, _ = strconv.Atoi(params["min-leaf-size"])
-	rdt.params.TreeCount, _ = strconv.Atoi(params["tree-count"])
-	rdt.params.MaxDepth, _ = strconv.Atoi(params["max-depth"])
+	values, err := RDTParamSchema.Parse(params)
+	if err != nil {
+		return err
+	}
+	rdt.params.MinLeafSize = values.Int("min-leaf-size")
+	rdt.params.TreeCount = values.Int("tree-count")
+	rdt.params.MaxDepth = values.Int("max-depth")
+	rdt.rng = NewRand(int64(values.Int("seed")))
+	rdt.init_params = params
+	return nil
 }
 
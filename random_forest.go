@@ -1,65 +1,101 @@
 package hector
 
 import (
-	"strconv"
-	"os"
-	"bufio"
-	"fmt"
-	"sync"
+	"context"
+	"math"
 )
 
 type RandomForestParams struct {
 	TreeCount int
 	FeatureCount float64
+	OOB bool
 }
 
+/*
+RandomForest trains each tree on a bootstrap sample of the train dataset. With the oob param, Train also
+predicts each train sample by trees whose bootstrap samples miss it, which estimates accuracy of the forest
+without a validation dataset
+*/
 type RandomForest struct {
 	trees []*Tree
 	params RandomForestParams
 	cart CART
 	continuous_features bool
+	oob_predictions []*ArrayVector
+	oob_labels []int
+	oob_weights []float64
+	init_params map[string]string
 }
 
-func (self *RandomForest) SaveModel(path string){
-	file, _ := os.Create(path)
-	defer file.Close()
-	for _, tree := range self.trees {
-		buf := tree.ToString()
-		file.Write(buf)
-		file.WriteString("\n#\n")
+func (self *RandomForest) SaveModel(path string) error {
+	header := NewModelHeader(self, self.init_params, TreesFeatureCount(self.trees))
+	if header.Binary() {
+		w := NewBinaryWriter(header.Format)
+		TreesToBinary(w, self.trees)
+		return WriteModelFile(path, header, w.Bytes())
 	}
+	return WriteModelFile(path, header, TreesToString(self.trees))
 }
 
-func (self *RandomForest) LoadModel(path string){
-	file, _ := os.Open(path)
-	defer file.Close()
-
-	self.trees = []*Tree{}
-	scanner := bufio.NewScanner(file)
-	text := ""
-	for scanner.Scan() {
-		line := scanner.Text()
-		if line == "#" {
-			tree := Tree{}
-			tree.FromString(text)
-			self.trees = append(self.trees, &tree)
-			text = ""
-		} else {
-			text += line + "\n"
-		}
+func (self *RandomForest) LoadModel(path string) error {
+	header, body, err := ReadModelBody(path, self)
+	if err != nil {
+		return err
+	}
+	if header.Binary() {
+		r := NewBinaryReader(body, header.Format).WithVersion(header.Version)
+		self.trees = TreesFromBinary(r)
+		return r.Err()
 	}
+	self.trees = TreesFromString(body)
+	return nil
+}
+
+var RandomForestParamSchema = MergeParamSchemas(ParamSchema{
+	IntParam("tree-count", 10, 1, math.Inf(1), "tree count in rdt/rf/gbdt"),
+	PositiveFloatParam("feature-count", 1.0, 1.0, "ratio of features used by each tree in rf"),
+	IntParam("oob", 0, 0, 1, "rf evaluates out-of-bag predictions of train samples after training if 1"),
+}, CARTParamSchema)
+
+func init() {
+	RegisterAlgorithm(Algorithm{
+		Name: "rf",
+		Description: "random forest",
+		Types: AlgorithmTypeEnum.BINARY | AlgorithmTypeEnum.MULTI_CLASS,
+		Params: RandomForestParamSchema,
+		New: func() interface{} { return &(RandomForest{}) },
+	})
 }
 
-func (dt *RandomForest) Init(params map[string]string){
+func (dt *RandomForest) Init(params map[string]string) error {
 	dt.trees = []*Tree{}
-	dt.cart.Init(params)
-	tree_count,_ := strconv.ParseInt(params["tree-count"], 10, 64)
-	feature_count,_ := strconv.ParseFloat(params["feature-count"], 64)
-	dt.params.TreeCount = int(tree_count)
-	dt.params.FeatureCount = feature_count
+	values, err := RandomForestParamSchema.Parse(params)
+	if err != nil {
+		return err
+	}
+	err = dt.cart.Init(params)
+	if err != nil {
+		return err
+	}
+	dt.params.TreeCount = values.Int("tree-count")
+	dt.params.FeatureCount = values.Float("feature-count")
+	dt.params.OOB = values.Int("oob") == 1
+	dt.oob_predictions = nil
+	dt.oob_labels = nil
+	dt.oob_weights = nil
+	dt.init_params = params
+	return nil
 }
 
 func (dt *RandomForest) Train(dataset * DataSet) {
+	dt.TrainContext(context.Background(), dataset, nil)
+}
+
+/*
+TrainContext builds trees until ctx is done, the forest keeps the trees built. Out-of-bag predictions are
+only evaluated if all trees are built
+*/
+func (dt *RandomForest) TrainContext(ctx context.Context, dataset *DataSet, sink ProgressSink) error {
 	samples := []*MapBasedSample{}
 	feature_weights := make(map[int64]float64)
 	for _, sample := range dataset.Samples{
@@ -78,26 +114,115 @@ func (dt *RandomForest) Train(dataset * DataSet) {
 		samples = append(samples, msample)
 	}
 	dt.cart.continuous_features = dt.continuous_features
+	dt.cart.prepareBins(dataset)
+	defer func() {
+		dt.cart.bins = nil
+	}()
 	
-	trees := make(chan *Tree, dt.params.TreeCount)
-	var wait sync.WaitGroup
-	wait.Add(dt.params.TreeCount)
+	// each tree has its own random generator and slot, so forests of the same seed are the same
+	rngs := NewRands(dt.cart.rng, dt.params.TreeCount)
+	bags := make([]baggedTree, dt.params.TreeCount)
+	err := parallelSteps(ctx, dt.params.TreeCount, TrainEventEnum.TREE, sink, func(i int) {
+		tree, in_bag := dt.cart.withRand(rngs[i]).BaggedTreeBuild(samples, dt.params.FeatureCount, true)
+		bags[i] = baggedTree{tree: &tree, in_bag: in_bag}
+	})
+	for _, bagged := range bags {
+		if bagged.tree != nil {
+			dt.trees = append(dt.trees, bagged.tree)
+		}
+	}
+
+	dt.oob_predictions = nil
+	dt.oob_labels = nil
+	dt.oob_weights = nil
+	if dt.params.OOB && err == nil {
+		dt.oob_predictions = oobPredictions(samples, bags)
+		dt.oob_labels = make([]int, len(samples))
+		dt.oob_weights = make([]float64, len(samples))
+		for i, sample := range samples {
+			dt.oob_labels[i] = sample.Label
+			dt.oob_weights[i] = sample.GetWeight()
+		}
+		auc, accuracy, count := dt.OOBEvaluate()
+		sink.Send(TrainEvent{Kind: TrainEventEnum.OUT_OF_BAG, Step: count, Total: len(samples), Metric: "auc", Value: auc})
+		sink.Send(TrainEvent{Kind: TrainEventEnum.OUT_OF_BAG, Step: count, Total: len(samples), Metric: "accuracy", Value: accuracy})
+	}
+	return err
+}
+
+type baggedTree struct {
+	tree *Tree
+	in_bag []bool
+}
 
-	for i:= 0; i < dt.params.TreeCount; i++{
+/*
+oobPredictions averages label distributions of each sample by trees whose bootstrap samples miss it
+*/
+func oobPredictions(samples []*MapBasedSample, bags []baggedTree) []*ArrayVector {
+	ret := make([]*ArrayVector, len(samples))
+	counts := make([]float64, len(samples))
+	for _, bagged := range bags {
+		for i, sample := range samples {
+			if bagged.in_bag[i] {
+				continue
+			}
+			node, _ := PredictBySingleTree(bagged.tree, sample)
+			if ret[i] == nil {
+				ret[i] = NewArrayVector()
+			}
+			ret[i].AddVector(node.prediction, 1.0)
+			counts[i] += 1.0
+		}
+	}
+	for i, prediction := range ret {
+		if prediction != nil {
+			prediction.Scale(1.0 / counts[i])
+		}
+	}
+	return ret
+}
+
+/*
+OOBPredictions returns the out-of-bag label distribution of each sample of the last train dataset, in
+order of samples. It is nil for samples in bootstrap samples of all trees, and for forests trained
+without the oob param or loaded from model files
+*/
+func (dt *RandomForest) OOBPredictions() []*ArrayVector {
+	return dt.oob_predictions
+}
 
-		go func(){
-			tree := dt.cart.SingleTreeBuild(samples, dt.params.FeatureCount, true)
-			trees <- &tree
-			fmt.Printf(".")
-			wait.Done()
-		}()
+/*
+OOBEvaluate returns AUC of label 1 and accuracy of out-of-bag predictions of the last train dataset, both
+weighted by samples, and the count of samples with out-of-bag predictions
+*/
+func (dt *RandomForest) OOBEvaluate() (float64, float64, int) {
+	predictions := []*LabelPrediction{}
+	correct := 0.0
+	total := 0.0
+	for i, prediction := range dt.oob_predictions {
+		if prediction == nil {
+			continue
+		}
+		w := dt.oob_weights[i]
+		predictions = append(predictions, &(LabelPrediction{Label: dt.oob_labels[i], Prediction: prediction.GetValue(1), Weight: w, HasWeight: true}))
+		total += w
+		label, _ := prediction.KeyWithMaxValue()
+		if label == dt.oob_labels[i] {
+			correct += w
+		}
+	}
+	if len(predictions) == 0 {
+		return 0.5, 0.0, 0
 	}
-	wait.Wait()
-	fmt.Println()
-	close(trees)
-	for tree := range trees {
-		dt.trees = append(dt.trees, tree)
+	accuracy := 0.0
+	if total > 0 {
+		accuracy = correct / total
 	}
+	return AUC(predictions), accuracy, len(predictions)
+}
+
+func (dt *RandomForest) Trees() []*Tree {
+	return dt.trees
 }
 
 func (dt *RandomForest) Predict(sample * Sample) float64 {
@@ -109,6 +234,10 @@ func (dt *RandomForest) Predict(sample * Sample) float64 {
 		predictions += node.prediction.GetValue(1)
 		total += 1.0
 	}
+	// a forest without trees, e.g. training is cancelled before the first tree, knows nothing
+	if total == 0 {
+		return 0.5
+	}
 	return predictions / total
 }
 
@@ -121,6 +250,8 @@ func (dt *RandomForest) PredictMultiClass(sample * Sample) *ArrayVector {
 		predictions.AddVector(node.prediction, 1.0)
 		total += 1.0
 	}
-	predictions.Scale(1.0 / total)
+	if total > 0 {
+		predictions.Scale(1.0 / total)
+	}
 	return predictions
 }
@@ -0,0 +1,90 @@
+package hector
+
+import (
+	"testing"
+)
+
+func TestRandomForestOOB(t *testing.T) {
+	train_dataset := importanceDataSet(1000)
+	params := DefaultParams()
+	params["max-depth"] = "4"
+	params["min-leaf-size"] = "5"
+	params["tree-count"] = "20"
+	params["oob"] = "1"
+
+	rf := RandomForest{}
+	err := rf.Init(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rf.Train(train_dataset)
+	oob := rf.OOBPredictions()
+	if len(oob) != len(train_dataset.Samples) {
+		t.Fatalf("%d out-of-bag predictions of %d samples", len(oob), len(train_dataset.Samples))
+	}
+	auc, accuracy, count := rf.OOBEvaluate()
+	t.Logf("out-of-bag auc : %f, accuracy : %f, samples : %d", auc, accuracy, count)
+	if count < 990 || auc < 0.95 || accuracy < 0.95 {
+		t.Errorf("out-of-bag auc %f and accuracy %f of %d samples are too low", auc, accuracy, count)
+	}
+
+	// about 1 / e of samples are out of bag of each tree
+	samples := []*MapBasedSample{}
+	for _, sample := range train_dataset.Samples {
+		samples = append(samples, sample.ToMapBasedSample())
+	}
+	_, in_bag := rf.cart.BaggedTreeBuild(samples, 1.0, true)
+	out := 0
+	for _, ok := range in_bag {
+		if !ok {
+			out++
+		}
+	}
+	if out < 300 || out > 440 {
+		t.Errorf("%d of %d samples are out of bag", out, len(samples))
+	}
+	if _, in_bag = rf.cart.BaggedTreeBuild(samples, 1.0, false); in_bag != nil {
+		t.Error("tree without bootstrap has an in-bag mask")
+	}
+
+	// samples of weight 0 do not count in out-of-bag AUC and accuracy
+	weighted_dataset := importanceDataSet(1000)
+	for i, sample := range weighted_dataset.Samples {
+		if i % 2 == 0 {
+			sample.Weight = 0
+		}
+	}
+	labels := []int{}
+	for _, sample := range weighted_dataset.Samples {
+		labels = append(labels, sample.Label)
+	}
+	rf.Train(weighted_dataset)
+	predictions := []*LabelPrediction{}
+	correct := 0
+	weighted := 0
+	for i, prediction := range rf.OOBPredictions() {
+		if prediction == nil {
+			continue
+		}
+		// AUC does not rank ties, so samples of weight 0 stay in the list to keep the order of ties
+		predictions = append(predictions, &(LabelPrediction{Label: labels[i], Prediction: prediction.GetValue(1), Weight: float64(i % 2), HasWeight: true}))
+		if i % 2 == 0 {
+			continue
+		}
+		weighted++
+		if label, _ := prediction.KeyWithMaxValue(); label == labels[i] {
+			correct++
+		}
+	}
+	auc, accuracy, _ = rf.OOBEvaluate()
+	if auc != AUC(predictions) || accuracy != float64(correct) / float64(weighted) {
+		t.Errorf("out-of-bag auc %f and accuracy %f count samples of weight 0, expected %f and %f", auc, accuracy, AUC(predictions), float64(correct) / float64(weighted))
+	}
+
+	params["oob"] = "0"
+	rf.Init(params)
+	rf.Train(importanceDataSet(1000))
+	if rf.OOBPredictions() != nil {
+		t.Error("forest without oob param has out-of-bag predictions")
+	}
+}
@@ -1,41 +1,57 @@
 package hector
 
 import (
-	"strconv"
+	"math"
+	"math/rand"
 	"sort"
 	"container/list"
-	"os"
-	"bufio"
-	"io/ioutil"
 )
 
+/*
+RegressionTree fits Prediction of samples. In column subsampling of GBDT, features are the features
+of the tree, and each level of the tree splits by level_ratio of them, which are sampled by rng.
+Splits reduce variance of Prediction, or gain most by objective if it is set, then Prediction of
+samples is their negative gradient and hessians are their hessians, both multiplied by weights of samples
+*/
 type RegressionTree struct {
 	tree Tree
 	params CARTParams
+	bins *FeatureBins
+	features []int64
+	level_ratio float64
+	rng *rand.Rand
+	level_features map[int]map[int64]bool
+	objective *TreeObjective
+	hessians []float64
+	init_params map[string]string
 }
 
-func (self *RegressionTree) SaveModel(path string){
-	ioutil.WriteFile(path, self.tree.ToString(), 0600)
+func (self *RegressionTree) SaveModel(path string) error {
+	header := NewModelHeader(self, self.init_params, TreesFeatureCount([]*Tree{&(self.tree)}))
+	if header.Binary() {
+		w := NewBinaryWriter(header.Format)
+		self.tree.WriteBinary(w)
+		return WriteModelFile(path, header, w.Bytes())
+	}
+	return WriteModelFile(path, header, self.tree.ToString())
 }
 
-func (self *RegressionTree) LoadModel(path string){
-	file, _ := os.Open(path)
-	defer file.Close()
-	text := ""
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		text += scanner.Text() + "\n"
+func (self *RegressionTree) LoadModel(path string) error {
+	header, body, err := ReadModelBody(path, self)
+	if err != nil {
+		return err
+	}
+	if header.Binary() {
+		r := NewBinaryReader(body, header.Format).WithVersion(header.Version)
+		self.tree.ReadBinary(r)
+		return r.Err()
 	}
-	self.tree.FromString(string(text))
+	self.tree.FromString(string(body))
+	return nil
 }
 
-func (dt *RegressionTree) GoLeft(sample *MapBasedSample, feature_split Feature) bool {
-	value, ok := sample.Features[feature_split.Id]
-	if ok && value >= feature_split.Value {
-		return true
-	} else {
-		return false
-	}
+func (dt *RegressionTree) GoLeft(sample *MapBasedSample, node *TreeNode) bool {
+	return DTGoLeft(sample, node)
 }
 
 func (dt *RegressionTree) GetElementFromQueue(queue *list.List, n int) []*TreeNode {
@@ -52,14 +68,23 @@ func (dt *RegressionTree) GetElementFromQueue(queue *list.List, n int) []*TreeNo
 }
 
 func (dt *RegressionTree) FindBestSplit(samples []*MapBasedSample, node *TreeNode, select_features map[int64]bool){
+	if dt.bins != nil {
+		dt.findBestSplitByHistogram(samples, node, select_features)
+		return
+	}
+	if dt.objective != nil {
+		dt.findBestSplitByObjective(samples, node, select_features)
+		return
+	}
 	feature_weight_labels := make(map[int64]*FeatureGoalDistribution)
 	sum_total := 0.0
 	sum_total2 := 0.0
 	count_total := 0.0
 	for _, k := range node.samples{
-		sum_total += samples[k].Prediction
-		sum_total2 += samples[k].Prediction * samples[k].Prediction
-		count_total += 1.0
+		w := samples[k].GetWeight()
+		sum_total += w * samples[k].Prediction
+		sum_total2 += w * samples[k].Prediction * samples[k].Prediction
+		count_total += w
 	}
 
 	feature_sum_right := NewVector()
@@ -68,20 +93,32 @@ func (dt *RegressionTree) FindBestSplit(samples []*MapBasedSample, node *TreeNod
 
 	for _, k := range node.samples{
 		for fid, fvalue := range samples[k].Features {
-			feature_count_right.AddValue(fid, 1.0)
-			feature_sum_right.AddValue(fid, samples[k].Prediction)
-			feature_sum_right2.AddValue(fid, samples[k].Prediction * samples[k].Prediction)
+			if select_features != nil && !select_features[fid] {
+				continue
+			}
+			w := samples[k].GetWeight()
+			feature_count_right.AddValue(fid, w)
+			feature_sum_right.AddValue(fid, w * samples[k].Prediction)
+			feature_sum_right2.AddValue(fid, w * samples[k].Prediction * samples[k].Prediction)
 			_, ok := feature_weight_labels[fid]
 			if !ok {
 				feature_weight_labels[fid] = NewFeatureGoalDistribution()
 			}
-			feature_weight_labels[fid].AddWeightGoal(fvalue, samples[k].Prediction)
+			feature_weight_labels[fid].AddWeightGoal(fvalue, samples[k].Prediction, w)
 		}
 	}
 	
 	min_vari := 1e20
-	node.feature_split = Feature{Id:-1, Value: 0}
+	node.setSplit(-1, 0.0, false, nil)
 	for fid, distribution := range feature_weight_labels{
+		if dt.params.CategoricalFeatures[fid] {
+			categories, gain, missing_left, ok := dt.bestCategorySplit(samples, node, fid, []float64{sum_total, sum_total2, count_total})
+			if ok && (min_vari > -gain || (min_vari == -gain && fid < node.feature_split.Id)) {
+				min_vari = -gain
+				node.setSplit(fid, 0.0, missing_left, categories)
+			}
+			continue
+		}
 		sort.Sort(distribution)
 		split, vari := distribution.BestSplitByVariance(sum_total - feature_sum_right.GetValue(fid),
 			sum_total2 - feature_sum_right2.GetValue(fid),
@@ -89,20 +126,259 @@ func (dt *RegressionTree) FindBestSplit(samples []*MapBasedSample, node *TreeNod
 			feature_sum_right.GetValue(fid),
 			feature_sum_right2.GetValue(fid),
 			feature_count_right.GetValue(fid))
-		if min_vari > vari {
+		missing_left := false
+		// samples without the feature go with small values, or with large values if it is better
+		if feature_count_right.GetValue(fid) < count_total {
+			missing_split, missing_vari := distribution.BestSplitByVariance(0, 0, 0, sum_total, sum_total2, count_total)
+			if missing_vari < vari {
+				split, vari, missing_left = missing_split, missing_vari, true
+			}
+		}
+		// ties are broken by feature id, so trees do not depend on order of maps
+		if min_vari > vari || (min_vari == vari && fid < node.feature_split.Id) {
 			min_vari = vari
-			node.feature_split.Id = fid
-			node.feature_split.Value = split
+			node.setSplit(fid, split, missing_left, nil)
+		}
+	}
+}
+
+/*
+findBestSplitByObjective finds the split of largest gain of objective by sorted values of features
+*/
+func (dt *RegressionTree) findBestSplitByObjective(samples []*MapBasedSample, node *TreeNode, select_features map[int64]bool) {
+	feature_weight_labels := make(map[int64]*FeatureGoalDistribution)
+	sum_total := 0.0
+	hess_total := 0.0
+	count_total := 0.0
+	for _, k := range node.samples {
+		sum_total += samples[k].Prediction
+		hess_total += dt.hessians[k]
+		count_total += 1.0
+	}
+
+	feature_sum_right := NewVector()
+	feature_hess_right := NewVector()
+	feature_count_right := NewVector()
+
+	for _, k := range node.samples {
+		for fid, fvalue := range samples[k].Features {
+			if select_features != nil && !select_features[fid] {
+				continue
+			}
+			feature_count_right.AddValue(fid, 1.0)
+			feature_sum_right.AddValue(fid, samples[k].Prediction)
+			feature_hess_right.AddValue(fid, dt.hessians[k])
+			_, ok := feature_weight_labels[fid]
+			if !ok {
+				feature_weight_labels[fid] = NewFeatureGoalDistribution()
+			}
+			feature_weight_labels[fid].AddWeightGoalHessian(fvalue, samples[k].Prediction, dt.hessians[k])
+		}
+	}
+
+	max_gain := 0.0
+	node.setSplit(-1, 0.0, false, nil)
+	for fid, distribution := range feature_weight_labels {
+		if dt.params.CategoricalFeatures[fid] {
+			categories, gain, missing_left, ok := dt.bestCategorySplit(samples, node, fid, []float64{sum_total, hess_total, count_total})
+			if ok && (node.feature_split.Id < 0 || max_gain < gain || (max_gain == gain && fid < node.feature_split.Id)) {
+				max_gain = gain
+				node.setSplit(fid, 0.0, missing_left, categories)
+			}
+			continue
+		}
+		sort.Sort(distribution)
+		split, gain, ok := distribution.BestSplitByObjective(dt.objective,
+			sum_total - feature_sum_right.GetValue(fid),
+			hess_total - feature_hess_right.GetValue(fid),
+			count_total - feature_count_right.GetValue(fid),
+			feature_sum_right.GetValue(fid),
+			feature_hess_right.GetValue(fid),
+			feature_count_right.GetValue(fid))
+		missing_left := false
+		if feature_count_right.GetValue(fid) < count_total {
+			missing_split, missing_gain, missing_ok := distribution.BestSplitByObjective(dt.objective, 0, 0, 0, sum_total, hess_total, count_total)
+			if missing_ok && (!ok || missing_gain > gain) {
+				split, gain, ok, missing_left = missing_split, missing_gain, true, true
+			}
+		}
+		if !ok {
+			continue
+		}
+		if node.feature_split.Id < 0 || max_gain < gain || (max_gain == gain && fid < node.feature_split.Id) {
+			max_gain = gain
+			node.setSplit(fid, split, missing_left, nil)
+		}
+	}
+}
+
+/*
+bestCategorySplit finds the best set of categories of feature fid in node, total is statistics of samples
+in node. Gain is the negative variance, or gain of objective if it is set
+*/
+func (dt *RegressionTree) bestCategorySplit(samples []*MapBasedSample, node *TreeNode, fid int64, total []float64) ([]float64, float64, bool, bool) {
+	stats := NewCategoryStats(3)
+	for _, k := range node.samples {
+		value, ok := samples[k].Features[fid]
+		if !ok {
+			continue
+		}
+		goal := samples[k].Prediction
+		if dt.objective != nil {
+			stats.Add(value, goal, dt.hessians[k], 1.0)
+		} else {
+			w := samples[k].GetWeight()
+			stats.Add(value, w * goal, w * goal * goal, w)
+		}
+	}
+	if dt.objective == nil {
+		return stats.BestSplit(total, meanGoal, varianceGain)
+	}
+	// categories are ordered by their leaf values without L1 regularization
+	key := func(s []float64) float64 {
+		h := s[1] + dt.objective.Lambda
+		if h <= 0 {
+			return 0.0
+		}
+		return s[0] / h
+	}
+	gain := func(left, right []float64) (float64, bool) {
+		if left[2] <= 0 || right[2] <= 0 {
+			return 0.0, false
+		}
+		return dt.objective.Gain(-left[0], left[1], -right[0], right[1])
+	}
+	return stats.BestSplit(total, key, gain)
+}
+
+/*
+histogram keeps weighted sum of goals, sum of squared goals and count of samples in node, or sum of
+goals, sum of hessians and count of samples with objective
+*/
+func (dt *RegressionTree) histogram(samples []*MapBasedSample, node *TreeNode) *Histogram {
+	ret := NewHistogram(3)
+	for _, k := range node.samples {
+		goal := samples[k].Prediction
+		if dt.objective != nil {
+			ret.Add(dt.bins, k, goal, dt.hessians[k], 1.0)
+		} else {
+			w := samples[k].GetWeight()
+			ret.Add(dt.bins, k, w * goal, w * goal * goal, w)
+		}
+	}
+	return ret
+}
+
+/*
+findBestSplitByHistogram scans bins of features instead of sorted values, split values are thresholds of bins
+*/
+func (dt *RegressionTree) findBestSplitByHistogram(samples []*MapBasedSample, node *TreeNode, select_features map[int64]bool) {
+	sum_total := 0.0
+	sum_total2 := 0.0
+	count_total := 0.0
+	for _, k := range node.samples {
+		if dt.objective != nil {
+			sum_total += samples[k].Prediction
+			sum_total2 += dt.hessians[k]
+			count_total += 1.0
+		} else {
+			w := samples[k].GetWeight()
+			sum_total += w * samples[k].Prediction
+			sum_total2 += w * samples[k].Prediction * samples[k].Prediction
+			count_total += w
+		}
+	}
+	if node.histogram == nil {
+		node.histogram = dt.histogram(samples, node)
+	}
+	hist := node.histogram
+
+	min_vari := 1e20
+	max_gain := 0.0
+	node.setSplit(-1, 0.0, false, nil)
+	// bins of categorical features mix categories, their splits are found by samples of node
+	for fid, _ := range dt.params.CategoricalFeatures {
+		if select_features != nil && !select_features[fid] {
+			continue
+		}
+		categories, gain, missing_left, ok := dt.bestCategorySplit(samples, node, fid, []float64{sum_total, sum_total2, count_total})
+		if !ok {
+			continue
+		}
+		if dt.objective != nil && (node.feature_split.Id < 0 || max_gain < gain || (max_gain == gain && fid < node.feature_split.Id)) {
+			max_gain = gain
+			node.setSplit(fid, 0.0, missing_left, categories)
+		} else if dt.objective == nil && (min_vari > -gain || (min_vari == -gain && fid < node.feature_split.Id)) {
+			min_vari = -gain
+			node.setSplit(fid, 0.0, missing_left, categories)
+		}
+	}
+	for fid, _ := range hist.features {
+		if (select_features != nil && !select_features[fid]) || dt.params.CategoricalFeatures[fid] {
+			continue
+		}
+		sum_present, sum_present2, count_present := 0.0, 0.0, 0.0
+		for b := 0; b < hist.Bins(fid); b++ {
+			stats := hist.Stats(fid, b)
+			sum_present += stats[0]
+			sum_present2 += stats[1]
+			count_present += stats[2]
+		}
+		// left and right are sides of small and large values here, samples without the feature go
+		// with small values, or with large values (to the left child) if it is better
+		for _, missing_left := range []bool{false, true} {
+			sum_right, sum_right2, count_right := sum_present, sum_present2, count_present
+			sum_left := sum_total - sum_right
+			sum_left2 := sum_total2 - sum_right2
+			count_left := count_total - count_right
+			if missing_left {
+				if count_left == 0 {
+					break
+				}
+				sum_right, sum_right2, count_right = sum_total, sum_total2, count_total
+				sum_left, sum_left2, count_left = 0.0, 0.0, 0.0
+			}
+			for b := 0; b < hist.Bins(fid); b++ {
+				stats := hist.Stats(fid, b)
+				if stats[2] == 0 {
+					continue
+				}
+				if dt.objective != nil {
+					// sums of squares are sums of hessians here
+					gain, ok := dt.objective.Gain(-sum_left, sum_left2, -sum_right, sum_right2)
+					if ok && count_left > 0 && (node.feature_split.Id < 0 || max_gain < gain || (max_gain == gain && fid < node.feature_split.Id)) {
+						max_gain = gain
+						node.setSplit(fid, dt.bins.Thresholds[fid][b], missing_left, nil)
+					}
+				} else if count_left > 0 {
+					mean_left := sum_left / count_left
+					mean_right := sum_right / count_right
+					vari := sum_left2 + sum_right2 - mean_left * mean_left * count_left - mean_right * mean_right * count_right
+					if min_vari > vari || (min_vari == vari && fid < node.feature_split.Id) {
+						min_vari = vari
+						node.setSplit(fid, dt.bins.Thresholds[fid][b], missing_left, nil)
+					}
+				}
+				sum_left += stats[0]
+				sum_left2 += stats[1]
+				count_left += stats[2]
+				sum_right -= stats[0]
+				sum_right2 -= stats[1]
+				count_right -= stats[2]
+			}
 		}
 	}
 }
 
 func (dt *RegressionTree) AppendNodeToTree(samples []*MapBasedSample, node *TreeNode, queue *list.List, tree *Tree, select_features map[int64]bool) {
+	defer func() {
+		node.histogram = nil
+	}()
 	if node.depth >= dt.params.MaxDepth {
 		return
 	}
 	
-	dt.FindBestSplit(samples, node, select_features)
+	dt.FindBestSplit(samples, node, dt.levelFeatures(node.depth, select_features))
 
 	if node.feature_split.Id < 0{
 		return
@@ -115,21 +391,26 @@ func (dt *RegressionTree) AppendNodeToTree(samples []*MapBasedSample, node *Tree
 	right_positive := 0.0
 	right_total := 0.0
 	for _, k := range node.samples {
-		if dt.GoLeft(samples[k], node.feature_split) {
+		w := dt.goalWeight(samples[k])
+		if dt.GoLeft(samples[k], node) {
 			left_node.samples = append(left_node.samples, k)
-			left_positive += samples[k].Prediction
-			left_total += 1.0
+			left_positive += w * samples[k].Prediction
+			left_total += w
 		} else {
 			right_node.samples = append(right_node.samples, k)
-			right_positive += samples[k].Prediction
-			right_total += 1.0
+			right_positive += w * samples[k].Prediction
+			right_total += w
 		}
 	}
+	node.gain = dt.splitGain(left_node.samples, right_node.samples, left_positive, right_positive, left_total, right_total)
+	setChildHistograms(node, &left_node, &right_node, func(child *TreeNode) *Histogram {
+		return dt.histogram(samples, child)
+	})
 	node.samples = nil
 	
 	if len(left_node.samples) > dt.params.MinLeafSize {
 		left_node.sample_count = len(left_node.samples)
-		left_node.prediction.SetValue(0, left_positive / left_total)
+		left_node.prediction.SetValue(0, nodeMean(left_positive, left_total, samples, left_node.samples))
 		queue.PushBack(&left_node)
 		node.left = len(tree.nodes)
 		tree.AddTreeNode(&left_node)
@@ -137,26 +418,131 @@ func (dt *RegressionTree) AppendNodeToTree(samples []*MapBasedSample, node *Tree
 
 	if len(right_node.samples) > dt.params.MinLeafSize {
 		right_node.sample_count = len(right_node.samples)
-		right_node.prediction.SetValue(0, right_positive / right_total)
+		right_node.prediction.SetValue(0, nodeMean(right_positive, right_total, samples, right_node.samples))
 		queue.PushBack(&right_node)
 		node.right = len(tree.nodes)
 		tree.AddTreeNode(&right_node)
 	}
 }
 
+/*
+goalWeight is the weight of goals of sample in leaf values and splits by variance. Goals and hessians of
+objective are already multiplied by weights of samples, so they are not weighted again
+*/
+func (dt *RegressionTree) goalWeight(sample *MapBasedSample) float64 {
+	if dt.objective != nil {
+		return 1.0
+	}
+	return sample.GetWeight()
+}
+
+/*
+nodeMean is the mean of goals of samples of rows, sum and weight are their weighted sum and their weight. If the
+samples all weigh 0, it is the mean of their goals instead of NaN
+*/
+func nodeMean(sum, weight float64, samples []*MapBasedSample, rows []int) float64 {
+	if weight == 0 {
+		for _, k := range rows {
+			sum += samples[k].Prediction
+			weight += 1.0
+		}
+	}
+	if weight == 0 {
+		return 0.0
+	}
+	return sum / weight
+}
+
+/*
+splitGain is the decrease of objective by a split before gamma, or the decrease of squared error of goals if objective is not set.
+sum_left and sum_right are weighted sums of goals of left and right samples, count_left and count_right are their weights
+*/
+func (dt *RegressionTree) splitGain(left, right []int, sum_left, sum_right, count_left, count_right float64) float64 {
+	if len(left) == 0 || len(right) == 0 {
+		return 0.0
+	}
+	if dt.objective != nil {
+		hess_left, hess_right := 0.0, 0.0
+		for _, k := range left {
+			hess_left += dt.hessians[k]
+		}
+		for _, k := range right {
+			hess_right += dt.hessians[k]
+		}
+		return 0.5 * (dt.objective.Score(-sum_left, hess_left) + dt.objective.Score(-sum_right, hess_right) -
+			dt.objective.Score(-sum_left - sum_right, hess_left + hess_right))
+	}
+	sum := sum_left + sum_right
+	return sum_left * sum_left / count_left + sum_right * sum_right / count_right - sum * sum / (count_left + count_right)
+}
+
+/*
+levelFeatures returns features which nodes of depth can split by, nil means all features
+*/
+func (dt *RegressionTree) levelFeatures(depth int, select_features map[int64]bool) map[int64]bool {
+	if dt.level_ratio >= 1.0 || dt.rng == nil || len(dt.features) == 0 {
+		return select_features
+	}
+	if dt.level_features == nil {
+		dt.level_features = make(map[int]map[int64]bool)
+	}
+	ret, ok := dt.level_features[depth]
+	if !ok {
+		ret = make(map[int64]bool)
+		for _, fid := range SampleFeatures(dt.features, dt.level_ratio, dt.rng) {
+			ret[fid] = true
+		}
+		dt.level_features[depth] = ret
+	}
+	return ret
+}
+
+/*
+SampleFeatures returns ratio of features, at least one. Features should be sorted to be reproducible by rng
+*/
+func SampleFeatures(features []int64, ratio float64, rng *rand.Rand) []int64 {
+	if ratio >= 1.0 || len(features) == 0 {
+		return features
+	}
+	ret := []int64{}
+	for _, fid := range features {
+		if rng.Float64() < ratio {
+			ret = append(ret, fid)
+		}
+	}
+	if len(ret) == 0 {
+		ret = append(ret, features[rng.Intn(len(features))])
+	}
+	return ret
+}
+
 func (dt *RegressionTree) SingleTreeBuild(samples []*MapBasedSample, select_features map[int64]bool) Tree {
+	return dt.buildTree(samples, nil, select_features)
+}
+
+/*
+buildTree builds the tree on samples of rows, nil rows means all samples
+*/
+func (dt *RegressionTree) buildTree(samples []*MapBasedSample, rows []int, select_features map[int64]bool) Tree {
 	tree := Tree{}
 	queue := list.New()
 	root := TreeNode{depth: 0, left: -1, right: -1, prediction: NewArrayVector(), samples: []int{}}
+	if rows == nil {
+		rows = make([]int, len(samples))
+		for i, _ := range rows {
+			rows[i] = i
+		}
+	}
 	total := 0.0
 	positive := 0.0
-	for i, sample := range samples {
+	for _, i := range rows {
 		root.AddSample(i)
-		total += 1.0
-		positive += sample.Prediction
+		w := dt.goalWeight(samples[i])
+		total += w
+		positive += w * samples[i].Prediction
 	}
 	root.sample_count = len(root.samples)
-	root.prediction.SetValue(0, positive / total)
+	root.prediction.SetValue(0, nodeMean(positive, total, samples, root.samples))
 
 	queue.PushBack(&root)
 	tree.AddTreeNode(&root)
@@ -178,7 +564,7 @@ func (dt *RegressionTree) PredictBySingleTree(tree *Tree, sample *MapBasedSample
 	node := tree.GetNode(0)
 	path += node.ToString()
 	for {
-		if dt.GoLeft(sample, node.feature_split) {
+		if dt.GoLeft(sample, node) {
 			if node.left >= 0 && node.left < tree.Size() {
 				node = tree.GetNode(node.left)
 				path += "-" + node.ToString()
@@ -198,12 +584,39 @@ func (dt *RegressionTree) PredictBySingleTree(tree *Tree, sample *MapBasedSample
 }
 
 func (dt *RegressionTree) Train(dataset * DataSet) {
+	dt.TrainSubset(dataset, nil, nil)
+}
+
+/*
+TrainSubset trains the tree on samples of rows of dataset, and splits by features only.
+nil rows or features means all of them
+*/
+func (dt *RegressionTree) TrainSubset(dataset * DataSet, rows []int, features []int64) {
 	samples := []*MapBasedSample{}
 	for _,sample := range dataset.Samples{
 		msample := sample.ToMapBasedSample()
 		samples = append(samples, msample)
 	}
-	dt.tree = dt.SingleTreeBuild(samples, nil)
+	dt.bins = nil
+	if dt.params.MaxBins > 0 {
+		dt.bins = dataset.FeatureBins(dt.params.MaxBins)
+	}
+	var select_features map[int64]bool
+	dt.features = features
+	dt.level_features = nil
+	if features != nil {
+		select_features = make(map[int64]bool)
+		for _, fid := range features {
+			select_features[fid] = true
+		}
+	}
+	dt.tree = dt.buildTree(samples, rows, select_features)
+	dt.bins = nil
+	dt.level_features = nil
+}
+
+func (dt *RegressionTree) Trees() []*Tree {
+	return []*Tree{&(dt.tree)}
 }
 
 func (dt *RegressionTree) Predict(sample * Sample) float64 {
@@ -212,13 +625,38 @@ func (dt *RegressionTree) Predict(sample * Sample) float64 {
 	return node.prediction.GetValue(0)
 }
 
-func (dt *RegressionTree) Init(params map[string]string) {
+var RegressionTreeParamSchema = ParamSchema{
+	IntParam("min-leaf-size", 10, 0, math.Inf(1), "min leaf size in dt"),
+	IntParam("max-depth", 10, 1, math.Inf(1), "max depth of dt"),
+	MaxBinsParam,
+	CategoricalFeaturesParam,
+}
+
+func init() {
+	RegisterAlgorithm(Algorithm{
+		Name: "cart-regression",
+		Description: "regression tree",
+		Types: AlgorithmTypeEnum.BINARY | AlgorithmTypeEnum.REGRESSION,
+		Params: RegressionTreeParamSchema,
+		New: func() interface{} { return &(RegressionTree{}) },
+	})
+}
+
+func (dt *RegressionTree) Init(params map[string]string) error {
 	dt.tree = Tree{}
-	min_leaf_size, _ := strconv.ParseInt(params["min-leaf-size"], 10, 32)
-	max_depth, _ := strconv.ParseInt(params["max-depth"], 10, 32)
+	values, err := RegressionTreeParamSchema.Parse(params)
+	if err != nil {
+		return err
+	}
 	
-	dt.params.MinLeafSize = int(min_leaf_size)
-	dt.params.MaxDepth = int(max_depth)
-	dt.params.GiniThreshold, _ = strconv.ParseFloat(params["gini"], 64)
+	dt.params.MinLeafSize = values.Int("min-leaf-size")
+	dt.params.MaxDepth = values.Int("max-depth")
+	dt.params.MaxBins = values.Int("max-bins")
+	dt.params.CategoricalFeatures, err = ParseFeatureIds(values.String("categorical-features"))
+	if err != nil {
+		return err
+	}
+	dt.init_params = params
+	return nil
 }
 
@@ -1,36 +1,97 @@
 package hector
 
 import(
+	"context"
 	"math/rand"
-	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 )
 
 type SAOptAUC struct {
 	Model map[int64]float64
+	rng *rand.Rand
+	init_params map[string]string
 }
 
-func (self *SAOptAUC) SaveModel(path string){
+var SAOptAUCParamSchema = ParamSchema{
+	SeedParam,
+}
 
+func init() {
+	RegisterAlgorithm(Algorithm{
+		Name: "sa",
+		Description: "linear model optimizing AUC by simulated annealing",
+		Types: AlgorithmTypeEnum.BINARY,
+		Params: SAOptAUCParamSchema,
+		New: func() interface{} { return &(SAOptAUC{}) },
+	})
 }
 
-func (self *SAOptAUC) LoadModel(path string){
-	
+func (self *SAOptAUC) SaveModel(path string) error {
+	header := NewModelHeader(self, self.init_params, len(self.Model))
+	if header.Binary() {
+		w := NewBinaryWriter(header.Format)
+		WeightsToBinary(w, self.Model)
+		return WriteModelFile(path, header, w.Bytes())
+	}
+	sb := StringBuilder{}
+	for f, g := range self.Model {
+		sb.Int64(f)
+		sb.Write("\t")
+		sb.Float(g)
+		sb.Write("\n")
+	}
+	return WriteModelFile(path, header, sb.Bytes())
+}
+
+func (self *SAOptAUC) LoadModel(path string) error {
+	header, body, err := ReadModelBody(path, self)
+	if err != nil {
+		return err
+	}
+	if header.Binary() {
+		r := NewBinaryReader(body, header.Format)
+		WeightsFromBinary(r, self.Model)
+		return r.Err()
+	}
+
+	scaner := NewModelScanner(body)
+	for scaner.Scan() {
+		line := scaner.Text()
+		tks := strings.Split(line, "\t")
+		fid, _ := strconv.ParseInt(tks[0], 10, 64)
+		fw, _ := strconv.ParseFloat(tks[1], 64)
+		self.Model[fid] = fw
+	}
+	return nil
 }
 
-func (algo *SAOptAUC) Init(params map[string]string) {
+func (algo *SAOptAUC) Init(params map[string]string) error {
 	algo.Model = make(map[int64]float64)
+	algo.init_params = params
+	values, err := SAOptAUCParamSchema.Parse(params)
+	if err != nil {
+		return err
+	}
+	algo.rng = NewRand(int64(values.Int("seed")))
+	return nil
 }
 
 func (algo *SAOptAUC) TrainAUC(samples []*Sample) float64 {
 	predictions := []*LabelPrediction{}
 	for _, sample := range samples {
 		pred := algo.Predict(sample)
-		predictions = append(predictions, &(LabelPrediction{Label: sample.Label, Prediction: pred}))
+		predictions = append(predictions, &(LabelPrediction{Label: sample.Label, Prediction: pred, Weight: sample.Weight, HasWeight: true}))
 	}
 	return AUC(predictions)
 }
 
 func (algo *SAOptAUC) Train(dataset * DataSet) {
+	algo.TrainContext(context.Background(), dataset, nil)
+}
+
+func (algo *SAOptAUC) TrainContext(ctx context.Context, dataset *DataSet, sink ProgressSink) error {
 	algo.Model = make(map[int64]float64)
 	samples := []*Sample{}
 	for _, sample := range dataset.Samples {
@@ -44,17 +105,21 @@ func (algo *SAOptAUC) Train(dataset * DataSet) {
 	for fid, _ := range algo.Model {
 		features = append(features, fid)
 	}
+	sort.Slice(features, func(i, j int) bool { return features[i] < features[j] })
 	
 	prev_auc := 0.5
 	for i := 0; i < 5000; i++ {
-		add := rand.Float64()
-		fid := features[rand.Intn(len(features))]
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		add := algo.rng.Float64()
+		fid := features[algo.rng.Intn(len(features))]
 		fweight := algo.Model[fid]
 		algo.Model[fid] = add
 		auc := algo.TrainAUC(samples)
 		
 		if i % 500 == 0{
-			fmt.Println(prev_auc)
+			sink.Send(TrainEvent{Kind: TrainEventEnum.ITERATION, Step: i, Total: 5000, Metric: "auc", Value: prev_auc})
 		}
 		
 		if prev_auc < auc {
@@ -63,7 +128,8 @@ func (algo *SAOptAUC) Train(dataset * DataSet) {
 			algo.Model[fid] = fweight
 		}
 	}
-	fmt.Println(algo.Model)
+	logDebug("model of sa", "weights", algo.Model)
+	return nil
 }
 
 func (algo *SAOptAUC) Predict(sample * Sample) float64 {
@@ -26,19 +26,27 @@ func (s *RawSample) GetFeatureValue(key string) string {
 
 
 /*
-Here, label should be int value started from 0
+Here, label should be int value started from 0. Weight is how many times the sample counts in training
+and evaluation, e.g. to correct negative downsampling. NewSample and ParseSample set it to 1, and samples
+of weight 0 do not count
 */
 type Sample struct {
 	Features []Feature
 	Label int
+	Weight float64
 
 	Prediction float64
 }
 
+func (s *Sample) GetWeight() float64 {
+	return s.Weight
+}
+
 func NewSample() *Sample {
 	ret := Sample{}
 	ret.Features = []Feature{}
 	ret.Label = 0
+	ret.Weight = 1.0
 	ret.Prediction = 0.0
 	return &ret
 }
@@ -46,6 +54,7 @@ func NewSample() *Sample {
 func (s *Sample) Clone() *Sample {
 	ret := NewSample()
 	ret.Label = s.Label
+	ret.Weight = s.Weight
 	ret.Prediction = s.Prediction
 	for _, feature := range s.Features {
 		clone_feature := Feature{feature.Id, feature.Value}
@@ -58,6 +67,10 @@ func (s *Sample) Clone() *Sample {
 func (s *Sample) ToString(includePrediction bool) []byte {
 	sb := StringBuilder{}
 	sb.Int(s.Label)
+	if s.Weight != 1.0 {
+		sb.Write(":")
+		sb.Float(s.Weight)
+	}
 	sb.Write(" ")
 	if includePrediction {
 		sb.Float(s.Prediction)
@@ -87,10 +100,15 @@ func (s *Sample) AddFeature(f Feature){
 type MapBasedSample struct {
 	Features map[int64]float64
 	Label int
+	Weight float64
 
 	Prediction float64	
 }
 
+func (s *MapBasedSample) GetWeight() float64 {
+	return s.Weight
+}
+
 func (s *MapBasedSample) LabelDoubleValue() float64 {
 	return float64(s.Label)
 }
@@ -99,6 +117,7 @@ func (s *Sample) ToMapBasedSample() *MapBasedSample {
 	ret := MapBasedSample{}
 	ret.Features = make(map[int64]float64)
 	ret.Label = s.Label
+	ret.Weight = s.Weight
 	ret.Prediction = s.Prediction
 	for _, feature := range s.Features{
 		ret.Features[feature.Id] = feature.Value
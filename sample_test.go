@@ -0,0 +1,254 @@
+package hector
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestSampleWeight(t *testing.T) {
+	sample := ParseSample("1:2.5 3:0.5 7:1", -1)
+	if sample.Label != 1 || sample.Weight != 2.5 || len(sample.Features) != 2 {
+		t.Fatalf("sample is parsed as %v", sample)
+	}
+	parsed := ParseSample(string(sample.ToString(false)), -1)
+	if parsed.Label != 1 || parsed.Weight != 2.5 || parsed.ToMapBasedSample().GetWeight() != 2.5 {
+		t.Errorf("sample is written as %s", sample.ToString(false))
+	}
+	if ParseSample("0 3:0.5", -1).GetWeight() != 1.0 || string(ParseSample("0 3:0.5", -1).ToString(false)) != "0 3:0.5 " {
+		t.Error("samples without weight do not count once")
+	}
+	if ParseSample("0:0 3:0.5", -1).GetWeight() != 0.0 || NewSample().GetWeight() != 1.0 {
+		t.Error("weight 0 is not kept")
+	}
+	for _, line := range []string{"1:abc 3:0.5", "1:-2 3:0.5", "1:NaN 3:0.5"} {
+		if _, err := ParseSampleStrict(line, -1); err == nil {
+			t.Errorf("bad weight of %q is not reported", line)
+		}
+	}
+
+	// samples of integer weights are trained as duplicated samples by trees
+	weighted := NewDataSet()
+	duplicated := NewDataSet()
+	for i := 0; i < 300; i++ {
+		sample := NewSample()
+		x := rand.Float64()
+		if x + 0.3 * rand.NormFloat64() > 0.5 {
+			sample.Label = 1
+		}
+		sample.AddFeature(Feature{Id: 1, Value: x})
+		sample.AddFeature(Feature{Id: 2, Value: rand.Float64()})
+		sample.Weight = float64(1 + rand.Intn(3))
+		weighted.AddSample(sample)
+		for k := 0; k < int(sample.Weight); k++ {
+			clone := sample.Clone()
+			clone.Weight = 1
+			duplicated.AddSample(clone)
+		}
+	}
+	params := DefaultParams()
+	params["max-depth"] = "4"
+	params["min-leaf-size"] = "0"
+	for _, method := range []string{"cart", "cart-regression"} {
+		for _, max_bins := range []string{"0", "1000"} {
+			params["max-bins"] = max_bins
+			a, _ := GetClassifier(method)
+			a.Init(params)
+			a.Train(weighted)
+			b, _ := GetClassifier(method)
+			b.Init(params)
+			b.Train(duplicated)
+			for _, sample := range weighted.Samples {
+				if a.Predict(sample) != b.Predict(sample) {
+					t.Fatalf("%s with %s bins predicts %f by weights, %f by duplicated samples", method, max_bins, a.Predict(sample), b.Predict(sample))
+				}
+			}
+		}
+	}
+
+	// so are samples of gbdt by all losses, first-order or second-order, and by softmax loss of more labels.
+	// Samples have one feature and trees are shallow, since splits by two features or of pure nodes may
+	// separate the same samples, then their gains tie up to rounding of weighted and duplicated sums
+	rng := rand.New(rand.NewSource(1))
+	weighted = NewDataSet()
+	duplicated = NewDataSet()
+	for i := 0; i < 300; i++ {
+		sample := NewSample()
+		x := rng.Float64()
+		if x + 0.3 * rng.NormFloat64() > 0.5 {
+			sample.Label = 1
+		}
+		sample.AddFeature(Feature{Id: 1, Value: x})
+		sample.Weight = float64(1 + rng.Intn(3))
+		weighted.AddSample(sample)
+		for k := 0; k < int(sample.Weight); k++ {
+			clone := sample.Clone()
+			clone.Weight = 1
+			duplicated.AddSample(clone)
+		}
+	}
+	params["tree-count"] = "5"
+	params["max-depth"] = "2"
+	params["max-bins"] = "0"
+	for _, loss := range []string{"logistic", "squared", "absolute", "huber"} {
+		for _, second_order := range []string{"0", "1"} {
+			params["loss"] = loss
+			params["second-order"] = second_order
+			a, b := GBDT{}, GBDT{}
+			a.Init(params)
+			a.Train(weighted)
+			b.Init(params)
+			b.Train(duplicated)
+			for _, sample := range weighted.Samples {
+				if math.Abs(a.Predict(sample) - b.Predict(sample)) > 1e-6 {
+					t.Fatalf("gbdt of %s loss and second order %s predicts %f by weights, %f by duplicated samples", loss, second_order, a.Predict(sample), b.Predict(sample))
+				}
+			}
+		}
+	}
+	for _, dataset := range []*DataSet{weighted, duplicated} {
+		for _, sample := range dataset.Samples {
+			sample.Label = (sample.Label + int(3 * sample.Features[0].Value)) % 3
+		}
+	}
+	params["loss"] = "logistic"
+	for _, second_order := range []string{"0", "1"} {
+		params["second-order"] = second_order
+		a, b := GBDT{}, GBDT{}
+		a.Init(params)
+		a.Train(weighted)
+		b.Init(params)
+		b.Train(duplicated)
+		for _, sample := range weighted.Samples {
+			p, q := a.PredictMultiClass(sample), b.PredictMultiClass(sample)
+			for k := 0; k < 3; k++ {
+				if math.Abs(p.GetValue(k) - q.GetValue(k)) > 1e-6 {
+					t.Fatalf("multi-class gbdt of second order %s predicts %f by weights, %f by duplicated samples", second_order, p.GetValue(k), q.GetValue(k))
+				}
+			}
+		}
+	}
+
+	// positive samples weigh 4 times as negative samples, so predictions of the bias are about 0.8
+	dataset := NewDataSet()
+	for i := 0; i < 400; i++ {
+		sample := NewSample()
+		sample.Label = i % 2
+		if sample.Label == 1 {
+			sample.Weight = 4.0
+		}
+		sample.AddFeature(Feature{Id: 1, Value: 1.0})
+		dataset.AddSample(sample)
+	}
+	params = DefaultParams()
+	params["steps"] = "5"
+	params["learning-rate"] = "0.01"
+	params["regularization"] = "0.0"
+	params["alpha"] = "0.1"
+	params["hidden"] = "2"
+	params["seed"] = "1"
+	for _, method := range []string{"lr", "ftrl", "fm", "ann", "lr-lbfgs"} {
+		classifier, err := GetClassifier(method)
+		if err != nil {
+			t.Fatal(err)
+		}
+		classifier.Init(params)
+		classifier.Train(dataset)
+		prediction := classifier.Predict(dataset.Samples[0])
+		t.Logf("%s predicts %f for samples of weighted labels", method, prediction)
+		if prediction < 0.65 {
+			t.Errorf("%s predicts %f, weights of samples are not used", method, prediction)
+		}
+	}
+	for _, optimizer := range []string{"sgd", "lbfgs"} {
+		params["optimizer"] = optimizer
+		softmax, _ := GetMutliClassClassifier("softmax")
+		softmax.Init(params)
+		softmax.Train(dataset)
+		prediction := softmax.PredictMultiClass(dataset.Samples[0]).GetValue(1)
+		if prediction < 0.65 {
+			t.Errorf("softmax by %s predicts %f, weights of samples are not used", optimizer, prediction)
+		}
+	}
+}
+
+func TestZeroSampleWeight(t *testing.T) {
+	// samples of weight 0 are split into nodes of their own by a feature only they have
+	rng := rand.New(rand.NewSource(1))
+	zeroWeightDataSet := func() *DataSet {
+		ret := NewDataSet()
+		for i := 0; i < 400; i++ {
+			sample := NewSample()
+			x := rng.Float64()
+			if x > 0.5 {
+				sample.Label = 1
+			}
+			sample.AddFeature(Feature{Id: 1, Value: x})
+			if i % 4 == 0 {
+				sample.Weight = 0
+				sample.AddFeature(Feature{Id: 100, Value: 1.0})
+			}
+			ret.AddSample(sample)
+		}
+		return ret
+	}
+	params := DefaultParams()
+	params["max-depth"] = "6"
+	params["min-leaf-size"] = "0"
+	params["tree-count"] = "5"
+	params["seed"] = "1"
+	for _, method := range []string{"cart", "cart-regression", "rf", "rdt"} {
+		for _, max_bins := range []string{"0", "1000"} {
+			params["max-bins"] = max_bins
+			classifier, _ := GetClassifier(method)
+			classifier.Init(params)
+			// rdt drops samples of the dataset after training
+			classifier.Train(zeroWeightDataSet())
+			for i, sample := range zeroWeightDataSet().Samples {
+				if math.IsNaN(classifier.Predict(sample)) {
+					t.Fatalf("%s with %s bins predicts NaN for sample %d", method, max_bins, i)
+				}
+			}
+		}
+	}
+}
+
+func TestZeroTotalWeight(t *testing.T) {
+	zeroWeightDataSet := func() *DataSet {
+		ret := LinearDataSet(100)
+		for _, sample := range ret.Samples {
+			sample.Weight = 0
+		}
+		return ret
+	}
+	params := DefaultParams()
+	params["tree-count"] = "5"
+	params["seed"] = "1"
+	params["optimizer"] = "lbfgs"
+	lr, _ := GetClassifier("lr-lbfgs")
+	lr.Init(params)
+	if TrainWithContext(context.Background(), lr, zeroWeightDataSet(), nil) == nil {
+		t.Error("a dataset whose samples all weigh 0 is not rejected")
+	}
+	// classifiers trained directly learn nothing but predict numbers
+	for _, method := range []string{"lr-lbfgs", "cart", "cart-regression", "rf", "rdt", "gbdt"} {
+		classifier, _ := GetClassifier(method)
+		classifier.Init(params)
+		classifier.Train(zeroWeightDataSet())
+		if prediction := classifier.Predict(zeroWeightDataSet().Samples[0]); math.IsNaN(prediction) || math.IsInf(prediction, 0) {
+			t.Errorf("%s predicts %f for samples which all weigh 0", method, prediction)
+		}
+	}
+	for _, method := range []string{"softmax", "gbdt"} {
+		classifier, _ := GetMutliClassClassifier(method)
+		classifier.Init(params)
+		classifier.Train(zeroWeightDataSet())
+		prediction := classifier.PredictMultiClass(zeroWeightDataSet().Samples[0])
+		for k := 0; k < 2; k++ {
+			if math.IsNaN(prediction.GetValue(k)) {
+				t.Errorf("%s predicts NaN for label %d of samples which all weigh 0", method, k)
+			}
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package hector
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type SoftmaxRegressionParams struct {
+	Optimizer string
+	LearningRate float64
+	L1, L2 float64
+	Steps int
+	Settings LBFGSSettings
+	Verbose int
+}
+
+/*
+SoftmaxRegression is multinomial logistic regression, Model keeps one weight vector per label.
+Labels are 0, 1, ..., MaxLabel
+*/
+type SoftmaxRegression struct {
+	Model *Matrix
+	MaxLabel int
+	Params SoftmaxRegressionParams
+	init_params map[string]string
+}
+
+var SoftmaxRegressionParamSchema = MergeParamSchemas(ParamSchema{
+	StringParam("optimizer", "sgd", "optimizer of softmax regression", "sgd", "lbfgs"),
+}, LogisticRegressionParamSchema, LBFGSLogisticRegressionParamSchema)
+
+func init() {
+	RegisterAlgorithm(Algorithm{
+		Name: "softmax",
+		Description: "softmax regression by SGD or L-BFGS with L1 and L2 regularization",
+		Types: AlgorithmTypeEnum.BINARY | AlgorithmTypeEnum.MULTI_CLASS,
+		Params: SoftmaxRegressionParamSchema,
+		New: func() interface{} { return &(SoftmaxRegression{}) },
+	})
+}
+
+func (algo *SoftmaxRegression) Init(params map[string]string) error {
+	algo.Model = NewMatrix()
+	algo.MaxLabel = 0
+	values, err := SoftmaxRegressionParamSchema.Parse(params)
+	if err != nil {
+		return err
+	}
+	algo.Params.Optimizer = values.String("optimizer")
+	algo.Params.LearningRate = values.Float("learning-rate")
+	algo.Params.L2 = values.Float("regularization")
+	algo.Params.L1 = values.Float("l1-regularization")
+	algo.Params.Steps = values.Int("steps")
+	algo.Params.Settings = DefaultLBFGSSettings()
+	algo.Params.Settings.HistorySize = values.Int("lbfgs-history")
+	algo.Params.Settings.MaxIterations = values.Int("lbfgs-iterations")
+	algo.Params.Settings.GradientTolerance = values.Float("lbfgs-gradient-tolerance")
+	algo.Params.Settings.RelativeCostTolerance = values.Float("lbfgs-cost-tolerance")
+	algo.Params.Verbose = values.Int("verbose")
+	algo.init_params = params
+	return nil
+}
+
+func (algo *SoftmaxRegression) Train(dataset * DataSet) {
+	algo.TrainContext(context.Background(), dataset, nil)
+}
+
+/*
+TrainContext stops SGD after the current sample, or L-BFGS after the current iteration, when ctx is done
+*/
+func (algo *SoftmaxRegression) TrainContext(ctx context.Context, dataset *DataSet, sink ProgressSink) error {
+	algo.Model = NewMatrix()
+	algo.MaxLabel = 0
+	for _, sample := range dataset.Samples {
+		if algo.MaxLabel < sample.Label {
+			algo.MaxLabel = sample.Label
+		}
+	}
+	for k := 0; k <= algo.MaxLabel; k++ {
+		algo.Model.data[int64(k)] = NewVector()
+	}
+	if algo.Params.Optimizer == "lbfgs" {
+		return algo.trainLBFGS(ctx, dataset, sink)
+	}
+	return algo.trainSGD(ctx, dataset, sink)
+}
+
+func (algo *SoftmaxRegression) trainSGD(ctx context.Context, dataset * DataSet, sink ProgressSink) error {
+	learning_rate := algo.Params.LearningRate
+	for step := 0; step < algo.Params.Steps; step++ {
+		for _, sample := range dataset.Samples {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			prediction := algo.PredictMultiClass(sample)
+			for k := 0; k <= algo.MaxLabel; k++ {
+				err := -prediction.GetValue(k)
+				if k == sample.Label {
+					err += 1.0
+				}
+				err *= sample.GetWeight()
+				weights := algo.Model.data[int64(k)]
+				for _, feature := range sample.Features {
+					w := weights.GetValue(feature.Id)
+					w += learning_rate * (err * feature.Value - algo.Params.L2 * w)
+					// truncated gradient for L1, weight does not cross zero
+					if w > 0 {
+						w = math.Max(0, w - learning_rate * algo.Params.L1)
+					} else {
+						w = math.Min(0, w + learning_rate * algo.Params.L1)
+					}
+					if w == 0 {
+						delete(weights.data, feature.Id)
+					} else {
+						weights.SetValue(feature.Id, w)
+					}
+				}
+			}
+		}
+		learning_rate *= 0.9
+		sink.Send(TrainEvent{Kind: TrainEventEnum.EPOCH, Step: step + 1, Total: algo.Params.Steps})
+	}
+	return nil
+}
+
+func (algo *SoftmaxRegression) trainLBFGS(ctx context.Context, dataset * DataSet, sink ProgressSink) error {
+	if len(dataset.Samples) == 0 {
+		return nil
+	}
+	loss := NewSoftmaxLoss(dataset, algo.MaxLabel + 1, algo.Params.L2)
+	minimizer := LBFGSMinimizer{L1: algo.Params.L1, Settings: algo.Params.Settings}
+	minimizer.Callback = contextCallback(ctx, algo.Params.Settings.MaxIterations, sink)
+	result := minimizer.Optimize(loss, NewVector())
+	logVerbose(algo.Params.Verbose, "L-BFGS stops", "reason", result.Reason, "iterations", result.Iterations)
+	algo.Model = loss.ToMatrix(result.Position)
+	return ctx.Err()
+}
+
+func (algo *SoftmaxRegression) PredictMultiClass(sample * Sample) * ArrayVector {
+	z := NewArrayVector()
+	for k := 0; k <= algo.MaxLabel; k++ {
+		z.SetValue(k, algo.Model.data[int64(k)].DotFeatures(sample.Features))
+	}
+	return z.SoftMaxNorm()
+}
+
+func (algo *SoftmaxRegression) Predict(sample * Sample) float64 {
+	return algo.PredictMultiClass(sample).GetValue(1)
+}
+
+/*
+Model file starts with a line of max label, followed by one line per label : label and weight vector
+*/
+func (algo *SoftmaxRegression) SaveModel(path string) error {
+	rows := []*Vector{}
+	for _, row := range algo.Model.data {
+		rows = append(rows, row)
+	}
+	header := NewModelHeader(algo, algo.init_params, FeatureCountOfVectors(rows...))
+	if header.Binary() {
+		w := NewBinaryWriter(header.Format)
+		w.Int(algo.MaxLabel)
+		algo.Model.WriteBinary(w)
+		return WriteModelFile(path, header, w.Bytes())
+	}
+	sb := StringBuilder{}
+	sb.Int(algo.MaxLabel)
+	sb.Write("\n")
+	sb.WriteBytes(algo.Model.ToString())
+	return WriteModelFile(path, header, sb.Bytes())
+}
+
+func (algo *SoftmaxRegression) LoadModel(path string) error {
+	header, body, err := ReadModelBody(path, algo)
+	if err != nil {
+		return err
+	}
+	algo.Model = NewMatrix()
+	if header.Binary() {
+		r := NewBinaryReader(body, header.Format)
+		algo.MaxLabel = r.Int()
+		algo.Model.ReadBinary(r)
+		if r.Err() != nil {
+			return r.Err()
+		}
+	} else {
+		lines := strings.SplitN(string(body), "\n", 2)
+		algo.MaxLabel, err = strconv.Atoi(lines[0])
+		if err != nil {
+			return fmt.Errorf("bad max label in softmax model %s", path)
+		}
+		if len(lines) > 1 {
+			algo.Model.FromString(lines[1])
+		}
+	}
+	for k := 0; k <= algo.MaxLabel; k++ {
+		if _, ok := algo.Model.data[int64(k)]; !ok {
+			algo.Model.data[int64(k)] = NewVector()
+		}
+	}
+	return nil
+}
+
+/*
+SoftmaxLoss is the weighted average negative log likelihood of softmax regression plus L2 regularization.
+Weights of all labels are kept in one Vector for LBFGSMinimizer, weight of feature f and label k
+is at index(f) * labels + k, where index numbers features in the dataset from 0
+*/
+type SoftmaxLoss struct {
+	dataset *DataSet
+	labels int64
+	l2 float64
+	index map[int64]int64
+	fids []int64
+}
+
+func NewSoftmaxLoss(dataset *DataSet, labels int, l2 float64) *SoftmaxLoss {
+	ret := SoftmaxLoss{dataset: dataset, labels: int64(labels), l2: l2, index: make(map[int64]int64)}
+	for _, sample := range dataset.Samples {
+		for _, feature := range sample.Features {
+			if _, ok := ret.index[feature.Id]; !ok {
+				ret.index[feature.Id] = int64(len(ret.fids))
+				ret.fids = append(ret.fids, feature.Id)
+			}
+		}
+	}
+	return &ret
+}
+
+func (f *SoftmaxLoss) probabilities(pos *Vector, sample *Sample) *ArrayVector {
+	z := NewArrayVector()
+	for k := int64(0); k < f.labels; k++ {
+		sum := 0.0
+		for _, feature := range sample.Features {
+			sum += pos.GetValue(f.index[feature.Id] * f.labels + k) * feature.Value
+		}
+		z.SetValue(int(k), sum)
+	}
+	return z.SoftMaxNorm()
+}
+
+func (f *SoftmaxLoss) Value(pos *Vector) float64 {
+	ret := 0.0
+	for _, sample := range f.dataset.Samples {
+		p := f.probabilities(pos, sample).GetValue(sample.Label)
+		ret -= sample.GetWeight() * math.Log(math.Max(p, 1e-300))
+	}
+	ret /= f.dataset.lossWeight()
+	return ret + 0.5 * f.l2 * pos.SortedNormL2()
+}
+
+func (f *SoftmaxLoss) Gradient(pos *Vector) *Vector {
+	ret := NewVector()
+	n := f.dataset.lossWeight()
+	for _, sample := range f.dataset.Samples {
+		p := f.probabilities(pos, sample)
+		for k := int64(0); k < f.labels; k++ {
+			err := p.GetValue(int(k))
+			if int(k) == sample.Label {
+				err -= 1.0
+			}
+			err *= sample.GetWeight()
+			for _, feature := range sample.Features {
+				ret.AddValue(f.index[feature.Id] * f.labels + k, err * feature.Value / n)
+			}
+		}
+	}
+	for key, val := range pos.data {
+		ret.AddValue(key, f.l2 * val)
+	}
+	return ret
+}
+
+/*
+ToMatrix converts weights in the layout of SoftmaxLoss to one row per label
+*/
+func (f *SoftmaxLoss) ToMatrix(pos *Vector) *Matrix {
+	ret := NewMatrix()
+	for k := int64(0); k < f.labels; k++ {
+		ret.data[k] = NewVector()
+	}
+	for key, val := range pos.data {
+		if val != 0 {
+			ret.SetValue(key % f.labels, f.fids[key / f.labels], val)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,55 @@
+package hector
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"testing"
+)
+
+func TestSoftmaxRegression(t *testing.T) {
+	train_dataset := MultiClassDataSet(2000, 4)
+	test_dataset := MultiClassDataSet(500, 4)
+
+	file, _ := ioutil.TempFile("", "hector-softmax")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	for _, optimizer := range []string{"sgd", "lbfgs"} {
+		params := DefaultParams()
+		params["optimizer"] = optimizer
+		params["steps"] = "10"
+		params["learning-rate"] = "0.05"
+		params["regularization"] = "0.0001"
+		params["l1-regularization"] = "0.0001"
+
+		classifier := SoftmaxRegression{}
+		err := classifier.Init(params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		accuracy := MultiClassRunOnDataSet(&classifier, train_dataset, test_dataset, "", params)
+		t.Logf("accuracy of softmax by %s is %f", optimizer, accuracy)
+		if accuracy < 0.8 {
+			t.Errorf("accuracy of softmax by %s is less than 0.8", optimizer)
+		}
+
+		err = classifier.SaveModel(file.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := LoadAnyMultiClassModel(file.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, sample := range test_dataset.Samples {
+			p1 := classifier.PredictMultiClass(sample)
+			p2 := loaded.PredictMultiClass(sample)
+			for k := 0; k < 4; k++ {
+				if math.Abs(p1.GetValue(k) - p2.GetValue(k)) > 1e-9 {
+					t.Fatalf("probability of label %d on sample %d changes from %f to %f after load", k, i, p1.GetValue(k), p2.GetValue(k))
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package hector
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+)
+
+/*
+StreamingDataSet delivers samples one by one through a channel, so online learners
+can train on files which do not fit in memory
+*/
+type StreamingDataSet struct {
+	Samples chan *Sample
+	err error
+}
+
+func NewStreamingDataSet(buffer int) *StreamingDataSet {
+	ret := StreamingDataSet{}
+	ret.Samples = make(chan *Sample, buffer)
+	return &ret
+}
+
+/*
+OpenStreamingDataSet starts reading path in background. Caller should range over Samples
+and check Err() after the channel is closed
+*/
+func OpenStreamingDataSet(path string, global_bias_feature_id int64) *StreamingDataSet {
+	return OpenStreamingDataSetContext(context.Background(), path, global_bias_feature_id)
+}
+
+/*
+OpenStreamingDataSetContext is OpenStreamingDataSet which stops reading when ctx is done, then the
+channel is closed and Err() is ctx.Err()
+*/
+func OpenStreamingDataSetContext(ctx context.Context, path string, global_bias_feature_id int64) *StreamingDataSet {
+	ret := NewStreamingDataSet(1000)
+	go ret.LoadContext(ctx, path, global_bias_feature_id)
+	return ret
+}
+
+/*
+StreamDataSet sends the samples of an in-memory dataset through a stream
+*/
+func StreamDataSet(dataset *DataSet) *StreamingDataSet {
+	ret := NewStreamingDataSet(1000)
+	go func() {
+		for _, sample := range dataset.Samples {
+			ret.Samples <- sample
+		}
+		close(ret.Samples)
+	}()
+	return ret
+}
+
+/*
+Load reads all samples of path into the channel and closes it when done
+*/
+func (d *StreamingDataSet) Load(path string, global_bias_feature_id int64) error {
+	return d.LoadContext(context.Background(), path, global_bias_feature_id)
+}
+
+/*
+LoadContext is Load which stops reading and closes the channel when ctx is done
+*/
+func (d *StreamingDataSet) LoadContext(ctx context.Context, path string, global_bias_feature_id int64) error {
+	defer close(d.Samples)
+	file, err := OpenDataFile(path)
+	if err != nil {
+		d.err = err
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	line := 0
+	for scanner.Scan() {
+		line++
+		sample, err := ParseSampleStrict(scanner.Text(), global_bias_feature_id)
+		if err != nil {
+			d.err = fmt.Errorf("line %d of %s: %v", line, path, err)
+			return d.err
+		}
+		select {
+		case d.Samples <- sample:
+		case <-ctx.Done():
+			d.err = ctx.Err()
+			return d.err
+		}
+	}
+	d.err = scanner.Err()
+	return d.err
+}
+
+func (d *StreamingDataSet) Err() error {
+	return d.err
+}
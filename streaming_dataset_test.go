@@ -0,0 +1,99 @@
+package hector
+
+import (
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func writeGzipDataSet(dataset *DataSet, t *testing.T) string {
+	file, err := ioutil.TempFile("", "hector-stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer := gzip.NewWriter(file)
+	for _, sample := range dataset.Samples {
+		writer.Write(sample.ToString(false))
+		writer.Write([]byte("\n"))
+	}
+	writer.Close()
+	file.Close()
+	path := file.Name() + ".gz"
+	os.Rename(file.Name(), path)
+	return path
+}
+
+func TestStreamingDataSet(t *testing.T) {
+	train_dataset := LinearDataSet(1000)
+	path := writeGzipDataSet(train_dataset, t)
+	defer os.Remove(path)
+
+	stream := OpenStreamingDataSet(path, -1)
+	count := 0
+	for sample := range stream.Samples {
+		if sample.Label != train_dataset.Samples[count].Label || len(sample.Features) != len(train_dataset.Samples[count].Features) {
+			t.Errorf("sample %d differs after streaming", count)
+		}
+		count++
+	}
+	if stream.Err() != nil {
+		t.Error(stream.Err())
+	}
+	if count != len(train_dataset.Samples) {
+		t.Errorf("streamed %d samples, expect %d", count, len(train_dataset.Samples))
+	}
+
+	// reading stops in the middle of the file when ctx is done
+	large_path := writeGzipDataSet(LinearDataSet(5000), t)
+	defer os.Remove(large_path)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream = OpenStreamingDataSetContext(ctx, large_path, -1)
+	count = 0
+	for _ = range stream.Samples {
+		count++
+		if count == 10 {
+			cancel()
+		}
+	}
+	if stream.Err() != context.Canceled || count >= 5000 {
+		t.Errorf("streamed %d samples after cancel, error is %v", count, stream.Err())
+	}
+}
+
+func TestTrainOnline(t *testing.T) {
+	train_dataset := LinearDataSet(1000)
+	test_dataset := LinearDataSet(500)
+	path := writeGzipDataSet(train_dataset, t)
+	defer os.Remove(path)
+
+	params := DefaultParams()
+	params["beta"] = "1.0"
+	params["steps"] = "10"
+	params["lambda1"] = "0.1"
+	params["lambda2"] = "1.0"
+	params["alpha"] = "0.1"
+	params["learning-rate"] = "0.05"
+	params["regularization"] = "0.0001"
+	params["factors"] = "10"
+	params["global"] = "-1"
+
+	for _, algo := range []string{"ep", "fm", "ftrl", "lr"} {
+		algorithm, err := GetClassifier(algo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		classifier := algorithm.(OnlineClassifier)
+		err = AlgorithmTrainOnline(classifier, path, params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		auc, _ := AlgorithmRunOnDataSet(classifier, nil, test_dataset, "", params)
+		t.Logf("auc of online %s in linear dataset is %f", algo, auc)
+		if auc < 0.9 {
+			t.Error("auc less than 0.9 in linear dataset")
+		}
+	}
+}
@@ -1,10 +1,11 @@
 package hector
 
 import (
+	"context"
 	"math"
-	"fmt"
-	"strconv"
 	"math/rand"
+	"strconv"
+	"strings"
 )
 
 type SVM struct {
@@ -17,14 +18,58 @@ type SVM struct {
 	w *Vector
 
 	xx []float64
+	rng *rand.Rand
+	init_params map[string]string
 }
 
-func (self *SVM) SaveModel(path string){
-
+/*
+Model file starts with a line of bias b, followed by one line per feature weight : fid and w
+*/
+func (self *SVM) SaveModel(path string) error {
+	header := NewModelHeader(self, self.init_params, len(self.w.data))
+	if header.Binary() {
+		w := NewBinaryWriter(header.Format)
+		w.Float64(self.b)
+		self.w.WriteBinary(w)
+		return WriteModelFile(path, header, w.Bytes())
+	}
+	sb := StringBuilder{}
+	sb.Float(self.b)
+	sb.Write("\n")
+	for f, g := range self.w.data {
+		sb.Int64(f)
+		sb.Write("\t")
+		sb.Float(g)
+		sb.Write("\n")
+	}
+	return WriteModelFile(path, header, sb.Bytes())
 }
 
-func (self *SVM) LoadModel(path string){
-	
+func (self *SVM) LoadModel(path string) error {
+	header, body, err := ReadModelBody(path, self)
+	if err != nil {
+		return err
+	}
+	if header.Binary() {
+		r := NewBinaryReader(body, header.Format)
+		self.w = NewVector()
+		self.b = r.Float64()
+		self.w.ReadBinary(r)
+		return r.Err()
+	}
+
+	self.w = NewVector()
+	scaner := NewModelScanner(body)
+	if scaner.Scan() {
+		self.b, _ = strconv.ParseFloat(scaner.Text(), 64)
+	}
+	for scaner.Scan() {
+		tks := strings.Split(scaner.Text(), "\t")
+		fid, _ := strconv.ParseInt(tks[0], 10, 64)
+		fw, _ := strconv.ParseFloat(tks[1], 64)
+		self.w.SetValue(fid, fw)
+	}
+	return nil
 }
 
 type SVMValues struct {
@@ -32,16 +77,46 @@ type SVMValues struct {
 	i1, i2 int
 }
 
-func (c *SVM) Init(params map[string]string){
-	c.C,_ = strconv.ParseFloat(params["c"], 64)
-	c.e,_ = strconv.ParseFloat(params["e"], 64)
+var SVMParamSchema = ParamSchema{
+	PositiveFloatParam("c", 1.0, math.Inf(1), "C in svm"),
+	PositiveFloatParam("e", 0.01, math.Inf(1), "stop threshold"),
+	SeedParam,
+}
+
+func init() {
+	RegisterAlgorithm(Algorithm{
+		Name: "svm",
+		Description: "svm optimized by SMO",
+		Types: AlgorithmTypeEnum.BINARY,
+		Params: SVMParamSchema,
+		New: func() interface{} { return &(SVM{}) },
+	})
+}
+
+func (c *SVM) Init(params map[string]string) error {
+	values, err := SVMParamSchema.Parse(params)
+	if err != nil {
+		return err
+	}
+	c.C = values.Float("c")
+	c.e = values.Float("e")
+	c.rng = NewRand(int64(values.Int("seed")))
 
 	c.w = NewVector()
+	c.init_params = params
+	return nil
 }
 
 func (c *SVM) Predict(sample *Sample) float64 {
-	x := sample.GetFeatureVector()
-	return c.PredictVector(x)
+	return c.predictFeatures(sample.Features)
+}
+
+/*
+predictFeatures sums products of weights in order of features, unlike Dot of vectors whose order of maps is random,
+so predictions and training are reproducible
+*/
+func (c *SVM) predictFeatures(features []Feature) float64 {
+	return c.w.DotFeatures(features) - c.b
 }
 
 func (c *SVM) PredictVector(x *Vector) float64 {
@@ -67,52 +142,62 @@ func (c *SVM) MatchKKT(y, f, a float64) bool{
 }
 
 func (c *SVM) Train(dataset *DataSet) {
+	c.TrainContext(context.Background(), dataset, nil)
+}
+
+/*
+TrainContext stops SMO after the current pair of multipliers when ctx is done, weights are kept consistent
+with the multipliers
+*/
+func (c *SVM) TrainContext(ctx context.Context, dataset *DataSet, sink ProgressSink) error {
 	c.sv = []*Vector{}
 	c.y = []float64{}
 	c.a = []float64{}
 	for k, sample := range dataset.Samples {
 		x := sample.GetFeatureVector()
 		c.sv = append(c.sv, x)
-		c.xx = append(c.xx, x.Dot(x))
+		c.xx = append(c.xx, x.DotFeatures(sample.Features))
 		if sample.Label > 0.0 {
 			c.y = append(c.y, 1.0)
 		} else {
 			c.y = append(c.y, -1.0)
 		}
-		c.a = append(c.a, c.C * rand.Float64())
+		c.a = append(c.a, c.C * c.rng.Float64())
 		c.w.AddVector(x, c.y[k] * c.a[k])
 	}
 
 	c.b = 0.0
-	for k, x := range c.sv {
-		c.b += c.PredictVector(x) - c.y[k]
+	for k, sample := range dataset.Samples {
+		c.b += c.predictFeatures(sample.Features) - c.y[k]
 	}
 	c.b /= float64(len(c.sv))
-	fmt.Println(c.b)
+	logDebug("initial bias of svm", "b", c.b)
 
 	for step := 0; step < 100; step++ {
 		da := 0.0
 		for i1 := 0; i1 < len(c.sv); i1++{
+			if err := ctx.Err(); err != nil {
+				return err
+			}
 			a1 := c.a[i1]
 			x1 := c.sv[i1]
 			y1 := c.y[i1]
-			p1 := c.PredictVector(x1)
+			p1 := c.predictFeatures(dataset.Samples[i1].Features)
 			if c.MatchKKT(y1, p1, a1) {
 				continue
 			}
 			maxde := 0.0
 			best_values := SVMValues{}
 			for k2 := 0; k2 < 10; k2++{
-				i2 := rand.Intn(len(c.sv))
+				i2 := c.rng.Intn(len(c.sv))
 				if i1 == i2{
 					continue
 				}
 				
-				x2 := c.sv[i2]
 				y2 := c.y[i2]
-				p2 := c.PredictVector(x2)
+				p2 := c.predictFeatures(dataset.Samples[i2].Features)
 				k11 := c.xx[i1]
-				k12 := x1.Dot(x2)
+				k12 := x1.DotFeatures(dataset.Samples[i2].Features)
 				k22 := c.xx[i2]
 				
 				a2 := c.a[i2]
@@ -166,9 +251,10 @@ func (c *SVM) Train(dataset *DataSet) {
 			c.a[best_values.i2] = best_values.a2
 		}
 		da /= float64(len(c.sv))
-		fmt.Printf(".. %f %f\n", da, c.b)
+		sink.Send(TrainEvent{Kind: TrainEventEnum.ITERATION, Step: step + 1, Total: 100, Metric: "multiplier-change", Value: da})
 		if da < c.e {
 			break
 		}
 	}
+	return nil
 }
\ No newline at end of file
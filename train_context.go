@@ -0,0 +1,147 @@
+package hector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"runtime"
+	"sync"
+)
+
+var TrainEventEnum = struct {
+	TREE, ROUND, EPOCH, ITERATION, VALIDATION, EARLY_STOPPING, OUT_OF_BAG string
+}{"tree", "round", "epoch", "iteration", "validation", "early-stopping", "out-of-bag"}
+
+/*
+TrainEvent is the progress of training, e.g. a tree of a forest is built, or a round of gbdt ends. Step
+is the count of steps done, Total is the count of all steps or 0 if it is unknown. Metric is the name
+of Value, e.g. a loss on the train dataset, and empty if the event has no value
+*/
+type TrainEvent struct {
+	Kind string
+	Step, Total int
+	Metric string
+	Value float64
+}
+
+/*
+ProgressSink receives events of training, it is called by one goroutine at a time. A nil sink drops events
+*/
+type ProgressSink func(event TrainEvent)
+
+func (sink ProgressSink) Send(event TrainEvent) {
+	if sink != nil {
+		sink(event)
+	}
+}
+
+/*
+ContextClassifier stops training when ctx is done, and sends progress of training to sink instead of
+printing it. Training stops at the end of the current step, e.g. a tree or an epoch, and returns ctx.Err().
+The model keeps the steps done, so it can be used and saved, e.g. a forest of the trees built
+*/
+type ContextClassifier interface {
+	TrainContext(ctx context.Context, dataset *DataSet, sink ProgressSink) error
+}
+
+/*
+TrainWithContext trains classifier by TrainContext if it is a ContextClassifier. Other classifiers can
+not be stopped, ctx is only checked before training. A dataset whose samples all weigh 0 is rejected,
+as there is nothing to learn from it
+*/
+func TrainWithContext(ctx context.Context, classifier interface{}, dataset *DataSet, sink ProgressSink) error {
+	if len(dataset.Samples) > 0 && dataset.TotalWeight() <= 0 {
+		return errors.New("samples of train dataset all weigh 0")
+	}
+	if trainer, ok := classifier.(ContextClassifier); ok {
+		return trainer.TrainContext(ctx, dataset, sink)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	switch trainer := classifier.(type) {
+	case Classifier:
+		trainer.Train(dataset)
+	case MultiClassClassifier:
+		trainer.Train(dataset)
+	}
+	return nil
+}
+
+/*
+WriterProgress renders events as lines of w, e.g. "round 10/100 residual-rmse : 0.312". Events are
+written every interval steps, and always at the last step and if they have no total
+*/
+func WriterProgress(w io.Writer, interval int) ProgressSink {
+	return func(event TrainEvent) {
+		if interval > 1 && event.Total > 0 && event.Step % interval != 0 && event.Step != event.Total {
+			return
+		}
+		line := fmt.Sprintf("%s %d", event.Kind, event.Step)
+		if event.Total > 0 {
+			line += fmt.Sprintf("/%d", event.Total)
+		}
+		if event.Metric != "" {
+			line += fmt.Sprintf(" %s : %f", event.Metric, event.Value)
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+/*
+parallelSteps runs step(i) for i from 0 to n - 1 by GOMAXPROCS goroutines, and sends an event of kind
+to sink after each step. Steps not started when ctx is done are skipped, and ctx.Err() is returned
+*/
+func parallelSteps(ctx context.Context, n int, kind string, sink ProgressSink, step func(i int)) error {
+	steps := make(chan int, n)
+	for i := 0; i < n; i++ {
+		steps <- i
+	}
+	close(steps)
+
+	var lock sync.Mutex
+	done := 0
+	skipped := false
+	var wait sync.WaitGroup
+	workers := runtime.GOMAXPROCS(0)
+	wait.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wait.Done()
+			for i := range steps {
+				if ctx.Err() != nil {
+					lock.Lock()
+					skipped = true
+					lock.Unlock()
+					continue
+				}
+				step(i)
+				lock.Lock()
+				done++
+				sink.Send(TrainEvent{Kind: kind, Step: done, Total: n})
+				lock.Unlock()
+			}
+		}()
+	}
+	wait.Wait()
+	if skipped {
+		return ctx.Err()
+	}
+	return nil
+}
+
+/*
+InterruptContext is done on the first SIGINT, which stops training at the end of the current step. SIGINT
+is only caught once, so a second Ctrl-C kills the process as usual, e.g. when a learner can not be stopped
+*/
+func InterruptContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, stop
+}
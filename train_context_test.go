@@ -0,0 +1,111 @@
+package hector
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestTrainContext(t *testing.T) {
+	params := DefaultParams()
+	params["max-depth"] = "4"
+	params["min-leaf-size"] = "5"
+	params["tree-count"] = "50"
+
+	// the sink cancels training after 3 trees, trees being built are kept
+	ctx, cancel := context.WithCancel(context.Background())
+	trees := 0
+	rf := RandomForest{}
+	rf.Init(params)
+	err := rf.TrainContext(ctx, importanceDataSet(500), func(event TrainEvent) {
+		if event.Kind == TrainEventEnum.TREE {
+			trees = event.Step
+		}
+		if trees == 3 {
+			cancel()
+		}
+	})
+	if err != context.Canceled {
+		t.Fatalf("training of rf stopped by cancel returns %v", err)
+	}
+	if len(rf.trees) < 3 || len(rf.trees) >= 50 || len(rf.trees) != trees {
+		t.Fatalf("rf has %d trees after %d events of trees", len(rf.trees), trees)
+	}
+	auc, _ := AlgorithmRunOnDataSet(&rf, nil, importanceDataSet(500), "", params)
+	if auc < 0.9 {
+		t.Errorf("auc of partial rf is %f", auc)
+	}
+
+	// forests cancelled before the first tree predict 0.5
+	cancelled, cancel_now := context.WithCancel(context.Background())
+	cancel_now()
+	for _, method := range []string{"rf", "rdt"} {
+		forest, _ := GetClassifier(method)
+		forest.Init(params)
+		TrainWithContext(cancelled, forest, importanceDataSet(100), nil)
+		if forest.Predict(importanceDataSet(1).Samples[0]) != 0.5 {
+			t.Errorf("%s without trees predicts %f", method, forest.Predict(importanceDataSet(1).Samples[0]))
+		}
+	}
+
+	// the partial model of gbdt is saved by AlgorithmTrainContext
+	path := writeGzipDataSet(LinearDataSet(500), t)
+	defer os.Remove(path)
+	file, _ := ioutil.TempFile("", "hector-gbdt")
+	file.Close()
+	defer os.Remove(file.Name())
+	params["model"] = file.Name()
+	params["global"] = "-1"
+	ctx, cancel = context.WithCancel(context.Background())
+	rounds := 0
+	gbdt := GBDT{}
+	err = AlgorithmTrainContext(ctx, &gbdt, path, params, func(event TrainEvent) {
+		if event.Kind == TrainEventEnum.ROUND {
+			rounds = event.Step
+			if event.Metric != "residual-rmse" || event.Total != 50 {
+				t.Errorf("bad event of gbdt %v", event)
+			}
+		}
+		if rounds == 5 {
+			cancel()
+		}
+	})
+	if err != context.Canceled {
+		t.Fatalf("training of gbdt stopped by cancel returns %v", err)
+	}
+	loaded := GBDT{}
+	err = loaded.LoadModel(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gbdt.dts) != 5 || len(loaded.dts) != 5 {
+		t.Fatalf("gbdt stopped after 5 rounds has %d trees, %d trees are saved", len(gbdt.dts), len(loaded.dts))
+	}
+
+	// other classifiers only check ctx before training
+	lr, _ := GetClassifier("lr")
+	lr.Init(params)
+	if TrainWithContext(ctx, lr, LinearDataSet(100), nil) != context.Canceled {
+		t.Error("lr is trained with a cancelled context")
+	}
+	if TrainWithContext(context.Background(), lr, LinearDataSet(100), nil) != nil {
+		t.Error("lr is not trained without a cancelled context")
+	}
+}
+
+func TestWriterProgress(t *testing.T) {
+	buffer := bytes.Buffer{}
+	sink := WriterProgress(&buffer, 10)
+	for step := 1; step <= 25; step++ {
+		sink.Send(TrainEvent{Kind: TrainEventEnum.ROUND, Step: step, Total: 25, Metric: "logloss", Value: 0.5})
+	}
+	sink.Send(TrainEvent{Kind: TrainEventEnum.ITERATION, Step: 3})
+	expected := "round 10/25 logloss : 0.500000\nround 20/25 logloss : 0.500000\nround 25/25 logloss : 0.500000\niteration 3\n"
+	if buffer.String() != expected {
+		t.Errorf("progress is written as %q", buffer.String())
+	}
+	var nil_sink ProgressSink
+	nil_sink.Send(TrainEvent{Kind: TrainEventEnum.TREE})
+}
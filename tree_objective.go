@@ -0,0 +1,50 @@
+package hector
+
+/*
+TreeObjective is the regularized second-order objective of "XGBoost: A Scalable Tree Boosting System".
+A node whose samples have gradient sum G and hessian sum H scores T(G)^2 / (H + Lambda), where T shrinks
+G towards zero by Alpha, and its value is -T(G) / (H + Lambda). A split gains half of the scores of
+children minus the score of the node, minus Gamma. Splits which do not gain, or leave a child with
+hessian sum less than MinChildWeight, are not made
+*/
+type TreeObjective struct {
+	Lambda, Alpha, Gamma, MinChildWeight float64
+}
+
+func (o *TreeObjective) threshold(g float64) float64 {
+	if g > o.Alpha {
+		return g - o.Alpha
+	}
+	if g < -o.Alpha {
+		return g + o.Alpha
+	}
+	return 0.0
+}
+
+func (o *TreeObjective) Score(g, h float64) float64 {
+	if h + o.Lambda < 1e-150 {
+		return 0.0
+	}
+	t := o.threshold(g)
+	return t * t / (h + o.Lambda)
+}
+
+func (o *TreeObjective) LeafValue(g, h float64) float64 {
+	if h + o.Lambda < 1e-150 {
+		return 0.0
+	}
+	return -o.threshold(g) / (h + o.Lambda)
+}
+
+/*
+Gain returns the gain of splitting a node into children of gradient and hessian sums (gl, hl) and (gr, hr),
+and false if the split should not be made
+*/
+func (o *TreeObjective) Gain(gl, hl, gr, hr float64) (float64, bool) {
+	if hl < o.MinChildWeight || hr < o.MinChildWeight {
+		return 0.0, false
+	}
+	gain := 0.5 * (o.Score(gl, hl) + o.Score(gr, hr) - o.Score(gl + gr, hl + hr)) - o.Gamma
+	// tiny gains are rounding errors of splits changing nothing
+	return gain, gain > 1e-12
+}
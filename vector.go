@@ -3,6 +3,7 @@ package hector
 import (
 	"math/rand"
 	"math"
+	"sort"
 	"strings"
 	"strconv"
 )
@@ -41,6 +42,22 @@ func (v *Vector) FromString(buf string) {
 	}
 }
 
+func (v *Vector) WriteBinary(w *BinaryWriter) {
+	w.Int(len(v.data))
+	for key, value := range v.data {
+		w.Varint(key)
+		w.Float(value)
+	}
+}
+
+func (v *Vector) ReadBinary(r *BinaryReader) {
+	n := r.Len()
+	for i := 0; i < n; i++ {
+		key := r.Varint()
+		v.data[key] = r.Float()
+	}
+}
+
 func (v *Vector) AddValue(key int64, value float64) {
 	_, ok := v.data[key]
 	if ok{
@@ -59,10 +76,13 @@ func (v *Vector) GetValue(key int64) float64{
 	}
 }
 
-func (v *Vector) RandomInit(key int64, c float64){
+/*
+RandomInit sets value of key to a normal random number of deviation c drawn from rng, unless key has a value
+*/
+func (v *Vector) RandomInit(key int64, c float64, rng *rand.Rand){
 	value, ok := v.data[key]
 	if !ok {
-		value = rand.NormFloat64() * c
+		value = rng.NormFloat64() * c
 		v.data[key] = value
 	}
 }
@@ -136,6 +156,43 @@ func (v *Vector) Dot(v2 *Vector) float64{
 	return ret	
 }
 
+/*
+SortedDot is Dot summed in order of keys. Order of maps is random, so sums of Dot may differ in the
+last bits between runs. SortedDot is slower, it is used where models must be reproducible, e.g. L-BFGS
+*/
+func (v *Vector) SortedDot(v2 *Vector) float64 {
+	va := v
+	vb := v2
+	if len(v2.data) < len(v.data) {
+		va = v2
+		vb = v
+	}
+	ret := 0.0
+	for _, key := range va.SortedKeys() {
+		b, ok := vb.data[key]
+		if ok {
+			ret += va.data[key] * b
+		}
+	}
+	return ret
+}
+
+/*
+SortedNormL2 is NormL2 summed in order of keys, see SortedDot
+*/
+func (v *Vector) SortedNormL2() float64 {
+	return v.SortedDot(v)
+}
+
+func (v *Vector) SortedKeys() []int64 {
+	ret := make([]int64, 0, len(v.data))
+	for key, _ := range v.data {
+		ret = append(ret, key)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i] < ret[j] })
+	return ret
+}
+
 func (v *Vector) DotFeatures(fs []Feature) float64{
 	ret := 0.0
 	for _, f := range fs{